@@ -10,6 +10,8 @@ import (
 	"concurrency_hw1/pkg/logger"
 	"context"
 	"flag"
+	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"syscall"
@@ -17,39 +19,48 @@ import (
 
 var ConfigFileName = os.Getenv("CONFIG_FILE_NAME")
 
+// flagPaths are the config settings overridden by the flags.
+var flagPaths = map[string]string{
+	"address":          "network.address",
+	"idle_timeout":     "network.idle_timeout",
+	"max_connections":  "network.max_connections",
+	"max_message_size": "network.max_message_size",
+}
+
 func main() {
 	logger := logger.New("debug", "local")
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
-	address := flag.String("address", "localhost:3223", "Address of the server")
-	idleTimeout := flag.Duration("idle_timeout", 1, "Idle timeout for connection")
-	maxConnections := flag.Int("max_connections", 100, "Max connections for server")
-	maxMessageSizeStr := flag.String("max_message_size", "4KB", "Max message size for connection")
+	defaults := config.Default()
+	flag.String("address", defaults.Network.Address, "Address of the server")
+	flag.Duration("idle_timeout", defaults.Network.IdleTimeout, "Idle timeout for connection")
+	flag.Int("max_connections", defaults.Network.MaxConnections, "Max connections for server")
+	flag.String("max_message_size", defaults.Network.MaxMessageSize, "Max message size for connection")
 	flag.Parse()
 
 	if ConfigFileName == "" {
 		ConfigFileName = "./../../config.yml"
 	}
 
-	cfg, err := config.Load(logger, ConfigFileName)
+	cfg, _, err := config.Resolve(logger, ConfigFileName, config.FlagOverrides(flag.CommandLine, flagPaths))
 	if err != nil {
-		if cfg.Network.Address == "" {
-			cfg.Network.Address = *address
-		}
-		if cfg.Network.MaxConnections == 0 {
-			cfg.Network.MaxConnections = *maxConnections
-		}
-		if cfg.Network.MaxMessageSize == "" {
-			cfg.Network.MaxMessageSize = *maxMessageSizeStr
-		}
-		if cfg.Network.IdleTimeout == 0 {
-			cfg.Network.IdleTimeout = *idleTimeout
-		}
+		logger.Fatal(fmt.Errorf("invalid config: %w", err))
 	}
 
 	parser := compute.NewParser()
-	engine := storage.NewEngine()
+	engine, err := storage.New(cfg.Engine, logger)
+	if err != nil {
+		logger.Error("failed to create storage engine: %w", err)
+		return
+	}
+	if closer, ok := engine.(io.Closer); ok {
+		defer func() {
+			if err := closer.Close(); err != nil {
+				logger.Error("failed to close storage engine: %w", err)
+			}
+		}()
+	}
 	diskStorage, err := disk.NewDiskStorage(cfg.Storage.Path, cfg.Storage.MaxSegmentSize, logger)
 	if err != nil {
 		logger.Error("failed to create disk storage: %w", err)
@@ -61,9 +72,17 @@ func main() {
 		return
 	}
 
-	wal := wal.NewWALService(storeChan, cfg.Storage.FlushingBatchSize, cfg.Storage.FlushingBatchTimeout, logger)
-	wal.Start(ctx)
-	service := server.NewServer(logger, parser, engine, wal.WALChannel, cfg)
+	walService := wal.NewWALService(storeChan, cfg.Storage.FlushingBatchSize, cfg.Storage.FlushingBatchTimeout, logger)
+	walService.Start(ctx)
+	service := server.NewServer(logger, parser, engine, walService.WALChannel, cfg)
+	// a persistent engine has the data already, the WAL is still read to
+	// restore the LSN of keyspace notifications
+	applied, err := wal.Recover(cfg.Storage.Path, service.Replay, logger)
+	if err != nil {
+		logger.Error("failed to recover from WAL: %w", err)
+		return
+	}
+	logger.Info("recovered %d records from WAL", applied)
 	service.Execute(ctx)
 
 	logger.Info("all services are stopped")
@@ -8,6 +8,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 	"syscall"
 	"time"
 
@@ -15,9 +16,13 @@ import (
 )
 
 func main() {
-	address := flag.String("address", "localhost:3223", "Address of the spider")
+	address := flag.String("address", "localhost:3223", "Address of the spider, host:port or unix:///path/to.sock")
 	idleTimeout := flag.Duration("idle_timeout", time.Minute, "Idle timeout for connection")
 	maxMessageSizeStr := flag.String("max_message_size", "4KB", "Max message size for connection")
+	reconnect := flag.Bool("reconnect", true, "Reconnect after the connection is lost")
+	reconnectAttempts := flag.Uint("reconnect_attempts", 5, "Reconnect attempts after the connection is lost (0 is unlimited)")
+	reconnectBackoff := flag.Duration("reconnect_backoff", 100*time.Millisecond, "Initial delay between reconnect attempts")
+	reconnectMaxBackoff := flag.Duration("reconnect_max_backoff", 5*time.Second, "Max delay between reconnect attempts")
 	flag.Parse()
 
 	logger, _ := zap.NewProduction()
@@ -29,6 +34,12 @@ func main() {
 	var options []network.TCPClientOption
 	options = append(options, network.WithClientIdleTimeout(*idleTimeout))
 	options = append(options, network.WithClientBufferSize(uint(maxMessageSize)))
+	if *reconnect {
+		options = append(options, network.WithReconnect(*reconnectAttempts, *reconnectBackoff, *reconnectMaxBackoff))
+		options = append(options, network.WithClientStateHandler(func(state network.ConnectionState) {
+			logger.Info("connection state changed", zap.Stringer("state", state))
+		}))
+	}
 
 	reader := bufio.NewReader(os.Stdin)
 	client, err := network.NewTCPClient(*address, options...)
@@ -46,12 +57,29 @@ func main() {
 		}
 
 		response, err := client.Send([]byte(request))
-		if errors.Is(err, syscall.EPIPE) {
+		if errors.Is(err, syscall.EPIPE) || errors.Is(err, network.ErrReconnectFailed) {
 			logger.Fatal("connection was closed", zap.Error(err))
 		} else if err != nil {
 			logger.Error("failed to send query", zap.Error(err))
+			continue
 		}
 
-		fmt.Println(string(response))
+		fmt.Println(strings.TrimSuffix(string(response), "\n"))
+		if isSubscription(request) && !strings.HasPrefix(string(response), "ERR ") {
+			// the connection is in push mode now, print messages until it is
+			// closed
+			for {
+				data, err := client.Receive()
+				if err != nil {
+					logger.Fatal("subscription was closed", zap.Error(err))
+				}
+				fmt.Print(string(data))
+			}
+		}
 	}
 }
+
+func isSubscription(request string) bool {
+	fields := strings.Fields(request)
+	return len(fields) != 0 && (fields[0] == "SUBSCRIBE" || fields[0] == "PSUBSCRIBE" || fields[0] == "WATCH")
+}
@@ -3,13 +3,18 @@ package main
 import (
 	"concurrency_hw1/internal/compute"
 	"concurrency_hw1/internal/config"
+	"concurrency_hw1/internal/gateway"
 	"concurrency_hw1/internal/server"
 	"concurrency_hw1/internal/storage"
 	"concurrency_hw1/internal/wal"
+	"concurrency_hw1/pkg/concurrency"
 	"concurrency_hw1/pkg/disk"
 	"concurrency_hw1/pkg/logger"
 	"flag"
+	"fmt"
+	"io"
 	"os"
+	"sync"
 	"time"
 
 	"context"
@@ -19,48 +24,71 @@ import (
 
 var ConfigFileName = os.Getenv("CONFIG_FILE_NAME")
 
+// flagPaths are the config settings overridden by the flags.
+var flagPaths = map[string]string{
+	"address":          "network.address",
+	"idle_timeout":     "network.idle_timeout",
+	"wal_path":         "wal.data_directory",
+	"max_connections":  "network.max_connections",
+	"max_message_size": "network.max_message_size",
+	"log_level":        "logging.level",
+}
+
+const (
+	defaultLogLevel        = "debug"
+	workersShutdownTimeout = 5 * time.Second
+	// the read-only mode is kept next to the WAL, the segment list skips it
+	readOnlyMarkerSuffix = ".readonly"
+)
+
 func main() {
-	logger := logger.New("debug", "local")
+	logger := logger.New(defaultLogLevel, "local")
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
-	address := flag.String("address", "127.0.0.1:3223", "Address of the server")
-	idleTimeoutStr := flag.String("idle_timeout", "5m", "Idle timeout for connection")
-	walPath := flag.String("wal_path", "./wal", "Path to write ahead log")
-	idleTimeout, err := time.ParseDuration(*idleTimeoutStr)
-	if err != nil {
-		logger.Fatal("failed to parse idle timeout", err)
-	}
-	maxConnections := flag.Int("max_connections", 100, "Max connections for server")
-	maxMessageSizeStr := flag.String("max_message_size", "4KB", "Max message size for connection")
+	defaults := config.Default()
+	flag.String("address", defaults.Network.Address, "Address of the server, host:port or unix:///path/to.sock")
+	flag.Duration("idle_timeout", defaults.Network.IdleTimeout, "Idle timeout for connection")
+	flag.String("wal_path", defaults.Storage.Path, "Path to write ahead log")
+	flag.Int("max_connections", defaults.Network.MaxConnections, "Max connections for server")
+	flag.String("max_message_size", defaults.Network.MaxMessageSize, "Max message size for connection")
+	flag.String("log_level", defaults.Logging.Level, "Log level: debug, info, warn or error")
+	readOnly := flag.Bool("readonly", false, "Start read-only, the mode is kept until READONLY no")
+	printConfig := flag.Bool("print-config", false, "Print the effective config and where every value came from, then exit")
 	flag.Parse()
 
 	if ConfigFileName == "" {
 		ConfigFileName = "../../config.yml"
 	}
 
-	cfg, err := config.Load(logger, ConfigFileName)
+	overrides := config.FlagOverrides(flag.CommandLine, flagPaths)
+	cfg, sources, err := config.Resolve(logger, ConfigFileName, overrides)
 	if err != nil {
-		logger.Info("failed to load config. working with default values")
-		cfg = &config.Config{Network: &config.NetworkConfig{}}
-		if address != nil {
-			cfg.Network.Address = *address
-		}
-		if maxConnections != nil {
-			cfg.Network.MaxConnections = *maxConnections
-		}
-		if maxMessageSizeStr != nil {
-			cfg.Network.MaxMessageSize = *maxMessageSizeStr
-		}
-		cfg.Network.IdleTimeout = idleTimeout
-		if walPath != nil {
-			cfg.Storage.Path = *walPath
+		logger.Fatal(fmt.Errorf("invalid config: %w", err))
+	}
+	if *printConfig {
+		if err := cfg.Print(os.Stdout, sources); err != nil {
+			logger.Fatal(err)
 		}
+		return
 	}
+	setLogLevel(logLevel(cfg))
 
 	parser := compute.NewParser()
-	engine := storage.NewEngine()
-	diskStorage, err := disk.NewDiskStorage(cfg.Storage.Path, cfg.Storage.MaxSegmentSize, logger)
+	engine, err := storage.New(cfg.Engine, logger)
+	if err != nil {
+		logger.Error("failed to create storage engine: %w", err)
+		return
+	}
+	if closer, ok := engine.(io.Closer); ok {
+		defer func() {
+			if err := closer.Close(); err != nil {
+				logger.Error("failed to close storage engine: %w", err)
+			}
+		}()
+	}
+	diskBreaker := concurrency.NewCircuitBreaker(diskBreakerOptions(cfg.Storage, logger)...)
+	diskStorage, err := disk.NewDiskStorage(cfg.Storage.Path, cfg.Storage.MaxSegmentSize, logger, disk.WithCircuitBreaker(diskBreaker))
 	if err != nil {
 		logger.Error("failed to create disk storage: %w", err)
 		return
@@ -72,10 +100,108 @@ func main() {
 		return
 	}
 
-	wal := wal.NewWALService(c, cfg.Storage.FlushingBatchSize, cfg.Storage.FlushingBatchTimeout, logger)
-	wal.Start(ctx)
-	service := server.NewServer(logger, parser, engine, wal.WALChannel, cfg)
+	walService := wal.NewWALService(c, cfg.Storage.FlushingBatchSize, cfg.Storage.FlushingBatchTimeout, logger)
+	walService.Start(ctx)
+	options := []server.ServerOption{
+		server.WithDiskBreaker(diskBreaker),
+		server.WithReadOnlyMarker(cfg.Storage.Path + readOnlyMarkerSuffix),
+		server.WithConfigFile(ConfigFileName),
+		server.WithWALBatcher(walService),
+	}
+	if cfg.Workers != nil && cfg.Workers.Size > 0 {
+		pool := concurrency.NewWorkerPool(
+			cfg.Workers.Size,
+			concurrency.WithMaxWorkers(cfg.Workers.MaxSize),
+			concurrency.WithQueueSize(cfg.Workers.QueueSize),
+			concurrency.WithWorkerIdleTimeout(cfg.Workers.IdleTimeout),
+			concurrency.WithPanicHandler(func(v any) {
+				logger.Error("captured panic in worker", v)
+			}),
+		)
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), workersShutdownTimeout)
+			defer cancel()
+			if err := pool.Shutdown(shutdownCtx); err != nil {
+				logger.Error("failed to stop workers: %w", err)
+			}
+		}()
+		options = append(options, server.WithWorkerPool(pool))
+	}
+	service := server.NewServer(logger, parser, engine, walService.WALChannel, cfg, options...)
+	// a persistent engine has the data already, the WAL is still read to
+	// restore the LSN of keyspace notifications
+	applied, err := wal.Recover(cfg.Storage.Path, service.Replay, logger)
+	if err != nil {
+		logger.Error("failed to recover from WAL: %w", err)
+		return
+	}
+	logger.Info("recovered %d records from WAL", applied)
+	if *readOnly {
+		if err := service.SetReadOnly(true); err != nil {
+			logger.Error("failed to switch to read-only mode: %w", err)
+			return
+		}
+	}
+
+	var wg sync.WaitGroup
+	if cfg.HTTP != nil && cfg.HTTP.Address != "" {
+		httpGateway, err := gateway.NewGateway(cfg.HTTP, service, logger)
+		if err != nil {
+			logger.Error("failed to create HTTP gateway: %w", err)
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := httpGateway.Execute(ctx); err != nil {
+				logger.Error(err)
+			}
+		}()
+	}
+	reload := &reloader{
+		fileName:  ConfigFileName,
+		overrides: overrides,
+		started:   cfg,
+		service:   service,
+		logger:    logger,
+	}
+	go reload.run(ctx)
+
 	service.Execute(ctx)
+	wg.Wait()
 
 	logger.Info("all services are stopped")
 }
+
+func diskBreakerOptions(cfg *config.StorageConfig, logger *logger.Logger) []concurrency.CircuitBreakerOption {
+	options := []concurrency.CircuitBreakerOption{
+		concurrency.WithStateChangeHandler(func(from, to concurrency.BreakerState) {
+			switch to {
+			case concurrency.BreakerOpen:
+				logger.Error("disk storage is failing, the server is read-only")
+			case concurrency.BreakerClosed:
+				logger.Info("disk storage recovered, the server is writable")
+			}
+		}),
+	}
+	if cfg != nil && cfg.CircuitBreaker != nil {
+		breakerCfg := cfg.CircuitBreaker
+		if breakerCfg.FailureThreshold > 0 {
+			options = append(options, concurrency.WithFailureThreshold(breakerCfg.FailureThreshold))
+		}
+		if breakerCfg.OpenTimeout > 0 {
+			options = append(options, concurrency.WithOpenTimeout(breakerCfg.OpenTimeout))
+		}
+		if breakerCfg.HalfOpenSuccesses > 0 {
+			options = append(options, concurrency.WithHalfOpenSuccesses(breakerCfg.HalfOpenSuccesses))
+		}
+	}
+	return options
+}
+
+// setLogLevel is here because main shadows the logger package.
+func setLogLevel(level string) {
+	if err := logger.SetLevel(level); err != nil {
+		fmt.Println(err)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"concurrency_hw1/internal/config"
+	"concurrency_hw1/internal/server"
+	"concurrency_hw1/pkg/logger"
+	"context"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+// reloader resolves the config again on SIGHUP and applies the settings
+// that can change live, replacing the values set by CONFIG SET. Settings
+// that need a restart are reported once until they change again.
+type reloader struct {
+	fileName string
+	// overrides are the flags, they keep overriding the file
+	overrides map[string]string
+	// started is the config the server was started with
+	started *config.Config
+	// reported are the values of the settings needing a restart that were
+	// reported last, as "path=value"
+	reported []string
+	service  *server.Server
+	logger   *logger.Logger
+}
+
+func (r *reloader) run(ctx context.Context) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			r.reload()
+		}
+	}
+}
+
+func (r *reloader) reload() {
+	cfg, _, err := config.Resolve(r.logger, r.fileName, r.overrides)
+	if err != nil {
+		r.logger.Error("config is invalid, nothing is reloaded: %v", err)
+		return
+	}
+
+	if err := r.service.ApplyConfig(cfg); err != nil {
+		r.logger.Error("failed to apply config: %v", err)
+		return
+	}
+	r.logger.Info("config reloaded from %s", r.fileName)
+
+	var restart, values []string
+	for _, path := range config.Changes(r.started, cfg) {
+		if !config.Reloadable(path) {
+			value, _ := cfg.Get(path)
+			restart = append(restart, path)
+			values = append(values, path+"="+value)
+		}
+	}
+	if slices.Equal(values, r.reported) {
+		return
+	}
+	r.reported = values
+	if len(restart) > 0 {
+		r.logger.Warn("changed settings that need a restart: %s", strings.Join(restart, ", "))
+	}
+}
+
+func logLevel(cfg *config.Config) string {
+	if cfg.Logging == nil || cfg.Logging.Level == "" {
+		return defaultLogLevel
+	}
+	return cfg.Logging.Level
+}
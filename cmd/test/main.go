@@ -1,44 +1,34 @@
 package main
 
 import (
+	"concurrency_hw1/pkg/concurrency"
 	"context"
-	"sync"
+	"log"
 	"time"
 )
 
-type KVDatabase interface {
-	Get(key string) (string, error)
-	Keys() ([]string, error)
-	MGet(keys []string) ([]*string, error)
-}
+// the read-through cache over a KVDatabase lives in pkg/cache
 
-type Cache struct {
-	mu   sync.RWMutex
-	data map[string]string
-}
+const longFunctionTimeout = 5 * time.Second
 
 func LongFunction() {
 	// This function is supposed to be long-running
 	// and should be optimized to be concurrent
 }
 
-func LongFunctionWrapper(ctx context.Context, funcToRun func()) {
-	// This function should run the LongFunction
-	// with a timeout of 5 seconds
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-			funcToRun()
-		}
-	}
+// LongFunctionWrapper runs funcToRun with a timeout of 5 seconds. A call
+// still running by then is left in the background and the wrapper returns
+// context.DeadlineExceeded.
+func LongFunctionWrapper(ctx context.Context, funcToRun func()) error {
+	_, err := concurrency.RunWithTimeout(ctx, longFunctionTimeout, func(context.Context) (struct{}, error) {
+		funcToRun()
+		return struct{}{}, nil
+	})
+	return err
 }
 
 func main() {
-	//run long function with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	LongFunctionWrapper(ctx, LongFunction)
-
+	if err := LongFunctionWrapper(context.Background(), LongFunction); err != nil {
+		log.Fatal(err)
+	}
 }
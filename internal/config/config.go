@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"time"
 
@@ -13,15 +14,97 @@ import (
 )
 
 type Config struct {
+	Engine  *EngineConfig  `yaml:"engine"`
 	Network *NetworkConfig `yaml:"network"`
 	Storage *StorageConfig `yaml:"wal"`
+	PubSub  *PubSubConfig  `yaml:"pubsub"`
+	HTTP    *HTTPConfig    `yaml:"http"`
+	Workers *WorkersConfig `yaml:"workers"`
+	Logging *LoggingConfig `yaml:"logging"`
+	SlowLog *SlowLogConfig `yaml:"slowlog"`
+}
+
+// SlowLogConfig keeps the last commands that ran longer than SlowerThan for
+// SLOWLOG, zero disables it.
+type SlowLogConfig struct {
+	SlowerThan time.Duration `yaml:"slower_than"`
+	MaxLen     int           `yaml:"max_len"`
+}
+
+type LoggingConfig struct {
+	// Level is "debug", "info" (default), "warn" or "error"
+	Level string `yaml:"level"`
+}
+
+type EngineConfig struct {
+	// Type is "hash" (default), "ordered" or "lsm"
+	Type string `yaml:"type"`
+	// the rest is used only by the lsm engine
+	DataDirectory       string `yaml:"data_directory"`
+	MemtableSize        string `yaml:"memtable_size"`
+	BlockSize           string `yaml:"block_size"`
+	BlockCacheSize      string `yaml:"block_cache_size"`
+	CompactionThreshold int    `yaml:"compaction_threshold"`
 }
 
 type NetworkConfig struct {
-	Address        string        `yaml:"address"`
+	// Address is "host:port" or a unix socket "unix:///path/to.sock"
+	Address string `yaml:"address"`
+	// ExtraAddresses are listened on as well, e.g. a unix socket for local
+	// clients next to the TCP address
+	ExtraAddresses []string `yaml:"extra_addresses"`
+	// UnixSocketMode is the octal file mode of unix sockets, "0660" if empty
+	UnixSocketMode string        `yaml:"unix_socket_mode"`
 	MaxConnections int           `yaml:"max_connections"`
 	MaxMessageSize string        `yaml:"max_message_size"`
 	IdleTimeout    time.Duration `yaml:"idle_timeout"`
+	// MaxRequestMemory bounds the bytes of requests handled at once, a
+	// request waits until it fits; empty is unlimited
+	MaxRequestMemory string           `yaml:"max_request_memory"`
+	RateLimit        *RateLimitConfig `yaml:"rate_limit"`
+	// CommandTimeout is how long a client waits for a command before it is
+	// answered with an error, CommandTimeouts overrides it by command name.
+	// Zero waits forever. Only reads are timed out, writes and commands
+	// changing the server always run to the end.
+	CommandTimeout  time.Duration            `yaml:"command_timeout"`
+	CommandTimeouts map[string]time.Duration `yaml:"command_timeouts"`
+}
+
+// RateLimitConfig limits requests of every connection and of all
+// connections of a client host together, a zero limit is unlimited.
+type RateLimitConfig struct {
+	// Policy is "reject" (default) to answer requests over the limit with an
+	// error or "delay" to hold them until they fit
+	Policy                   string  `yaml:"policy"`
+	ConnectionOpsPerSecond   float64 `yaml:"connection_ops_per_second"`
+	ConnectionBytesPerSecond string  `yaml:"connection_bytes_per_second"`
+	ClientOpsPerSecond       float64 `yaml:"client_ops_per_second"`
+	ClientBytesPerSecond     string  `yaml:"client_bytes_per_second"`
+}
+
+type PubSubConfig struct {
+	// SubscriberQueueSize is how many messages may wait for a subscriber
+	// before it is disconnected as too slow
+	SubscriberQueueSize int `yaml:"subscriber_queue_size"`
+	// KeyspaceHistorySize is how many key changes are kept for watchers
+	// resuming with WATCH ... SINCE
+	KeyspaceHistorySize int `yaml:"keyspace_history_size"`
+}
+
+// WorkersConfig sets up the worker pool commands run on, without it they
+// run on the goroutines of the connections.
+type WorkersConfig struct {
+	Size int `yaml:"size"`
+	// MaxSize above Size lets the pool grow under load
+	MaxSize     int           `yaml:"max_size"`
+	QueueSize   int           `yaml:"queue_size"`
+	IdleTimeout time.Duration `yaml:"idle_timeout"`
+}
+
+type HTTPConfig struct {
+	// Address of the HTTP gateway, it is disabled if empty
+	Address     string `yaml:"address"`
+	MaxBodySize string `yaml:"max_body_size"`
 }
 
 type StorageConfig struct {
@@ -29,33 +112,134 @@ type StorageConfig struct {
 	FlushingBatchTimeout time.Duration `yaml:"flushing_batch_timeout"`
 	MaxSegmentSize       string        `yaml:"max_segment_size"`
 	Path                 string        `yaml:"data_directory"`
+	// CircuitBreaker makes the server read-only while writing the WAL fails
+	CircuitBreaker *CircuitBreakerConfig `yaml:"circuit_breaker"`
+}
+
+type CircuitBreakerConfig struct {
+	// FailureThreshold is how many failed writes in a row open the breaker
+	FailureThreshold int `yaml:"failure_threshold"`
+	// OpenTimeout is how long to wait before probing the disk again
+	OpenTimeout time.Duration `yaml:"open_timeout"`
+	// HalfOpenSuccesses is how many probes must succeed to close it again
+	HalfOpenSuccesses int `yaml:"half_open_successes"`
 }
 
-func Load(log *logger.Logger, configFileName string) (*Config, error) {
-	if configFileName == "" {
-		return nil, errors.New("empty config file name")
+// Default returns the config used for the settings missing in every source.
+func Default() *Config {
+	return &Config{
+		Engine: &EngineConfig{
+			Type:                "hash",
+			DataDirectory:       "./data",
+			MemtableSize:        "4MB",
+			BlockSize:           "4KB",
+			BlockCacheSize:      "8MB",
+			CompactionThreshold: 4,
+		},
+		Network: &NetworkConfig{
+			Address:        "127.0.0.1:3223",
+			UnixSocketMode: "0660",
+			MaxConnections: 100,
+			MaxMessageSize: "4KB",
+			IdleTimeout:    5 * time.Minute,
+			RateLimit:      &RateLimitConfig{Policy: "reject"},
+		},
+		Storage: &StorageConfig{
+			FlushingBatchSize:    100,
+			FlushingBatchTimeout: 10 * time.Millisecond,
+			MaxSegmentSize:       "1KB",
+			Path:                 "./wal",
+			CircuitBreaker: &CircuitBreakerConfig{
+				FailureThreshold:  3,
+				OpenTimeout:       5 * time.Second,
+				HalfOpenSuccesses: 1,
+			},
+		},
+		PubSub: &PubSubConfig{
+			SubscriberQueueSize: 1024,
+			KeyspaceHistorySize: 10000,
+		},
+		HTTP: &HTTPConfig{
+			MaxBodySize: "1MB",
+		},
+		Workers: &WorkersConfig{
+			QueueSize:   1024,
+			IdleTimeout: time.Minute,
+		},
+		Logging: &LoggingConfig{
+			Level: "debug",
+		},
+		SlowLog: &SlowLogConfig{
+			SlowerThan: 10 * time.Millisecond,
+			MaxLen:     128,
+		},
 	}
-	dataCfg, err := os.ReadFile(configFileName)
-	if err != nil {
-		log.Error(err)
-		return nil, errors.New("failed to read config file")
+}
+
+// Resolve builds the config from the defaults, the YAML file, the SPIDER_*
+// environment variables and the overrides of flags by config path, each
+// source overriding the previous ones. A missing file is skipped. The
+// config is validated and every problem found is returned at once.
+func Resolve(log *logger.Logger, fileName string, overrides map[string]string) (*Config, Sources, error) {
+	cfg := Default()
+	sources := make(Sources)
+	for _, path := range cfg.Paths() {
+		sources[path] = SourceDefault
 	}
 
-	reader := bytes.NewReader(dataCfg)
+	if err := cfg.loadFile(fileName, sources); errors.Is(err, fs.ErrNotExist) {
+		log.Warn("config file %s not found, using defaults", fileName)
+	} else if err != nil {
+		return nil, nil, err
+	}
 
-	if reader == nil {
-		return nil, errors.New("incorrect reader")
+	var errs []error
+	for _, path := range cfg.Paths() {
+		if value, ok := os.LookupEnv(EnvName(path)); ok {
+			if err := cfg.Set(path, value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", EnvName(path), err))
+				continue
+			}
+			sources[path] = SourceEnv
+		}
 	}
+	for path, value := range overrides {
+		if err := cfg.Set(path, value); err != nil {
+			errs = append(errs, fmt.Errorf("flag for %s: %w", path, err))
+			continue
+		}
+		sources[path] = SourceFlag
+	}
+	if err := errors.Join(append(errs, cfg.Validate())...); err != nil {
+		return nil, nil, err
+	}
+	return cfg, sources, nil
+}
 
-	data, err := io.ReadAll(reader)
+// loadFile reads the YAML file over the config, unknown settings are errors.
+func (c *Config) loadFile(fileName string, sources Sources) error {
+	if fileName == "" {
+		return fs.ErrNotExist
+	}
+	data, err := os.ReadFile(fileName)
 	if err != nil {
-		return nil, errors.New("falied to read buffer")
+		return fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse config: %w", err)
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config: %w", err)
 	}
 
-	return &config, nil
+	var document map[string]any
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return fmt.Errorf("failed to parse config: %w", err)
+	}
+	for _, path := range c.Paths() {
+		if present(document, path) {
+			sources[path] = SourceFile
+		}
+	}
+	return nil
 }
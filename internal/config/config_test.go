@@ -0,0 +1,59 @@
+package config_test
+
+import (
+	"concurrency_hw1/internal/config"
+	"concurrency_hw1/pkg/logger"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestResolve(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "config.yml")
+	data := "network:\n  address: \"127.0.0.1:4000\"\n  max_connections: 10\n  idle_timeout: 1m\nwal:\n  data_directory: \"/tmp/wal\"\n"
+	if err := os.WriteFile(fileName, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SPIDER_NETWORK_MAX_CONNECTIONS", "20")
+	t.Setenv("SPIDER_NETWORK_IDLE_TIMEOUT", "2m")
+
+	cfg, sources, err := config.Resolve(logger.New("error", ""), fileName, map[string]string{"network.idle_timeout": "3m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path       string
+		got        any
+		want       any
+		wantSource config.Source
+	}{
+		{path: "network.max_message_size", got: cfg.Network.MaxMessageSize, want: "4KB", wantSource: config.SourceDefault},
+		{path: "network.address", got: cfg.Network.Address, want: "127.0.0.1:4000", wantSource: config.SourceFile},
+		{path: "wal.data_directory", got: cfg.Storage.Path, want: "/tmp/wal", wantSource: config.SourceFile},
+		{path: "network.max_connections", got: cfg.Network.MaxConnections, want: 20, wantSource: config.SourceEnv},
+		{path: "network.idle_timeout", got: cfg.Network.IdleTimeout, want: 3 * time.Minute, wantSource: config.SourceFlag},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %v, want %v", tt.got, tt.want)
+			}
+			if sources[tt.path] != tt.wantSource {
+				t.Errorf("got source %s, want %s", sources[tt.path], tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestResolveUnknownSetting(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(fileName, []byte("network:\n  adress: \"127.0.0.1:4000\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := config.Resolve(logger.New("error", ""), fileName, nil); err == nil {
+		t.Error("got no error for a misspelled setting")
+	}
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+// reloadable are the settings applied by a reload or CONFIG SET, the others
+// take effect after a restart.
+var reloadable = map[string]bool{
+	"network.idle_timeout":       true,
+	"network.max_connections":    true,
+	"network.max_request_memory": true,
+	"wal.flushing_batch_size":    true,
+	"wal.flushing_batch_timeout": true,
+	"logging.level":              true,
+	"slowlog.slower_than":        true,
+	"slowlog.max_len":            true,
+}
+
+// Reloadable reports whether the setting at path, as returned by Changes,
+// can be applied without a restart.
+func Reloadable(path string) bool {
+	return reloadable[path]
+}
+
+// Changes returns the paths of the settings that differ between the two
+// configs, named as in the YAML, e.g. "network.idle_timeout". A missing
+// section is the same as an empty one.
+func Changes(old, new *Config) []string {
+	var changes []string
+	diff(reflect.ValueOf(old), reflect.ValueOf(new), "", &changes)
+	return changes
+}
+
+func diff(old, new reflect.Value, path string, changes *[]string) {
+	if old.Kind() == reflect.Pointer {
+		old, new = deref(old), deref(new)
+	}
+	if old.Kind() != reflect.Struct {
+		if !reflect.DeepEqual(old.Interface(), new.Interface()) {
+			*changes = append(*changes, path)
+		}
+		return
+	}
+
+	for i := 0; i < old.NumField(); i++ {
+		field := old.Type().Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		if path != "" {
+			name = path + "." + name
+		}
+		diff(old.Field(i), new.Field(i), name, changes)
+	}
+}
+
+func deref(value reflect.Value) reflect.Value {
+	if value.IsNil() {
+		return reflect.Zero(value.Type().Elem())
+	}
+	return value.Elem()
+}
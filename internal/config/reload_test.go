@@ -0,0 +1,57 @@
+package config_test
+
+import (
+	"concurrency_hw1/internal/config"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChanges(t *testing.T) {
+	old := &config.Config{
+		Network: &config.NetworkConfig{Address: "127.0.0.1:3223", IdleTimeout: time.Minute},
+		Storage: &config.StorageConfig{FlushingBatchSize: 100},
+	}
+	new := &config.Config{
+		Network: &config.NetworkConfig{Address: "127.0.0.1:3224", IdleTimeout: time.Minute, ExtraAddresses: []string{"unix:///tmp/s.sock"}},
+		Storage: &config.StorageConfig{FlushingBatchSize: 10},
+		Logging: &config.LoggingConfig{Level: "info"},
+	}
+
+	got := config.Changes(old, new)
+	want := []string{"network.address", "network.extra_addresses", "wal.flushing_batch_size", "logging.level"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got changes %v, want %v", got, want)
+	}
+	if config.Reloadable("network.address") || !config.Reloadable("wal.flushing_batch_size") {
+		t.Error("network.address must need a restart and wal.flushing_batch_size must not")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg := &config.Config{
+		Network: &config.NetworkConfig{Address: "127.0.0.1:3223", MaxConnections: -1, MaxMessageSize: "4 apples"},
+		Storage: &config.StorageConfig{Path: "./wal", FlushingBatchTimeout: 10 * time.Millisecond},
+		Logging: &config.LoggingConfig{Level: "loud"},
+		Workers: &config.WorkersConfig{Size: 4, MaxSize: 2},
+	}
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("got no error for an invalid config")
+	}
+	for _, want := range []string{"network.max_connections", "network.max_message_size", "logging.level", "workers.max_size"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+
+	cfg.Network.MaxConnections = 10
+	cfg.Network.MaxMessageSize = "4KB"
+	cfg.Logging.Level = "warn"
+	cfg.Workers.MaxSize = 4
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("got %v for a valid config", err)
+	}
+}
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Clone returns a deep copy of the config.
+func (c *Config) Clone() *Config {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		panic(fmt.Sprintf("config cannot be marshaled: %v", err))
+	}
+	clone := &Config{}
+	if err := yaml.Unmarshal(data, clone); err != nil {
+		panic(fmt.Sprintf("config cannot be unmarshaled: %v", err))
+	}
+	return clone
+}
+
+// Rewrite writes the settings of cfg that differ from resolved, the config
+// as it was resolved from the file, the environment and the flags, into the
+// file. So only the settings changed at runtime are written, an override of
+// the environment or a flag is not made permanent. The other settings, the
+// comments and the order of the file are kept, missing sections are added
+// at the end. The file is replaced at once, a failed rewrite leaves it as
+// it was.
+func Rewrite(fileName string, cfg, resolved *Config) error {
+	var document yaml.Node
+	data, err := os.ReadFile(fileName)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return fmt.Errorf("failed to parse config: %w", err)
+	}
+	if document.Kind == 0 {
+		document = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return errors.New("failed to parse config: not a mapping")
+	}
+
+	cfg, resolved = cfg.Clone(), resolved.Clone()
+	for _, path := range cfg.Paths() {
+		// compared as formatted, so a nil list or map equals an empty one
+		value, _ := cfg.Get(path)
+		if old, _ := resolved.Get(path); value == old {
+			continue
+		}
+		if err := setNode(root, strings.Split(path, "."), cfg.field(path).Interface()); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	var buffer bytes.Buffer
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&document); err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+	return writeFile(fileName, buffer.Bytes())
+}
+
+// setNode sets the value under the keys of the mapping, the missing keys
+// are appended. The comments and the quoting of the old value are kept.
+func setNode(mapping *yaml.Node, keys []string, value any) error {
+	for i := 0; i < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value != keys[0] {
+			continue
+		}
+		node := mapping.Content[i+1]
+		if len(keys) > 1 {
+			if node.Kind != yaml.MappingNode {
+				*node = yaml.Node{Kind: yaml.MappingNode, HeadComment: node.HeadComment, LineComment: node.LineComment}
+			}
+			return setNode(node, keys[1:], value)
+		}
+
+		var encoded yaml.Node
+		if err := encoded.Encode(value); err != nil {
+			return err
+		}
+		if node.Kind == yaml.ScalarNode && encoded.Kind == yaml.ScalarNode && encoded.Tag == "!!str" {
+			encoded.Style = node.Style
+		}
+		encoded.HeadComment, encoded.LineComment, encoded.FootComment = node.HeadComment, node.LineComment, node.FootComment
+		*node = encoded
+		return nil
+	}
+
+	key := &yaml.Node{Kind: yaml.ScalarNode, Value: keys[0]}
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	mapping.Content = append(mapping.Content, key, node)
+	if len(keys) > 1 {
+		return setNode(node, keys[1:], value)
+	}
+	return node.Encode(value)
+}
+
+// writeFile replaces the file through a temporary one in the same
+// directory, keeping the mode of the old file.
+func writeFile(fileName string, data []byte) error {
+	mode := fs.FileMode(0644)
+	if info, err := os.Stat(fileName); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(fileName), filepath.Base(fileName)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), mode)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), fileName)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package config_test
+
+import (
+	"concurrency_hw1/internal/config"
+	"concurrency_hw1/pkg/logger"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetGet(t *testing.T) {
+	tests := []struct {
+		path    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{path: "network.idle_timeout", value: "90s", want: "1m30s"},
+		{path: "network.max_connections", value: "7", want: "7"},
+		{path: "network.max_request_memory", value: "32MB", want: "32MB"},
+		{path: "network.extra_addresses", value: "a, b", want: "a,b"},
+		{path: "network.command_timeouts", value: "{keys: 30s}", want: "{keys: 30s}"},
+		{path: "network.max_connections", value: "many", wantErr: true},
+		{path: "network.idle_timeout", value: "soon", wantErr: true},
+		{path: "network.adress", value: "x", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path+"="+tt.value, func(t *testing.T) {
+			cfg := config.Default()
+			err := cfg.Set(tt.path, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got, _ := cfg.Get(tt.path); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "config.yml")
+	data := "network:\n  # connections at once\n  max_connections: 10\n  max_message_size: \"4KB\"\nwal:\n  data_directory: \"/tmp/wal\"\n"
+	if err := os.WriteFile(fileName, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// overrides of the environment and the flags are not written
+	t.Setenv(config.EnvName("network.idle_timeout"), "1m")
+	overrides := map[string]string{"network.address": "127.0.0.1:7000"}
+	log := logger.New("error", "")
+	cfg, _, err := config.Resolve(log, fileName, overrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolved := cfg.Clone()
+	for path, value := range map[string]string{"network.max_connections": "20", "logging.level": "warn"} {
+		if err := cfg.Set(path, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := config.Rewrite(fileName, cfg, resolved); err != nil {
+		t.Fatal(err)
+	}
+
+	rewritten, _, err := config.Resolve(log, fileName, overrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes := config.Changes(cfg, rewritten); len(changes) != 0 {
+		t.Errorf("got changed settings %v after rewrite", changes)
+	}
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"# connections at once", "max_message_size: \"4KB\"", "level: warn"} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("rewritten file misses %q:\n%s", want, content)
+		}
+	}
+	for _, unwanted := range []string{"engine:", "address:", "idle_timeout:"} {
+		if strings.Contains(string(content), unwanted) {
+			t.Errorf("rewritten file got %q, not changed at runtime:\n%s", unwanted, content)
+		}
+	}
+}
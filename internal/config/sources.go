@@ -0,0 +1,172 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const envPrefix = "SPIDER_"
+
+// Source tells where the value of a setting came from.
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceFile    Source = "file"
+	SourceEnv     Source = "env"
+	SourceFlag    Source = "flag"
+)
+
+// Sources are the sources of the settings by config path, e.g.
+// "network.address".
+type Sources map[string]Source
+
+// EnvName returns the environment variable of the setting at path, e.g.
+// SPIDER_NETWORK_ADDRESS for "network.address".
+func EnvName(path string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(path, ".", "_"))
+}
+
+// FlagOverrides returns the values of the flags set on the command line by
+// the config paths they override, flags maps flag names to paths. Flags
+// left at their defaults do not override anything.
+func FlagOverrides(flags *flag.FlagSet, paths map[string]string) map[string]string {
+	overrides := make(map[string]string)
+	flags.Visit(func(f *flag.Flag) {
+		if path, ok := paths[f.Name]; ok {
+			overrides[path] = f.Value.String()
+		}
+	})
+	return overrides
+}
+
+// Print writes every setting with its value and source.
+func (c *Config) Print(w io.Writer, sources Sources) error {
+	for _, path := range c.Paths() {
+		value, _ := c.Get(path)
+		if _, err := fmt.Fprintf(w, "%s = %s (%s)\n", path, value, sources[path]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Paths returns the paths of all the settings in the order of the structs,
+// missing sections are created.
+func (c *Config) Paths() []string {
+	var paths []string
+	walk(reflect.ValueOf(c).Elem(), "", func(path string, _ reflect.Value) {
+		paths = append(paths, path)
+	})
+	return paths
+}
+
+func walk(value reflect.Value, path string, visit func(path string, field reflect.Value)) {
+	for i := 0; i < value.NumField(); i++ {
+		name, _, _ := strings.Cut(value.Type().Field(i).Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		if path != "" {
+			name = path + "." + name
+		}
+
+		field := value.Field(i)
+		if field.Kind() == reflect.Pointer && field.Type().Elem().Kind() == reflect.Struct {
+			if field.IsNil() {
+				field.Set(reflect.New(field.Type().Elem()))
+			}
+			walk(field.Elem(), name, visit)
+			continue
+		}
+		visit(name, field)
+	}
+}
+
+// field returns the setting at path, it must exist.
+func (c *Config) field(path string) reflect.Value {
+	var found reflect.Value
+	walk(reflect.ValueOf(c).Elem(), "", func(p string, field reflect.Value) {
+		if p == path {
+			found = field
+		}
+	})
+	return found
+}
+
+// Get returns the setting at path formatted the way Set parses it.
+func (c *Config) Get(path string) (string, bool) {
+	field := c.field(path)
+	if !field.IsValid() {
+		return "", false
+	}
+
+	switch value := field.Interface().(type) {
+	case string:
+		return value, true
+	case []string:
+		return strings.Join(value, ","), true
+	case map[string]time.Duration:
+		items := make([]string, 0, len(value))
+		for key, timeout := range value {
+			items = append(items, key+": "+timeout.String())
+		}
+		sort.Strings(items)
+		return "{" + strings.Join(items, ", ") + "}", true
+	default:
+		return fmt.Sprint(value), true
+	}
+}
+
+// Set parses value into the setting at path. Strings are taken as they are,
+// lists are separated by commas and the rest is parsed as YAML, so
+// durations are written as "5m" and maps as "{keys: 30s}". Sizes are kept
+// as strings and checked by Validate.
+func (c *Config) Set(path, value string) error {
+	field := c.field(path)
+	if !field.IsValid() {
+		return fmt.Errorf("unknown setting %s", path)
+	}
+
+	switch {
+	case field.Kind() == reflect.String:
+		field.SetString(value)
+	case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		parsed := reflect.New(field.Type())
+		if err := yaml.Unmarshal([]byte(value), parsed.Interface()); err != nil {
+			return fmt.Errorf("cannot parse %q as %s", value, field.Type())
+		}
+		field.Set(parsed.Elem())
+	}
+	return nil
+}
+
+// present reports whether the YAML document sets the setting at path.
+func present(document map[string]any, path string) bool {
+	var node any = document
+	for _, key := range strings.Split(path, ".") {
+		section, ok := node.(map[string]any)
+		if !ok {
+			return false
+		}
+		if node, ok = section[key]; !ok {
+			return false
+		}
+	}
+	return true
+}
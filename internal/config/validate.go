@@ -0,0 +1,126 @@
+package config
+
+import (
+	"concurrency_hw1/pkg/common"
+	"concurrency_hw1/pkg/logger"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+var (
+	engineTypes     = []string{"hash", "ordered", "lsm"}
+	rateLimitPolicy = []string{"reject", "delay"}
+)
+
+// Validate checks the values that cannot be checked by parsing the YAML and
+// returns all the problems at once.
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	if c.Engine != nil {
+		v.oneOf("engine.type", c.Engine.Type, engineTypes)
+		v.size("engine.memtable_size", c.Engine.MemtableSize)
+		v.size("engine.block_size", c.Engine.BlockSize)
+		v.size("engine.block_cache_size", c.Engine.BlockCacheSize)
+		v.check(c.Engine.CompactionThreshold >= 0, "engine.compaction_threshold: must not be negative")
+	}
+
+	if c.Network == nil {
+		v.errs = append(v.errs, errors.New("network: section is missing"))
+	} else {
+		v.check(c.Network.Address != "", "network.address: must not be empty")
+		v.check(c.Network.MaxConnections >= 0, "network.max_connections: must not be negative")
+		v.check(c.Network.IdleTimeout >= 0, "network.idle_timeout: must not be negative")
+		v.check(c.Network.CommandTimeout >= 0, "network.command_timeout: must not be negative")
+		for command, timeout := range c.Network.CommandTimeouts {
+			v.check(timeout >= 0, "network.command_timeouts.%s: must not be negative", command)
+		}
+		v.size("network.max_message_size", c.Network.MaxMessageSize)
+		v.size("network.max_request_memory", c.Network.MaxRequestMemory)
+		if mode := c.Network.UnixSocketMode; mode != "" {
+			_, err := strconv.ParseUint(mode, 8, 32)
+			v.check(err == nil, "network.unix_socket_mode: %q is not an octal file mode", mode)
+		}
+		if limit := c.Network.RateLimit; limit != nil {
+			v.oneOf("network.rate_limit.policy", limit.Policy, rateLimitPolicy)
+			v.check(limit.ConnectionOpsPerSecond >= 0, "network.rate_limit.connection_ops_per_second: must not be negative")
+			v.check(limit.ClientOpsPerSecond >= 0, "network.rate_limit.client_ops_per_second: must not be negative")
+			v.size("network.rate_limit.connection_bytes_per_second", limit.ConnectionBytesPerSecond)
+			v.size("network.rate_limit.client_bytes_per_second", limit.ClientBytesPerSecond)
+		}
+	}
+
+	if c.Storage == nil {
+		v.errs = append(v.errs, errors.New("wal: section is missing"))
+	} else {
+		v.check(c.Storage.Path != "", "wal.data_directory: must not be empty")
+		v.check(c.Storage.FlushingBatchSize >= 0, "wal.flushing_batch_size: must not be negative")
+		v.check(c.Storage.FlushingBatchTimeout > 0, "wal.flushing_batch_timeout: must be positive")
+		v.size("wal.max_segment_size", c.Storage.MaxSegmentSize)
+		if breaker := c.Storage.CircuitBreaker; breaker != nil {
+			v.check(breaker.FailureThreshold >= 0, "wal.circuit_breaker.failure_threshold: must not be negative")
+			v.check(breaker.OpenTimeout >= 0, "wal.circuit_breaker.open_timeout: must not be negative")
+			v.check(breaker.HalfOpenSuccesses >= 0, "wal.circuit_breaker.half_open_successes: must not be negative")
+		}
+	}
+
+	if c.PubSub != nil {
+		v.check(c.PubSub.SubscriberQueueSize >= 0, "pubsub.subscriber_queue_size: must not be negative")
+		v.check(c.PubSub.KeyspaceHistorySize >= 0, "pubsub.keyspace_history_size: must not be negative")
+	}
+	if c.HTTP != nil {
+		v.size("http.max_body_size", c.HTTP.MaxBodySize)
+	}
+	if c.Workers != nil {
+		v.check(c.Workers.Size >= 0, "workers.size: must not be negative")
+		v.check(c.Workers.MaxSize >= 0, "workers.max_size: must not be negative")
+		v.check(c.Workers.MaxSize == 0 || c.Workers.MaxSize >= c.Workers.Size, "workers.max_size: must not be below workers.size")
+		v.check(c.Workers.QueueSize >= 0, "workers.queue_size: must not be negative")
+		v.check(c.Workers.IdleTimeout >= 0, "workers.idle_timeout: must not be negative")
+	}
+	if c.SlowLog != nil {
+		v.check(c.SlowLog.SlowerThan >= 0, "slowlog.slower_than: must not be negative")
+		v.check(c.SlowLog.MaxLen >= 0, "slowlog.max_len: must not be negative")
+	}
+	if c.Logging != nil && c.Logging.Level != "" {
+		if err := logger.ValidateLevel(c.Logging.Level); err != nil {
+			v.errs = append(v.errs, fmt.Errorf("logging.level: %w", err))
+		}
+	}
+
+	return errors.Join(v.errs...)
+}
+
+// validator collects the problems of a config.
+type validator struct {
+	errs []error
+}
+
+func (v *validator) check(ok bool, format string, args ...any) {
+	if !ok {
+		v.errs = append(v.errs, fmt.Errorf(format, args...))
+	}
+}
+
+// size checks a size like "4KB", empty is allowed.
+func (v *validator) size(name, value string) {
+	if value == "" {
+		return
+	}
+	_, err := common.ParseSize(value)
+	v.check(err == nil, "%s: %q is not a size", name, value)
+}
+
+// oneOf checks a value against the allowed ones, empty is allowed.
+func (v *validator) oneOf(name, value string, allowed []string) {
+	if value == "" {
+		return
+	}
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.errs = append(v.errs, fmt.Errorf("%s: %q is not one of %v", name, value, allowed))
+}
@@ -0,0 +1,369 @@
+package gateway
+
+import (
+	"concurrency_hw1/internal/config"
+	"concurrency_hw1/pkg/common"
+	"concurrency_hw1/pkg/logger"
+	"concurrency_hw1/pkg/network"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	errorPrefix = "ERR "
+	// missingValue is how the text protocol answers a missing key
+	missingValue = " "
+
+	defaultMaxBodySize = 1 << 20
+	shutdownTimeout    = 5 * time.Second
+)
+
+var errInvalidArgument = errors.New("arguments must be non-empty and must not contain whitespace")
+
+// Dispatcher runs a command through the command table of the server, the
+// same way as a request of the TCP protocol.
+type Dispatcher interface {
+	Dispatch(ctx context.Context, command string, args []string) string
+}
+
+// Gateway serves the commands of the server over HTTP with JSON bodies, for
+// clients that cannot speak the TCP protocol. Every endpoint is translated
+// to commands of the text protocol, so writes are logged to the WAL like any
+// other request.
+type Gateway struct {
+	listener    net.Listener
+	server      *http.Server
+	dispatcher  Dispatcher
+	maxBodySize int64
+	logger      logger.LoggerInterface
+}
+
+func NewGateway(cfg *config.HTTPConfig, dispatcher Dispatcher, logger logger.LoggerInterface) (*Gateway, error) {
+	if cfg == nil || cfg.Address == "" {
+		return nil, errors.New("empty HTTP address")
+	}
+
+	maxBodySize := defaultMaxBodySize
+	if cfg.MaxBodySize != "" {
+		size, err := common.ParseSize(cfg.MaxBodySize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse max body size: %w", err)
+		}
+		maxBodySize = size
+	}
+
+	listener, err := net.Listen("tcp", cfg.Address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen: %w", err)
+	}
+
+	g := &Gateway{
+		listener:    listener,
+		dispatcher:  dispatcher,
+		maxBodySize: int64(maxBodySize),
+		logger:      logger,
+	}
+	g.server = &http.Server{Handler: g.routes()}
+	return g, nil
+}
+
+// Addr returns the address the gateway listens on.
+func (g *Gateway) Addr() net.Addr {
+	return g.listener.Addr()
+}
+
+// Execute serves requests until ctx is done, then waits for the running
+// ones to finish.
+func (g *Gateway) Execute(ctx context.Context) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- g.server.Serve(g.listener)
+	}()
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("failed to serve HTTP: %w", err)
+	case <-ctx.Done():
+	}
+
+	g.logger.Info("stopping HTTP gateway")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := g.server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to stop HTTP gateway: %w", err)
+	}
+	return nil
+}
+
+func (g *Gateway) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /keys/{key...}", g.handleGet)
+	mux.HandleFunc("PUT /keys/{key...}", g.handlePut)
+	mux.HandleFunc("DELETE /keys/{key...}", g.handleDelete)
+	mux.HandleFunc("GET /keys", g.handleKeys)
+	mux.HandleFunc("POST /batch", g.handleBatch)
+	mux.HandleFunc("POST /command", g.handleCommand)
+	return withRemoteAddr(mux)
+}
+
+// withRemoteAddr tells the server which client sent the commands.
+func withRemoteAddr(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(network.WithRemoteAddr(r.Context(), r.RemoteAddr)))
+	})
+}
+
+type valueBody struct {
+	Value string `json:"value"`
+}
+
+type keyValue struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type deleteResult struct {
+	Key     string `json:"key"`
+	Deleted bool   `json:"deleted"`
+}
+
+type keysResult struct {
+	Keys []string `json:"keys"`
+}
+
+type commandBody struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+}
+
+type commandResult struct {
+	Response string `json:"response"`
+}
+
+// batchOperation is one step of POST /batch, Op is "get", "set" or
+// "delete".
+type batchOperation struct {
+	Op    string `json:"op"`
+	Key   string `json:"key"`
+	Value string `json:"value,omitempty"`
+}
+
+// batchResult tells whether a key was found by get, written by set or
+// removed by delete.
+type batchResult struct {
+	Key   string  `json:"key"`
+	Value *string `json:"value,omitempty"`
+	OK    bool    `json:"ok"`
+	Error string  `json:"error,omitempty"`
+}
+
+type errorResult struct {
+	Error string `json:"error"`
+}
+
+func (g *Gateway) handleGet(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	value, found, err := g.get(r.Context(), key)
+	if err != nil {
+		g.writeError(w, err)
+		return
+	}
+	if !found {
+		g.writeJSON(w, http.StatusNotFound, errorResult{Error: "key not found"})
+		return
+	}
+	g.writeJSON(w, http.StatusOK, keyValue{Key: key, Value: value})
+}
+
+func (g *Gateway) handlePut(w http.ResponseWriter, r *http.Request) {
+	var body valueBody
+	if !g.readJSON(w, r, &body) {
+		return
+	}
+
+	key := r.PathValue("key")
+	if err := g.set(r.Context(), key, body.Value); err != nil {
+		g.writeError(w, err)
+		return
+	}
+	g.writeJSON(w, http.StatusOK, keyValue{Key: key, Value: body.Value})
+}
+
+func (g *Gateway) handleDelete(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	deleted, err := g.delete(r.Context(), key)
+	if err != nil {
+		g.writeError(w, err)
+		return
+	}
+	g.writeJSON(w, http.StatusOK, deleteResult{Key: key, Deleted: deleted})
+}
+
+// handleKeys lists the keys with the prefix given by the query, all keys
+// without it.
+func (g *Gateway) handleKeys(w http.ResponseWriter, r *http.Request) {
+	pattern := escapePattern(r.URL.Query().Get("prefix")) + "*"
+	response, err := g.dispatch(r.Context(), "KEYS", pattern)
+	if err != nil {
+		g.writeError(w, err)
+		return
+	}
+
+	keys := []string{}
+	if response != missingValue {
+		keys = strings.Split(response, "\n")
+	}
+	sort.Strings(keys)
+	g.writeJSON(w, http.StatusOK, keysResult{Keys: keys})
+}
+
+// handleBatch runs the operations in order and answers with a result for
+// each of them. The batch is not atomic, a failed operation does not stop
+// the rest.
+func (g *Gateway) handleBatch(w http.ResponseWriter, r *http.Request) {
+	var operations []batchOperation
+	if !g.readJSON(w, r, &operations) {
+		return
+	}
+
+	results := make([]batchResult, len(operations))
+	for i, operation := range operations {
+		results[i] = g.runOperation(r.Context(), operation)
+	}
+	g.writeJSON(w, http.StatusOK, results)
+}
+
+// handleCommand runs any command of the text protocol and answers with its
+// raw response.
+func (g *Gateway) handleCommand(w http.ResponseWriter, r *http.Request) {
+	var body commandBody
+	if !g.readJSON(w, r, &body) {
+		return
+	}
+	if body.Command == "" {
+		g.writeJSON(w, http.StatusBadRequest, errorResult{Error: "empty command"})
+		return
+	}
+
+	response, err := g.dispatch(r.Context(), body.Command, body.Args...)
+	if err != nil {
+		g.writeError(w, err)
+		return
+	}
+	g.writeJSON(w, http.StatusOK, commandResult{Response: response})
+}
+
+func (g *Gateway) runOperation(ctx context.Context, operation batchOperation) batchResult {
+	result := batchResult{Key: operation.Key}
+
+	var err error
+	switch operation.Op {
+	case "get":
+		var value string
+		value, result.OK, err = g.get(ctx, operation.Key)
+		if result.OK {
+			result.Value = &value
+		}
+	case "set":
+		err = g.set(ctx, operation.Key, operation.Value)
+		result.OK = err == nil
+	case "delete":
+		result.OK, err = g.delete(ctx, operation.Key)
+	default:
+		err = fmt.Errorf("unknown operation %q", operation.Op)
+	}
+
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func (g *Gateway) get(ctx context.Context, key string) (string, bool, error) {
+	response, err := g.dispatch(ctx, "GET", key)
+	if err != nil {
+		return "", false, err
+	}
+	if response == missingValue {
+		return "", false, nil
+	}
+	return response, true, nil
+}
+
+func (g *Gateway) set(ctx context.Context, key, value string) error {
+	_, err := g.dispatch(ctx, "SET", key, value)
+	return err
+}
+
+// delete uses MDEL because it answers how many keys were removed.
+func (g *Gateway) delete(ctx context.Context, key string) (bool, error) {
+	response, err := g.dispatch(ctx, "MDEL", key)
+	if err != nil {
+		return false, err
+	}
+	return response != "0", nil
+}
+
+// dispatch checks the arguments like the TCP protocol would split them and
+// turns error responses into errors.
+func (g *Gateway) dispatch(ctx context.Context, command string, args ...string) (string, error) {
+	for _, arg := range append([]string{command}, args...) {
+		if arg == "" || strings.ContainsAny(arg, " \t\r\n") {
+			return "", fmt.Errorf("%w: %q", errInvalidArgument, arg)
+		}
+	}
+
+	response := g.dispatcher.Dispatch(ctx, command, args)
+	if message, ok := strings.CutPrefix(response, errorPrefix); ok {
+		return "", errors.New(message)
+	}
+	return response, nil
+}
+
+func (g *Gateway) readJSON(w http.ResponseWriter, r *http.Request, dst any) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, g.maxBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dst); err != nil {
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		g.writeJSON(w, status, errorResult{Error: "invalid body: " + err.Error()})
+		return false
+	}
+	return true
+}
+
+// writeError answers errors of the server with 400, they are caused by the
+// request, e.g. a wrong type or a syntax error.
+func (g *Gateway) writeError(w http.ResponseWriter, err error) {
+	g.writeJSON(w, http.StatusBadRequest, errorResult{Error: err.Error()})
+}
+
+func (g *Gateway) writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		g.logger.Warn("failed to write HTTP response: %v", err)
+	}
+}
+
+// escapePattern escapes the glob wildcards of a prefix.
+func escapePattern(prefix string) string {
+	var b strings.Builder
+	for _, r := range prefix {
+		if strings.ContainsRune(`*?[]\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,186 @@
+package gateway_test
+
+import (
+	"concurrency_hw1/internal/config"
+	"concurrency_hw1/internal/gateway"
+	"concurrency_hw1/pkg/logger"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeDispatcher answers GET, SET, MDEL and KEYS like the server does.
+type fakeDispatcher struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
+func (d *fakeDispatcher) Dispatch(ctx context.Context, command string, args []string) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch command {
+	case "GET":
+		if value, ok := d.values[args[0]]; ok {
+			return value
+		}
+		return " "
+	case "SET":
+		d.values[args[0]] = args[1]
+		return "ok"
+	case "MDEL":
+		if _, ok := d.values[args[0]]; !ok {
+			return "0"
+		}
+		delete(d.values, args[0])
+		return "1"
+	case "KEYS":
+		var keys []string
+		for key := range d.values {
+			if strings.HasPrefix(key, strings.TrimSuffix(args[0], "*")) {
+				keys = append(keys, key)
+			}
+		}
+		if len(keys) == 0 {
+			return " "
+		}
+		return strings.Join(keys, "\n")
+	default:
+		return "ERR unknown command: " + command
+	}
+}
+
+func TestGateway(t *testing.T) {
+	dispatcher := &fakeDispatcher{values: map[string]string{"user:1": "alice", "user:2": "bob", "order:1": "book"}}
+	g, err := gateway.NewGateway(&config.HTTPConfig{Address: "127.0.0.1:0", MaxBodySize: "1KB"}, dispatcher, logger.New("error", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- g.Execute(ctx)
+	}()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}()
+
+	base := "http://" + g.Addr().String()
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "Get a key",
+			method:     http.MethodGet,
+			path:       "/keys/user:1",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"key":"user:1","value":"alice"}`,
+		},
+		{
+			name:       "Get a missing key",
+			method:     http.MethodGet,
+			path:       "/keys/missing",
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"error":"key not found"}`,
+		},
+		{
+			name:       "Put a key",
+			method:     http.MethodPut,
+			path:       "/keys/user:3",
+			body:       `{"value":"carol"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"key":"user:3","value":"carol"}`,
+		},
+		{
+			name:       "Put a value with spaces",
+			method:     http.MethodPut,
+			path:       "/keys/user:3",
+			body:       `{"value":"a b"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error":"arguments must be non-empty and must not contain whitespace: \"a b\""}`,
+		},
+		{
+			name:       "Delete a key",
+			method:     http.MethodDelete,
+			path:       "/keys/order:1",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"key":"order:1","deleted":true}`,
+		},
+		{
+			name:       "List keys by prefix",
+			method:     http.MethodGet,
+			path:       "/keys?prefix=user:",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"keys":["user:1","user:2","user:3"]}`,
+		},
+		{
+			name:       "Batch",
+			method:     http.MethodPost,
+			path:       "/batch",
+			body:       `[{"op":"set","key":"a","value":"1"},{"op":"get","key":"a"},{"op":"delete","key":"b"},{"op":"incr","key":"a"}]`,
+			wantStatus: http.StatusOK,
+			wantBody: `[{"key":"a","ok":true},{"key":"a","value":"1","ok":true},{"key":"b","ok":false},` +
+				`{"key":"a","ok":false,"error":"unknown operation \"incr\""}]`,
+		},
+		{
+			name:       "Command",
+			method:     http.MethodPost,
+			path:       "/command",
+			body:       `{"command":"GET","args":["user:2"]}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"response":"bob"}`,
+		},
+		{
+			name:       "Command with an error response",
+			method:     http.MethodPost,
+			path:       "/command",
+			body:       `{"command":"NOPE"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error":"unknown command: NOPE"}`,
+		},
+		{
+			name:       "Body is too large",
+			method:     http.MethodPost,
+			path:       "/command",
+			body:       `{"command":"GET","args":["` + strings.Repeat("k", 2048) + `"]}`,
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, err := http.NewRequest(tt.method, base+tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			response, err := http.DefaultClient.Do(request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer response.Body.Close()
+
+			body, err := io.ReadAll(response.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Errorf("got status %d, want %d", response.StatusCode, tt.wantStatus)
+			}
+			if got := strings.TrimSpace(string(body)); tt.wantBody != "" && got != tt.wantBody {
+				t.Errorf("got body %s, want %s", got, tt.wantBody)
+			}
+		})
+	}
+}
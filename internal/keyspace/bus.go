@@ -0,0 +1,112 @@
+package keyspace
+
+import (
+	"concurrency_hw1/internal/pubsub"
+	"concurrency_hw1/internal/storage"
+	"concurrency_hw1/pkg/common"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+const (
+	// ChannelPrefix is prepended to a key to get the pub/sub channel its
+	// changes are published on
+	ChannelPrefix      = "__keyspace__:"
+	DefaultHistorySize = 10000
+)
+
+var ErrHistoryLost = errors.New("changes after the requested LSN are no longer available")
+
+// Event is a change of a key made by the WAL record with the given LSN.
+type Event struct {
+	LSN  uint64
+	Type storage.EventType
+	Key  string
+}
+
+func (e Event) channel() string {
+	return ChannelPrefix + e.Key
+}
+
+func (e Event) payload() string {
+	return fmt.Sprintf("%d %s", e.LSN, e.Type)
+}
+
+// Bus publishes key changes to the hub as "<lsn> <type>" messages on the
+// channel of the key and keeps the latest of them, so a watcher that lost
+// its connection can resume from the last LSN it has seen.
+type Bus struct {
+	hub  *pubsub.Hub
+	mu   sync.Mutex
+	size int
+	// history is ordered by LSN, lost is the newest LSN dropped from it
+	history []Event
+	lost    uint64
+}
+
+func NewBus(hub *pubsub.Hub, historySize int) *Bus {
+	if historySize <= 0 {
+		historySize = DefaultHistorySize
+	}
+
+	return &Bus{
+		hub:  hub,
+		size: historySize,
+	}
+}
+
+// Reset drops the history and marks changes up to lsn as lost, e.g. the
+// ones recovered from the WAL on startup.
+func (b *Bus) Reset(lsn uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.history = nil
+	b.lost = lsn
+}
+
+// Publish sends events to the watchers, events must come in LSN order.
+func (b *Bus) Publish(events ...Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, event := range events {
+		b.history = append(b.history, event)
+		b.hub.Publish(event.channel(), event.payload())
+	}
+
+	if drop := len(b.history) - b.size; drop > 0 {
+		b.lost = b.history[drop-1].LSN
+		b.history = b.history[drop:]
+	}
+}
+
+// Watch subscribes to changes of keys matching pattern. With resume set the
+// changes made after the since LSN are delivered first, no change is missed
+// or repeated between them and the live ones.
+func (b *Bus) Watch(subscriber *pubsub.Subscriber, pattern string, since uint64, resume bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if resume && since < b.lost {
+		return ErrHistoryLost
+	}
+
+	channelPattern := ChannelPrefix + pattern
+	subscriber.PSubscribe(channelPattern)
+	if !resume {
+		return nil
+	}
+
+	first := sort.Search(len(b.history), func(i int) bool {
+		return b.history[i].LSN > since
+	})
+	for _, event := range b.history[first:] {
+		if common.MatchPattern(pattern, event.Key) {
+			subscriber.Deliver(channelPattern, event.channel(), event.payload())
+		}
+	}
+	return nil
+}
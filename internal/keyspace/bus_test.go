@@ -0,0 +1,118 @@
+package keyspace_test
+
+import (
+	"concurrency_hw1/internal/keyspace"
+	"concurrency_hw1/internal/pubsub"
+	"concurrency_hw1/internal/storage"
+	"concurrency_hw1/pkg/logger"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type fakeConnection struct {
+	mu      sync.Mutex
+	written []string
+}
+
+func (c *fakeConnection) Write(data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.written = append(c.written, string(data))
+	return nil
+}
+
+func (c *fakeConnection) Close() error {
+	return nil
+}
+
+func (c *fakeConnection) RemoteAddr() string {
+	return "fake"
+}
+
+func (c *fakeConnection) frames() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return strings.Join(c.written, "")
+}
+
+func TestBusWatch(t *testing.T) {
+	hub := pubsub.NewHub(16, logger.New("error", ""))
+	bus := keyspace.NewBus(hub, 3)
+	bus.Reset(10)
+
+	bus.Publish(
+		keyspace.Event{LSN: 11, Type: storage.EventSet, Key: "user:1"},
+		keyspace.Event{LSN: 12, Type: storage.EventSet, Key: "order:1"},
+		keyspace.Event{LSN: 13, Type: storage.EventDel, Key: "user:1"},
+	)
+
+	tests := []struct {
+		name    string
+		since   uint64
+		resume  bool
+		want    string
+		wantErr error
+	}{
+		{
+			name: "Live changes only",
+			want: "psubscribe __keyspace__:user:* 1\n" +
+				"pmessage __keyspace__:user:* __keyspace__:user:2 14 set\n",
+		},
+		{
+			name:   "Resume from the start of the history",
+			since:  10,
+			resume: true,
+			want: "psubscribe __keyspace__:user:* 1\n" +
+				"pmessage __keyspace__:user:* __keyspace__:user:1 11 set\n" +
+				"pmessage __keyspace__:user:* __keyspace__:user:1 13 del\n" +
+				"pmessage __keyspace__:user:* __keyspace__:user:2 14 set\n",
+		},
+		{
+			name:   "Resume skips seen changes",
+			since:  11,
+			resume: true,
+			want: "psubscribe __keyspace__:user:* 1\n" +
+				"pmessage __keyspace__:user:* __keyspace__:user:1 13 del\n" +
+				"pmessage __keyspace__:user:* __keyspace__:user:2 14 set\n",
+		},
+		{
+			name:    "Changes are no longer kept",
+			since:   9,
+			resume:  true,
+			wantErr: keyspace.ErrHistoryLost,
+		},
+	}
+
+	var subscribers []*pubsub.Subscriber
+	var connections []*fakeConnection
+	for _, tt := range tests {
+		conn := &fakeConnection{}
+		subscriber := hub.NewSubscriber(conn)
+		err := bus.Watch(subscriber, "user:*", tt.since, tt.resume)
+		if !errors.Is(err, tt.wantErr) {
+			t.Fatalf("%s: got error %v, want %v", tt.name, err, tt.wantErr)
+		}
+		subscribers = append(subscribers, subscriber)
+		connections = append(connections, conn)
+	}
+
+	// the history keeps 3 events, so 11 is dropped now
+	bus.Publish(keyspace.Event{LSN: 14, Type: storage.EventSet, Key: "user:2"})
+	for _, subscriber := range subscribers {
+		subscriber.Close()
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := connections[i].frames(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if err := bus.Watch(hub.NewSubscriber(&fakeConnection{}), "*", 10, true); !errors.Is(err, keyspace.ErrHistoryLost) {
+		t.Errorf("got error %v after the history is trimmed, want %v", err, keyspace.ErrHistoryLost)
+	}
+}
@@ -0,0 +1,272 @@
+package pubsub
+
+import (
+	"concurrency_hw1/pkg/common"
+	"concurrency_hw1/pkg/logger"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+const DefaultQueueSize = 1024
+
+// Connection is where a subscriber writes its messages.
+type Connection interface {
+	Write(data []byte) error
+	Close() error
+	RemoteAddr() string
+}
+
+// Hub routes published messages to the subscribers of channels and glob
+// patterns. Publishing never blocks: every subscriber has a bounded queue
+// drained by its own goroutine, and a subscriber whose queue is full is
+// disconnected.
+type Hub struct {
+	mu        sync.RWMutex
+	channels  map[string]map[*Subscriber]struct{}
+	patterns  map[string]map[*Subscriber]struct{}
+	queueSize int
+	logger    logger.LoggerInterface
+}
+
+func NewHub(queueSize int, logger logger.LoggerInterface) *Hub {
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+
+	return &Hub{
+		channels:  make(map[string]map[*Subscriber]struct{}),
+		patterns:  make(map[string]map[*Subscriber]struct{}),
+		queueSize: queueSize,
+		logger:    logger,
+	}
+}
+
+// Publish sends message to the subscribers of channel and of the patterns
+// matching it and returns how many subscribers it was queued for.
+func (h *Hub) Publish(channel, message string) int {
+	var slow []*Subscriber
+	receivers := 0
+
+	h.mu.RLock()
+	for subscriber := range h.channels[channel] {
+		if subscriber.enqueue(fmt.Appendf(nil, "message %s %s\n", channel, message)) {
+			receivers++
+		} else {
+			slow = append(slow, subscriber)
+		}
+	}
+	for pattern, subscribers := range h.patterns {
+		if !common.MatchPattern(pattern, channel) {
+			continue
+		}
+		for subscriber := range subscribers {
+			if subscriber.enqueue(fmt.Appendf(nil, "pmessage %s %s %s\n", pattern, channel, message)) {
+				receivers++
+			} else {
+				slow = append(slow, subscriber)
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, subscriber := range slow {
+		h.logger.Warn("disconnecting slow subscriber %s", subscriber.conn.RemoteAddr())
+		subscriber.disconnect()
+	}
+	return receivers
+}
+
+// NewSubscriber starts a subscriber writing to conn. It must be closed when
+// the connection is gone.
+func (h *Hub) NewSubscriber(conn Connection) *Subscriber {
+	s := &Subscriber{
+		hub:      h,
+		conn:     conn,
+		queue:    make(chan []byte, h.queueSize),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+		channels: make(map[string]struct{}),
+		patterns: make(map[string]struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// Subscriber is a connection subscribed to channels and patterns.
+type Subscriber struct {
+	hub   *Hub
+	conn  Connection
+	queue chan []byte
+	// done is closed to stop the writer, stopped when it has returned
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+
+	// channels and patterns are guarded by hub.mu
+	channels map[string]struct{}
+	patterns map[string]struct{}
+}
+
+// Subscribe adds channels. A confirmation with the number of subscriptions
+// is queued for each of them ahead of any message published later.
+func (s *Subscriber) Subscribe(channels ...string) {
+	s.add("subscribe", s.hub.channels, s.channels, channels)
+}
+
+func (s *Subscriber) PSubscribe(patterns ...string) {
+	s.add("psubscribe", s.hub.patterns, s.patterns, patterns)
+}
+
+// Unsubscribe removes channels, all of them if none are given, and queues a
+// confirmation for each of them.
+func (s *Subscriber) Unsubscribe(channels ...string) {
+	s.remove("unsubscribe", s.hub.channels, s.channels, channels)
+}
+
+func (s *Subscriber) PUnsubscribe(patterns ...string) {
+	s.remove("punsubscribe", s.hub.patterns, s.patterns, patterns)
+}
+
+// Deliver queues a message as if it had been published on channel and
+// matched pattern. It is used to replay messages published before the
+// subscription, a subscriber that cannot take it is disconnected.
+func (s *Subscriber) Deliver(pattern, channel, message string) {
+	if !s.enqueue(fmt.Appendf(nil, "pmessage %s %s %s\n", pattern, channel, message)) {
+		s.disconnect()
+	}
+}
+
+// Count returns the number of channels and patterns of the subscriber.
+func (s *Subscriber) Count() int {
+	s.hub.mu.RLock()
+	defer s.hub.mu.RUnlock()
+	return s.count()
+}
+
+// Close removes all subscriptions and stops the writer once the queued
+// messages are written.
+func (s *Subscriber) Close() {
+	s.closeOnce.Do(func() {
+		s.hub.mu.Lock()
+		for channel := range s.channels {
+			unregister(s.hub.channels, channel, s)
+		}
+		for pattern := range s.patterns {
+			unregister(s.hub.patterns, pattern, s)
+		}
+		s.channels = make(map[string]struct{})
+		s.patterns = make(map[string]struct{})
+		s.hub.mu.Unlock()
+
+		close(s.done)
+	})
+	<-s.stopped
+}
+
+func (s *Subscriber) add(kind string, index map[string]map[*Subscriber]struct{}, own map[string]struct{}, names []string) {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	for _, name := range names {
+		if index[name] == nil {
+			index[name] = make(map[*Subscriber]struct{})
+		}
+		index[name][s] = struct{}{}
+		own[name] = struct{}{}
+		s.confirm(kind, name)
+	}
+}
+
+func (s *Subscriber) remove(kind string, index map[string]map[*Subscriber]struct{}, own map[string]struct{}, names []string) {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	if len(names) == 0 {
+		for name := range own {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	if len(names) == 0 {
+		s.confirm(kind, "(nil)")
+		return
+	}
+
+	for _, name := range names {
+		if _, ok := own[name]; ok {
+			delete(own, name)
+			unregister(index, name, s)
+		}
+		s.confirm(kind, name)
+	}
+}
+
+// confirm queues a confirmation frame, the caller holds hub.mu.
+func (s *Subscriber) confirm(kind, name string) {
+	if !s.enqueue(fmt.Appendf(nil, "%s %s %d\n", kind, name, s.count())) {
+		s.disconnect()
+	}
+}
+
+func (s *Subscriber) count() int {
+	return len(s.channels) + len(s.patterns)
+}
+
+func unregister(index map[string]map[*Subscriber]struct{}, name string, s *Subscriber) {
+	delete(index[name], s)
+	if len(index[name]) == 0 {
+		delete(index, name)
+	}
+}
+
+func (s *Subscriber) enqueue(message []byte) bool {
+	select {
+	case s.queue <- message:
+		return true
+	default:
+		return false
+	}
+}
+
+// disconnect closes the connection of a subscriber that does not keep up,
+// the owner of the connection closes the subscriber when it notices.
+func (s *Subscriber) disconnect() {
+	if err := s.conn.Close(); err != nil {
+		s.hub.logger.Warn("failed to close subscriber connection: %v", err)
+	}
+}
+
+func (s *Subscriber) run() {
+	defer close(s.stopped)
+
+	for {
+		select {
+		case <-s.done:
+			// nothing is queued after done is closed, flush what is left
+			for {
+				select {
+				case message := <-s.queue:
+					if !s.write(message) {
+						return
+					}
+				default:
+					return
+				}
+			}
+		case message := <-s.queue:
+			if !s.write(message) {
+				return
+			}
+		}
+	}
+}
+
+func (s *Subscriber) write(message []byte) bool {
+	if err := s.conn.Write(message); err != nil {
+		s.hub.logger.Warn("failed to write to subscriber %s: %v", s.conn.RemoteAddr(), err)
+		s.disconnect()
+		return false
+	}
+	return true
+}
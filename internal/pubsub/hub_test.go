@@ -0,0 +1,153 @@
+package pubsub_test
+
+import (
+	"concurrency_hw1/internal/pubsub"
+	"concurrency_hw1/pkg/logger"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeConnection collects written frames, block makes writes hang until the
+// connection is closed, like a client that stopped reading.
+type fakeConnection struct {
+	mu      sync.Mutex
+	written []string
+	block   bool
+	closed  chan struct{}
+	once    sync.Once
+}
+
+func newFakeConnection(block bool) *fakeConnection {
+	return &fakeConnection{block: block, closed: make(chan struct{})}
+}
+
+func (c *fakeConnection) Write(data []byte) error {
+	if c.block {
+		<-c.closed
+		return errClosed
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.written = append(c.written, string(data))
+	return nil
+}
+
+func (c *fakeConnection) Close() error {
+	c.once.Do(func() { close(c.closed) })
+	return nil
+}
+
+func (c *fakeConnection) RemoteAddr() string {
+	return "fake"
+}
+
+func (c *fakeConnection) frames() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return strings.Join(c.written, "")
+}
+
+var errClosed = errors.New("connection is closed")
+
+func TestHub(t *testing.T) {
+	hub := pubsub.NewHub(8, logger.New("error", ""))
+
+	first := newFakeConnection(false)
+	second := newFakeConnection(false)
+	a := hub.NewSubscriber(first)
+	b := hub.NewSubscriber(second)
+
+	a.Subscribe("news", "sport")
+	b.PSubscribe("news.*")
+	a.Unsubscribe("sport")
+
+	if got := hub.Publish("news", "hello"); got != 1 {
+		t.Errorf("news: got %d receivers, want 1", got)
+	}
+	if got := hub.Publish("news.world", "hi"); got != 1 {
+		t.Errorf("news.world: got %d receivers, want 1", got)
+	}
+	if got := hub.Publish("sport", "goal"); got != 0 {
+		t.Errorf("sport: got %d receivers, want 0", got)
+	}
+
+	a.Close()
+	b.Close()
+
+	tests := []struct {
+		name string
+		conn *fakeConnection
+		want string
+	}{
+		{
+			name: "Channel subscriber",
+			conn: first,
+			want: "subscribe news 1\nsubscribe sport 2\nunsubscribe sport 1\nmessage news hello\n",
+		},
+		{
+			name: "Pattern subscriber",
+			conn: second,
+			want: "psubscribe news.* 1\npmessage news.* news.world hi\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.conn.frames(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if got := hub.Publish("news", "after close"); got != 0 {
+		t.Errorf("got %d receivers after close, want 0", got)
+	}
+}
+
+func TestHubDisconnectsSlowSubscriber(t *testing.T) {
+	hub := pubsub.NewHub(4, logger.New("error", ""))
+
+	slow := newFakeConnection(true)
+	fast := newFakeConnection(false)
+	slowSubscriber := hub.NewSubscriber(slow)
+	fastSubscriber := hub.NewSubscriber(fast)
+	slowSubscriber.Subscribe("events")
+	fastSubscriber.Subscribe("events")
+
+	// publishing must not wait for the slow subscriber
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			hub.Publish("events", "tick")
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("publisher is blocked")
+	}
+
+	select {
+	case <-slow.closed:
+	default:
+		t.Error("slow subscriber is not disconnected")
+	}
+	select {
+	case <-fast.closed:
+		t.Error("fast subscriber is disconnected")
+	default:
+	}
+
+	slowSubscriber.Close()
+	fastSubscriber.Close()
+	if got := strings.Count(fast.frames(), "message events tick\n"); got != 100 {
+		t.Errorf("fast subscriber got %d messages, want 100", got)
+	}
+}
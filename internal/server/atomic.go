@@ -0,0 +1,77 @@
+package server
+
+import (
+	"errors"
+	"strconv"
+)
+
+// The handlers below log the value they stored as a SET, so replaying the
+// WAL does not depend on the state the command was applied to.
+
+func (s *Server) incrHandler(delta int64) stateFunc {
+	return func(args []string) (string, []string) {
+		return s.incrBy(args[0], delta)
+	}
+}
+
+func (s *Server) handleIncrBy(args []string) (string, []string) {
+	delta, _ := strconv.ParseInt(args[1], 10, 64)
+	return s.incrBy(args[0], delta)
+}
+
+func (s *Server) incrBy(key string, delta int64) (string, []string) {
+	value, err := s.engine.IncrBy(key, delta)
+	if err != nil {
+		return errorResponse("%v", err), nil
+	}
+
+	result := strconv.FormatInt(value, 10)
+	return result, setRecord(key, result)
+}
+
+// handleAppend answers with the length of the resulting value.
+func (s *Server) handleAppend(args []string) (string, []string) {
+	value, err := s.engine.Append(args[0], args[1])
+	if err != nil {
+		return errorResponse("%v", err), nil
+	}
+	return strconv.Itoa(len(value)), setRecord(args[0], value)
+}
+
+func (s *Server) handleGetSet(args []string) (string, []string) {
+	old, ok, err := s.engine.GetSet(args[0], args[1])
+	if err != nil {
+		return errorResponse("%v", err), nil
+	}
+	return valueResponse(old, ok, nil), setRecord(args[0], args[1])
+}
+
+func (s *Server) handleSetNX(args []string) (string, []string) {
+	if !s.engine.SetNX(args[0], args[1]) {
+		return "0", nil
+	}
+	return "1", setRecord(args[0], args[1])
+}
+
+// handleCAS answers with 1 if the value was swapped and 0 otherwise.
+func (s *Server) handleCAS(args []string) (string, []string) {
+	swapped, err := s.engine.CAS(args[0], args[1], args[2])
+	if err != nil {
+		return errorResponse("%v", err), nil
+	}
+	if !swapped {
+		return "0", nil
+	}
+	return "1", setRecord(args[0], args[2])
+}
+
+func setRecord(key, value string) []string {
+	return []string{setCommand, key, value}
+}
+
+func validateInteger(args []string) error {
+	if _, err := strconv.ParseInt(args[1], 10, 64); err != nil {
+		return errors.New("increment is not an integer or out of range")
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"concurrency_hw1/internal/storage"
+	"concurrency_hw1/pkg/network"
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// popWaiter is a BLPOP or BRPOP waiting for an item. It is queued on every
+// key it waits for, ready gets the item once a push serves it.
+type popWaiter struct {
+	keys  []string
+	front bool
+	ready chan poppedItem
+}
+
+type poppedItem struct {
+	key  string
+	item string
+}
+
+func validateBlockingPop(args []string) error {
+	timeout, err := strconv.ParseFloat(args[len(args)-1], 64)
+	if err != nil || timeout < 0 || math.IsInf(timeout, 0) {
+		return errors.New("timeout must be a non-negative number of seconds")
+	}
+	return nil
+}
+
+func (s *Server) handleBLPop(ctx context.Context, args []string) string {
+	return s.blockingPop(ctx, args, true)
+}
+
+func (s *Server) handleBRPop(ctx context.Context, args []string) string {
+	return s.blockingPop(ctx, args, false)
+}
+
+// blockingPop pops from the first non-empty list or waits until a push to
+// one of the keys, the timeout, a dropped connection or the server stop. A
+// timeout of zero waits forever. Waiters are served in the order they came.
+//
+// It takes walMu itself instead of being a WAL command, because it must not
+// hold it while waiting. An item handed to a waiter is logged as a pop by
+// the push, so the WAL has the push first and the pop after it.
+func (s *Server) blockingPop(ctx context.Context, args []string, front bool) string {
+	keys := args[:len(args)-1]
+	seconds, _ := strconv.ParseFloat(args[len(args)-1], 64)
+
+	s.walMu.Lock()
+	if s.readOnly.Load() {
+		s.walMu.Unlock()
+		return errorResponse("%v", errReadOnly)
+	}
+	for _, key := range keys {
+		item, ok, err := s.pop(key, front)
+		if err != nil {
+			s.walMu.Unlock()
+			return errorResponse("%v", err)
+		}
+		if ok {
+			s.publishEvents()
+			s.walMu.Unlock()
+			return key + " " + item
+		}
+	}
+	waiter := &popWaiter{keys: keys, front: front, ready: make(chan poppedItem, 1)}
+	s.addWaiter(waiter)
+	s.walMu.Unlock()
+
+	if conn := network.ConnFromContext(ctx); conn != nil {
+		var stop func()
+		ctx, stop = conn.WatchClose(ctx)
+		defer stop()
+	}
+	var timeout <-chan time.Time
+	if seconds > 0 {
+		timer := time.NewTimer(time.Duration(seconds * float64(time.Second)))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case popped := <-waiter.ready:
+		return popped.key + " " + popped.item
+	case <-timeout:
+	case <-ctx.Done():
+	}
+
+	s.walMu.Lock()
+	waiting := s.removeWaiter(waiter)
+	s.walMu.Unlock()
+	if !waiting {
+		// a push served the waiter at the same time, the item is popped and
+		// logged already, so it is returned even if the client is gone
+		popped := <-waiter.ready
+		return popped.key + " " + popped.item
+	}
+	return nilValue
+}
+
+// pop pops an item and logs it, the caller holds walMu.
+func (s *Server) pop(key string, front bool) (string, bool, error) {
+	command, pop := rpopCommand, s.engine.RPop
+	if front {
+		command, pop = lpopCommand, s.engine.LPop
+	}
+
+	failures := storage.Failures(s.engine)
+	item, ok, err := pop(key)
+	if storage.Failures(s.engine) != failures {
+		s.dropEvents()
+		return "", false, errEngineFailed
+	}
+	if err == nil && ok {
+		s.appendWAL(command + " " + key)
+	}
+	return item, ok, err
+}
+
+// servePushed serves the waiters of the keys the current write pushed to,
+// the caller holds walMu.
+func (s *Server) servePushed() {
+	for _, key := range s.pushedKeys {
+		s.serveWaiters(key)
+	}
+	s.pushedKeys = s.pushedKeys[:0]
+}
+
+// serveWaiters hands items pushed to key to the waiters in the order they
+// came, the caller holds walMu.
+func (s *Server) serveWaiters(key string) {
+	for len(s.waiters[key]) != 0 {
+		waiter := s.waiters[key][0]
+		item, ok, err := s.pop(key, waiter.front)
+		if err != nil || !ok {
+			return
+		}
+		s.removeWaiter(waiter)
+		waiter.ready <- poppedItem{key: key, item: item}
+	}
+}
+
+func (s *Server) addWaiter(waiter *popWaiter) {
+	s.blocked++
+	for _, key := range waiter.keys {
+		if !slices.Contains(s.waiters[key], waiter) {
+			s.waiters[key] = append(s.waiters[key], waiter)
+		}
+	}
+}
+
+// removeWaiter reports whether the waiter was still waiting.
+func (s *Server) removeWaiter(waiter *popWaiter) bool {
+	found := false
+	for _, key := range waiter.keys {
+		queue := s.waiters[key]
+		if i := slices.Index(queue, waiter); i >= 0 {
+			found = true
+			queue = slices.Delete(queue, i, i+1)
+		}
+		if len(queue) == 0 {
+			delete(s.waiters, key)
+		} else {
+			s.waiters[key] = queue
+		}
+	}
+	if found {
+		s.blocked--
+	}
+	return found
+}
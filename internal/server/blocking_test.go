@@ -0,0 +1,151 @@
+package server_test
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitBlocked waits until count pops are blocked.
+func (ts *testServer) waitBlocked(t *testing.T, count int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for ts.info("blocked_clients") != strconv.Itoa(count) {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %s blocked clients, want %d", ts.info("blocked_clients"), count)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBlockingPopServesWaitersInOrder(t *testing.T) {
+	ts := newTestServer(t, nil)
+
+	responses := make([]chan string, 3)
+	for i := range responses {
+		responses[i] = make(chan string, 1)
+		go func() { responses[i] <- ts.do("BLPOP", "list", "0") }()
+		ts.waitBlocked(t, i+1)
+	}
+	if got := ts.do("RPUSH", "list", "a", "b", "c"); got != "3" {
+		t.Fatalf("RPUSH: got %q", got)
+	}
+
+	for i, want := range []string{"list a", "list b", "list c"} {
+		if got := <-responses[i]; got != want {
+			t.Errorf("waiter %d: got %q, want %q", i, got, want)
+		}
+	}
+	ts.waitBlocked(t, 0)
+	// the pops are logged after the push that served them
+	want := []string{"RPUSH list a b c", "LPOP list", "LPOP list", "LPOP list"}
+	if got := ts.logged(len(want)); !slices.Equal(got, want) {
+		t.Errorf("got records %q, want %q", got, want)
+	}
+}
+
+func TestBlockingPopTimeout(t *testing.T) {
+	ts := newTestServer(t, nil)
+
+	start := time.Now()
+	if got := ts.do("BRPOP", "list", "other", "0.05"); got != "(nil)" {
+		t.Errorf("got %q, want (nil)", got)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("returned after %s, before the timeout", elapsed)
+	}
+	ts.waitBlocked(t, 0)
+	if got := ts.do("RPUSH", "list", "a"); got != "1" {
+		t.Errorf("RPUSH: got %q, want the item kept for nobody waits", got)
+	}
+}
+
+func TestBlockingPopOfDroppedConnection(t *testing.T) {
+	ts := newTestServer(t, nil)
+	ts.start()
+
+	connection := ts.dial(t)
+	if _, err := connection.Write([]byte("BLPOP list 0")); err != nil {
+		t.Fatal(err)
+	}
+	ts.waitBlocked(t, 1)
+	connection.Close()
+	ts.waitBlocked(t, 0)
+
+	ts.do("RPUSH", "list", "a")
+	if got := ts.do("LRANGE", "list", "0", "-1"); got != "a" {
+		t.Errorf("got %q, want the item pushed after the client left", got)
+	}
+}
+
+func TestBlockingPopRacingPush(t *testing.T) {
+	ts := newTestServer(t, nil)
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("list:%d", i)
+		popped := make(chan string, 1)
+		go func() { popped <- ts.do("BLPOP", key, "0.005") }()
+		// push around the moment the waiter times out
+		time.Sleep(time.Duration(i%10) * time.Millisecond)
+		ts.do("RPUSH", key, "item")
+
+		got := <-popped
+		left := ts.do("LRANGE", key, "0", "-1")
+		switch {
+		case got == key+" item" && left == " ":
+		case got == "(nil)" && left == "item":
+		default:
+			t.Fatalf("got %q popped and %q left, want the item exactly once", got, left)
+		}
+	}
+}
+
+func TestBlockingPopEventsHaveTheirLSN(t *testing.T) {
+	ts := newTestServer(t, nil)
+	ts.start()
+	// the history of changes starts once the server serves connections
+	connection := ts.dial(t)
+	buffer := make([]byte, 1024)
+	if _, err := connection.Write([]byte("PING")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := connection.Read(buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	popped := make(chan string, 2)
+	for i := 0; i < 2; i++ {
+		go func() { popped <- ts.do("BLPOP", "list", "0") }()
+		ts.waitBlocked(t, i+1)
+	}
+	// the push is record 1, the pops it serves are records 2 and 3
+	ts.do("RPUSH", "list", "a", "b")
+	<-popped
+	<-popped
+
+	// a watcher that saw the push resumes with the pops
+	if _, err := connection.Write([]byte("WATCH list SINCE 1")); err != nil {
+		t.Fatal(err)
+	}
+	var frames string
+	connection.SetReadDeadline(time.Now().Add(time.Second))
+	for strings.Count(frames, "pmessage") < 2 {
+		n, err := connection.Read(buffer)
+		if err != nil {
+			t.Fatalf("got %q, then %v", frames, err)
+		}
+		frames += string(buffer[:n])
+	}
+	var lsns []string
+	for _, line := range strings.Split(strings.TrimSpace(frames), "\n") {
+		if fields := strings.Fields(line); fields[0] == "pmessage" {
+			lsns = append(lsns, fields[3])
+		}
+	}
+	if !slices.Equal(lsns, []string{"2", "3"}) {
+		t.Errorf("got events with LSNs %v, want 2 and 3:\n%s", lsns, frames)
+	}
+}
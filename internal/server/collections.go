@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+)
+
+func (s *Server) handleType(ctx context.Context, args []string) string {
+	return s.engine.Type(args[0])
+}
+
+// handleLPush answers with the length of the list after the push, before
+// the pushed items are handed to blocked pops.
+func (s *Server) handleLPush(ctx context.Context, args []string) string {
+	count, err := s.engine.LPush(args[0], args[1:]...)
+	if err == nil {
+		s.pushedKeys = append(s.pushedKeys, args[0])
+	}
+	return countResponse(count, err)
+}
+
+func (s *Server) handleRPush(ctx context.Context, args []string) string {
+	count, err := s.engine.RPush(args[0], args[1:]...)
+	if err == nil {
+		s.pushedKeys = append(s.pushedKeys, args[0])
+	}
+	return countResponse(count, err)
+}
+
+func (s *Server) handleLPop(ctx context.Context, args []string) string {
+	return valueResponse(s.engine.LPop(args[0]))
+}
+
+func (s *Server) handleRPop(ctx context.Context, args []string) string {
+	return valueResponse(s.engine.RPop(args[0]))
+}
+
+func (s *Server) handleLRange(ctx context.Context, args []string) string {
+	start, _ := strconv.Atoi(args[1])
+	stop, _ := strconv.Atoi(args[2])
+
+	items, err := s.engine.LRange(args[0], start, stop)
+	if err != nil {
+		return errorResponse("%v", err)
+	}
+	return joinLines(items)
+}
+
+func (s *Server) handleHSet(ctx context.Context, args []string) string {
+	pairs := make(map[string]string, len(args)/2)
+	for i := 1; i < len(args); i += 2 {
+		pairs[args[i]] = args[i+1]
+	}
+	return countResponse(s.engine.HSet(args[0], pairs))
+}
+
+func (s *Server) handleHGet(ctx context.Context, args []string) string {
+	return valueResponse(s.engine.HGet(args[0], args[1]))
+}
+
+func (s *Server) handleHDel(ctx context.Context, args []string) string {
+	return countResponse(s.engine.HDel(args[0], args[1:]...))
+}
+
+// handleHGetAll answers with a "field value" line per field, sorted by field.
+func (s *Server) handleHGetAll(ctx context.Context, args []string) string {
+	pairs, err := s.engine.HGetAll(args[0])
+	if err != nil {
+		return errorResponse("%v", err)
+	}
+
+	lines := make([]string, 0, len(pairs))
+	for field, value := range pairs {
+		lines = append(lines, field+" "+value)
+	}
+	sort.Strings(lines)
+	return joinLines(lines)
+}
+
+func (s *Server) handleSAdd(ctx context.Context, args []string) string {
+	return countResponse(s.engine.SAdd(args[0], args[1:]...))
+}
+
+func (s *Server) handleSRem(ctx context.Context, args []string) string {
+	return countResponse(s.engine.SRem(args[0], args[1:]...))
+}
+
+func (s *Server) handleSMembers(ctx context.Context, args []string) string {
+	members, err := s.engine.SMembers(args[0])
+	if err != nil {
+		return errorResponse("%v", err)
+	}
+	return joinLines(members)
+}
+
+func (s *Server) handleSIsMember(ctx context.Context, args []string) string {
+	ok, err := s.engine.SIsMember(args[0], args[1])
+	if err != nil {
+		return errorResponse("%v", err)
+	}
+	if ok {
+		return "1"
+	}
+	return "0"
+}
+
+func countResponse(count int, err error) string {
+	if err != nil {
+		return errorResponse("%v", err)
+	}
+	return strconv.Itoa(count)
+}
+
+// valueResponse answers a missing value with a space, the same as GET.
+func valueResponse(value string, ok bool, err error) string {
+	if err != nil {
+		return errorResponse("%v", err)
+	}
+	if !ok {
+		return missingValue
+	}
+	return value
+}
+
+func validateRange(args []string) error {
+	for _, index := range args[1:3] {
+		if _, err := strconv.Atoi(index); err != nil {
+			return errors.New("start and stop must be integers")
+		}
+	}
+	return nil
+}
+
+// validateFieldPairs checks that a key is followed by field value pairs.
+func validateFieldPairs(args []string) error {
+	if len(args)%2 != 1 {
+		return errors.New("arguments must be a key followed by field value pairs")
+	}
+	return nil
+}
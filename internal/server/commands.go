@@ -1,8 +1,23 @@
 package server
 
 import (
+	"concurrency_hw1/internal/storage"
+	"concurrency_hw1/pkg/concurrency"
+	"context"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
+)
+
+const (
+	errorPrefix = "ERR "
+	// missingValue answers a missing key or value. A stored value can not
+	// contain whitespace, so it is never mistaken for one.
+	missingValue = " "
+	// nilValue answers a blocking pop that timed out.
+	nilValue = "(nil)"
 )
 
 func (s *Server) readAndParseCommand() (string, []string, error) {
@@ -13,39 +28,361 @@ func (s *Server) readAndParseCommand() (string, []string, error) {
 	return s.parser.Parse(line)
 }
 
-func (s *Server) handleSet(args []string) string {
+func (s *Server) handleSet(ctx context.Context, args []string) string {
 	s.engine.Set(args[0], args[1])
 	return "ok"
 }
 
-func (s *Server) handleGet(args []string) string {
+func (s *Server) handleGet(ctx context.Context, args []string) string {
 	if val, ok := s.engine.Get(args[0]); ok {
 		return val
 	}
-	return " "
+	if s.engine.Type(args[0]) != storage.TypeNone {
+		return errorResponse("%v", storage.ErrWrongType)
+	}
+	return missingValue
 }
 
-func (s *Server) handleDel(args []string) string {
+func (s *Server) handleDel(ctx context.Context, args []string) string {
 	s.engine.Delete(args[0])
 
 	return "ok"
 }
 
-func (s *Server) handleHelp(args []string) string {
+func (s *Server) handleMGet(ctx context.Context, args []string) string {
+	values := s.engine.MGet(args)
+
+	lines := make([]string, len(values))
+	for i, value := range values {
+		if value == nil {
+			lines[i] = missingValue
+		} else {
+			lines[i] = *value
+		}
+	}
+	return joinLines(lines)
+}
+
+func (s *Server) handleMSet(ctx context.Context, args []string) string {
+	pairs := make(map[string]string, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		pairs[args[i]] = args[i+1]
+	}
+
+	s.engine.MSet(pairs)
+	return "ok"
+}
+
+func (s *Server) handleMDel(ctx context.Context, args []string) string {
+	return strconv.Itoa(s.engine.MDelete(args))
+}
+
+func (s *Server) handleExists(ctx context.Context, args []string) string {
+	return strconv.Itoa(s.engine.Exists(args))
+}
+
+func (s *Server) handleKeys(ctx context.Context, args []string) string {
+	keys, err := s.engine.KeysContext(ctx, args[0])
+	if err != nil {
+		return errorResponse("%v", err)
+	}
+	return joinLines(keys)
+}
+
+// handleScan answers with the next cursor on the first line followed by the
+// keys of the page.
+func (s *Server) handleScan(ctx context.Context, args []string) string {
+	pattern, count := "*", 0
+	for i := 1; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			return errorResponse("option %s requires a value", args[i])
+		}
+
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			pattern = args[i+1]
+		case "COUNT":
+			value, err := strconv.Atoi(args[i+1])
+			if err != nil || value <= 0 {
+				return errorResponse("COUNT must be a positive integer")
+			}
+			count = value
+		default:
+			return errorResponse("unknown option: %s", args[i])
+		}
+	}
+
+	cursor, keys, err := s.engine.ScanContext(ctx, args[0], pattern, count)
+	if err != nil {
+		return errorResponse("%v", err)
+	}
+	return strings.Join(append([]string{cursor}, keys...), "\n")
+}
+
+func (s *Server) rangeHandler(engine storage.OrderedEngineInterface, reverse bool) commandFunc {
+	return func(ctx context.Context, args []string) string {
+		limit, err := parseLimit(args[2:])
+		if err != nil {
+			return errorResponse("%v", err)
+		}
+		return formatEntries(engine.Range(args[0], args[1], limit, reverse))
+	}
+}
+
+func (s *Server) prefixHandler(engine storage.OrderedEngineInterface, reverse bool) commandFunc {
+	return func(ctx context.Context, args []string) string {
+		limit, err := parseLimit(args[1:])
+		if err != nil {
+			return errorResponse("%v", err)
+		}
+		return formatEntries(engine.Prefix(args[0], limit, reverse))
+	}
+}
+
+func (s *Server) handlePing(ctx context.Context, args []string) string {
+	return "PONG"
+}
+
+func (s *Server) handleHelp(ctx context.Context, args []string) string {
 	return guide
 }
 
-func (s *Server) dispatchCommand(command string, args []string) string {
+func (s *Server) dispatchCommand(ctx context.Context, command string, args []string) string {
 	if cmdDef, ok := s.commands[command]; ok {
 		s.logger.Info("command: %s, args: %v", command, args)
-		if len(args) < cmdDef.minArgs {
-			return fmt.Sprintf("command %s requires at least %d argument(s), got %d", command, cmdDef.minArgs, len(args))
+		if err := checkArgs(command, cmdDef, args); err != nil {
+			return errorResponse("%v", err)
+		}
+		// blocking pops remove the items they return
+		if cmdDef.isWAL || cmdDef.blocking {
+			if err := s.checkWritable(); err != nil {
+				return errorResponse("%v", err)
+			}
+		}
+		// blocking commands would be logged for the time they wait
+		if !cmdDef.blocking {
+			defer s.logSlow(ctx, command, args, time.Now())
 		}
-		if cmdDef.isWAL {
-			s.walCh <- fmt.Appendf(nil, "%s %s", command, strings.Join(args, " "))
+		if timeout := s.commandTimeout(command); timeout > 0 && cmdDef.abandonable() {
+			response, err := concurrency.RunWithTimeout(ctx, timeout, func(ctx context.Context) (string, error) {
+				return s.executeCommand(ctx, command, cmdDef, args), nil
+			})
+			switch {
+			case errors.Is(err, context.DeadlineExceeded):
+				return errorResponse("command %s timed out after %s", command, timeout)
+			case err != nil:
+				s.logger.Error("command %s failed: %v", command, err)
+				return errorResponse("internal error")
+			}
+			return response
+		}
+		return s.executeCommand(ctx, command, cmdDef, args)
+	}
+	return errorResponse("unknown command: %s", command)
+}
+
+// checkArgs checks the number of arguments and validates them.
+func checkArgs(command string, cmdDef CommandDefinition, args []string) error {
+	if len(args) < cmdDef.minArgs {
+		return fmt.Errorf("command %s requires at least %d argument(s), got %d", command, cmdDef.minArgs, len(args))
+	}
+	if cmdDef.maxArgs != 0 && len(args) > cmdDef.maxArgs {
+		return fmt.Errorf("command %s takes at most %d argument(s), got %d", command, cmdDef.maxArgs, len(args))
+	}
+	if cmdDef.validate != nil {
+		if err := cmdDef.validate(args); err != nil {
+			return fmt.Errorf("command %s: %w", command, err)
 		}
+	}
+	return nil
+}
+
+// executeCommand runs a validated command on the worker pool if there is
+// one.
+func (s *Server) executeCommand(ctx context.Context, command string, cmdDef CommandDefinition, args []string) string {
+	// blocking commands wait for other requests and would hold a worker
+	if s.workers != nil && !cmdDef.blocking {
+		return s.executeOnWorker(ctx, func() string {
+			return s.runCommand(ctx, command, cmdDef, args)
+		})
+	}
+	return s.runCommand(ctx, command, cmdDef, args)
+}
+
+func (s *Server) commandTimeout(command string) time.Duration {
+	if timeout, ok := s.commandTimeouts[command]; ok {
+		return timeout
+	}
+	return s.commandTimeouts[""]
+}
+
+// runCommand runs the handler of a command and logs a write to the WAL once
+// it succeeded. A write answered with an error has changed nothing, so it is
+// not logged and replay does not run it again. A command during which the
+// engine failed is answered with an error, its result may be wrong.
+func (s *Server) runCommand(ctx context.Context, command string, cmdDef CommandDefinition, args []string) string {
+	if !cmdDef.isWAL {
+		failures := storage.Failures(s.engine)
+		response := cmdDef.handler(ctx, args)
+		if storage.Failures(s.engine) != failures {
+			return errorResponse("%v", errEngineFailed)
+		}
+		return response
+	}
+
+	s.walMu.Lock()
+	defer s.walMu.Unlock()
+	// READONLY may have come while the write waited for the lock
+	if s.readOnly.Load() {
+		return errorResponse("%v", errReadOnly)
+	}
+	failures := storage.Failures(s.engine)
+	if failures != 0 {
+		return errorResponse("%v", errEngineFailing)
+	}
+	defer s.publishEvents()
+
+	var response string
+	var record []string
+	if cmdDef.stateHandler != nil {
+		response, record = cmdDef.stateHandler(args)
+	} else {
+		response = cmdDef.handler(ctx, args)
+		if !strings.HasPrefix(response, errorPrefix) {
+			record = append([]string{command}, args...)
+		}
+	}
+	if storage.Failures(s.engine) != failures {
+		s.dropEvents()
+		s.pushedKeys = s.pushedKeys[:0]
+		return errorResponse("%v", errEngineFailed)
+	}
+	if record != nil {
+		s.appendWAL(strings.Join(record, " "))
+	}
+	// the pops of the waiters are logged after the push that served them
+	s.servePushed()
+	return response
+}
+
+// executeOnWorker runs a command on the worker pool and waits for it. A
+// request that is gone while it waits is not answered, but a command that
+// has started still runs to the end.
+func (s *Server) executeOnWorker(ctx context.Context, run func() string) string {
+	var response string
+	panicked := true
+	done := make(chan struct{})
+	err := s.workers.Submit(ctx, func() {
+		defer close(done)
+		response = run()
+		panicked = false
+	})
+	if err != nil {
+		return errorResponse("%v", err)
+	}
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return errorResponse("%v", ctx.Err())
+	}
+	if panicked {
+		return errorResponse("internal error")
+	}
+	return response
+}
+
+// appendWAL sends a record to the WAL and gives it the next LSN, the caller
+// holds walMu.
+func (s *Server) appendWAL(record string) {
+	s.lsn++
+	s.stampEvents()
+	s.walCh <- fmt.Appendf(nil, "%s\n", record)
+}
+
+// Replay applies a command read back from the WAL without logging it again.
+// Only the records that apply count towards the LSN, like only the writes
+// that succeeded are logged, and a persistent engine only restores the LSN
+// because it has the data already.
+func (s *Server) Replay(command string, args []string) error {
+	if storage.IsPersistent(s.engine) {
+		s.lsn++
+		return nil
+	}
+	// nobody watches or waits yet, the changes are not published
+	defer func() {
+		s.pendingEvents = s.pendingEvents[:0]
+		s.pushedKeys = s.pushedKeys[:0]
+	}()
+
+	cmdDef, ok := s.commands[command]
+	if !ok || !cmdDef.isWAL {
+		return fmt.Errorf("unexpected command in WAL: %s", command)
+	}
+	// logs written before records were newline terminated have them glued,
+	// e.g. "SET a 1SET b 2", their argument count is wrong
+	if err := checkArgs(command, cmdDef, args); err != nil {
+		return err
+	}
+
+	var response string
+	if cmdDef.stateHandler != nil {
+		response, _ = cmdDef.stateHandler(args)
+	} else {
+		response = cmdDef.handler(context.Background(), args)
+	}
+	if strings.HasPrefix(response, errorPrefix) {
+		return errors.New(strings.TrimPrefix(response, errorPrefix))
+	}
+	s.lsn++
+	return nil
+}
+
+func validatePairs(args []string) error {
+	if len(args)%2 != 0 {
+		return errors.New("arguments must be key value pairs")
+	}
+	return nil
+}
+
+// parseLimit parses an optional "LIMIT n" tail of a command.
+func parseLimit(args []string) (int, error) {
+	if len(args) == 0 {
+		return 0, nil
+	}
+	if len(args) != 2 || strings.ToUpper(args[0]) != "LIMIT" {
+		return 0, errors.New("expected LIMIT n")
+	}
+
+	limit, err := strconv.Atoi(args[1])
+	if err != nil || limit <= 0 {
+		return 0, errors.New("LIMIT must be a positive integer")
+	}
+	return limit, nil
+}
+
+func formatEntries(entries []storage.Entry) string {
+	lines := make([]string, len(entries))
+	for i, entry := range entries {
+		if entry.Type == "string" {
+			lines[i] = entry.Key + " " + entry.Value
+		} else {
+			lines[i] = entry.Key + " (" + entry.Type + ")"
+		}
+	}
+	return joinLines(lines)
+}
+
+func errorResponse(format string, args ...any) string {
+	return errorPrefix + fmt.Sprintf(format, args...)
+}
 
-		return cmdDef.handler(args)
+// joinLines puts every item on its own line. An empty list is answered with
+// a single space, the same as a missing key, because the client waits for a
+// non-empty response.
+func joinLines(items []string) string {
+	if len(items) == 0 {
+		return missingValue
 	}
-	return fmt.Sprintf("unknown command: %s", command)
+	return strings.Join(items, "\n")
 }
@@ -0,0 +1,117 @@
+package server_test
+
+import (
+	"concurrency_hw1/internal/storage"
+	"concurrency_hw1/internal/storage/lsm"
+	"concurrency_hw1/pkg/logger"
+	"slices"
+	"testing"
+)
+
+func TestFailedWritesAreNotLogged(t *testing.T) {
+	ts := newTestServer(t, nil)
+
+	steps := []struct {
+		command string
+		args    []string
+		want    string
+	}{
+		{command: "LPUSH", args: []string{"list", "a"}, want: "1"},
+		{command: "HSET", args: []string{"list", "field", "value"}, want: "ERR WRONGTYPE operation against a key holding the wrong kind of value"},
+		{command: "SET", args: []string{"counter", "x"}, want: "ok"},
+		{command: "INCR", args: []string{"counter"}, want: "ERR value is not an integer or out of range"},
+	}
+	for _, step := range steps {
+		if got := ts.do(step.command, step.args...); got != step.want {
+			t.Errorf("%s %v: got %q, want %q", step.command, step.args, got, step.want)
+		}
+	}
+
+	want := []string{"LPUSH list a", "SET counter x"}
+	if got := ts.logged(len(want)); !slices.Equal(got, want) {
+		t.Errorf("got records %q, want %q", got, want)
+	}
+	if got := ts.info("lsn"); got != "2" {
+		t.Errorf("got lsn %s, want 2", got)
+	}
+}
+
+func TestMissingKeys(t *testing.T) {
+	ts := newTestServer(t, nil)
+	if got := ts.do("SET", "stored", "(nil)"); got != "ok" {
+		t.Fatalf("got %q setting a key", got)
+	}
+
+	tests := []struct {
+		command string
+		args    []string
+		want    string
+	}{
+		{command: "GET", args: []string{"missing"}, want: " "},
+		{command: "GET", args: []string{"stored"}, want: "(nil)"},
+		{command: "MGET", args: []string{"missing", "stored"}, want: " \n(nil)"},
+		{command: "HGET", args: []string{"missing", "field"}, want: " "},
+	}
+	for _, tt := range tests {
+		if got := ts.do(tt.command, tt.args...); got != tt.want {
+			t.Errorf("%s %v: got %q, want %q", tt.command, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestReplayCountsOnlyAppliedRecords(t *testing.T) {
+	ts := newTestServer(t, nil)
+
+	if err := ts.Replay("LPUSH", []string{"list", "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.Replay("HSET", []string{"list", "field", "value"}); err == nil {
+		t.Error("got no error replaying a write to a key of the wrong type")
+	}
+	if err := ts.Replay("RPOP", []string{"list"}); err != nil {
+		t.Fatal(err)
+	}
+	// records of old logs are glued, e.g. "SET a 1SET b 2" and "DEL aSET b 1"
+	if err := ts.Replay("SET", []string{"a", "1SET", "b", "2"}); err == nil {
+		t.Error("got no error replaying glued SET records")
+	}
+	if err := ts.Replay("DEL", []string{"aSET", "b", "1"}); err == nil {
+		t.Error("got no error replaying glued DEL and SET records")
+	}
+	if got := ts.do("GET", "a"); got != " " {
+		t.Errorf("got %q for a key of glued records, want it untouched", got)
+	}
+
+	if got := ts.info("lsn"); got != "2" {
+		t.Errorf("got lsn %s, want 2", got)
+	}
+}
+
+func TestFailingEngine(t *testing.T) {
+	engine, err := storage.NewLSMEngine(lsm.Options{Dir: t.TempDir(), Logger: logger.New("error", "")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := newTestServerOn(t, engine, nil)
+	ts.do("SET", "key", "value")
+
+	// writes to a closed tree fail
+	if err := engine.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ts.do("SET", "key", "other"), "ERR storage engine failed, see the server log"; got != want {
+		t.Errorf("SET: got %q, want %q", got, want)
+	}
+	if got, want := ts.do("GET", "key"), "ERR storage engine failed, see the server log"; got != want {
+		t.Errorf("GET: got %q, want %q", got, want)
+	}
+	if got, want := ts.do("DEL", "key"), "ERR server is read-only: storage engine is failing, writes are rejected"; got != want {
+		t.Errorf("DEL: got %q, want %q", got, want)
+	}
+	if got := ts.info("engine_failures"); got == "0" {
+		t.Error("INFO engine_failures: got 0, want the failures counted")
+	}
+	if got := ts.logged(2); !slices.Equal(got, []string{"SET key value"}) {
+		t.Errorf("got records %q, want the failed writes not logged", got)
+	}
+}
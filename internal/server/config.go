@@ -0,0 +1,146 @@
+package server
+
+import (
+	"concurrency_hw1/internal/config"
+	"concurrency_hw1/pkg/common"
+	"concurrency_hw1/pkg/logger"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// WALBatcher is implemented by the WAL service.
+type WALBatcher interface {
+	SetBatch(size int, timeout time.Duration)
+}
+
+// networkLimits is implemented by the network server.
+type networkLimits interface {
+	SetIdleTimeout(timeout time.Duration)
+	SetMaxConnections(count int)
+}
+
+func validateConfig(args []string) error {
+	expected := map[string]int{"GET": 2, "SET": 3, "REWRITE": 1}
+	count, ok := expected[strings.ToUpper(args[0])]
+	if !ok {
+		return fmt.Errorf("unknown subcommand %q, expected GET, SET or REWRITE", args[0])
+	}
+	if len(args) != count {
+		return fmt.Errorf("CONFIG %s expects %d arguments", strings.ToUpper(args[0]), count-1)
+	}
+	return nil
+}
+
+// handleConfig reads and changes the config. GET answers "setting value"
+// lines of the settings matching the pattern, SET changes a setting that
+// can change live and REWRITE writes the current config to the YAML file.
+func (s *Server) handleConfig(ctx context.Context, args []string) string {
+	var err error
+	switch strings.ToUpper(args[0]) {
+	case "GET":
+		return s.configGet(args[1])
+	case "SET":
+		err = s.configSet(args[1], args[2])
+	case "REWRITE":
+		err = s.configRewrite()
+	}
+	if err != nil {
+		return errorResponse("%v", err)
+	}
+	return "ok"
+}
+
+func (s *Server) configGet(pattern string) string {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+
+	var lines []string
+	for _, path := range s.config.Paths() {
+		if common.MatchPattern(pattern, path) {
+			value, _ := s.config.Get(path)
+			lines = append(lines, path+" "+value)
+		}
+	}
+	return joinLines(lines)
+}
+
+func (s *Server) configSet(path, value string) error {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+
+	if _, ok := s.config.Get(path); !ok {
+		return fmt.Errorf("unknown setting %s", path)
+	}
+	if !config.Reloadable(path) {
+		return fmt.Errorf("%s cannot be changed while the server runs", path)
+	}
+	cfg := s.config.Clone()
+	if err := cfg.Set(path, value); err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	return s.applyConfig(cfg)
+}
+
+func (s *Server) configRewrite() error {
+	if s.configFile == "" {
+		return errors.New("the server has no config file")
+	}
+
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	return config.Rewrite(s.configFile, s.config, s.resolved)
+}
+
+// ApplyConfig applies the settings of cfg that can change live, the others
+// keep the values the server was started with. cfg is resolved from its
+// sources, so the settings changed at runtime before are replaced.
+func (s *Server) ApplyConfig(cfg *config.Config) error {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	if err := s.applyConfig(cfg); err != nil {
+		return err
+	}
+	s.resolved = s.config.Clone()
+	return nil
+}
+
+// applyConfig is ApplyConfig for a caller holding configMu.
+func (s *Server) applyConfig(cfg *config.Config) error {
+	memorySize, err := requestMemorySize(cfg)
+	if err != nil {
+		return err
+	}
+	if cfg.Logging != nil && cfg.Logging.Level != "" {
+		if err := logger.SetLevel(cfg.Logging.Level); err != nil {
+			return err
+		}
+	}
+
+	s.requestMemory.Resize(memorySize)
+	s.slowLog.configure(slowLogLimits(cfg))
+	if limits, ok := s.server.(networkLimits); ok {
+		limits.SetIdleTimeout(cfg.Network.IdleTimeout)
+		limits.SetMaxConnections(cfg.Network.MaxConnections)
+	}
+	if s.walBatcher != nil {
+		s.walBatcher.SetBatch(cfg.Storage.FlushingBatchSize, cfg.Storage.FlushingBatchTimeout)
+	}
+
+	current := s.config.Clone()
+	for _, path := range cfg.Paths() {
+		if config.Reloadable(path) {
+			value, _ := cfg.Get(path)
+			if err := current.Set(path, value); err != nil {
+				return err
+			}
+		}
+	}
+	s.config = current
+	return nil
+}
@@ -0,0 +1,107 @@
+package server_test
+
+import (
+	"concurrency_hw1/internal/config"
+	"concurrency_hw1/internal/server"
+	"concurrency_hw1/pkg/logger"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigSet(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		value string
+		want  string
+	}{
+		{
+			name:  "setting that needs a restart",
+			path:  "network.address",
+			value: "127.0.0.1:4000",
+			want:  "ERR network.address cannot be changed while the server runs",
+		},
+		{name: "unknown setting", path: "network.adress", value: "x", want: "ERR unknown setting network.adress"},
+		{name: "malformed value", path: "network.max_connections", value: "many", want: "ERR "},
+		{name: "invalid value", path: "network.max_request_memory", value: "lots", want: "ERR "},
+		{name: "live setting", path: "network.max_connections", value: "7", want: "ok"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, nil)
+			before := ts.do("CONFIG", "GET", tt.path)
+
+			got := ts.do("CONFIG", "SET", tt.path, tt.value)
+			if !strings.HasPrefix(got, tt.want) {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+			after := ts.do("CONFIG", "GET", tt.path)
+			if tt.want == "ok" && after != tt.path+" "+tt.value {
+				t.Errorf("CONFIG GET: got %q, want the new value", after)
+			} else if tt.want != "ok" && after != before {
+				t.Errorf("CONFIG GET: got %q, want %q kept", after, before)
+			}
+		})
+	}
+}
+
+func TestConfigSetTakesEffect(t *testing.T) {
+	ts := newTestServer(t, nil)
+
+	if got := ts.do("CONFIG", "SET", "network.max_request_memory", "1KB"); got != "ok" {
+		t.Fatalf("CONFIG SET: got %q", got)
+	}
+	if got := ts.info("max_request_memory"); got != "1024" {
+		t.Errorf("INFO max_request_memory: got %q, want 1024", got)
+	}
+	if got := ts.do("CONFIG", "SET", "network.max_request_memory", "0"); got != "ok" {
+		t.Fatalf("CONFIG SET: got %q", got)
+	}
+	if got := ts.info("max_request_memory"); got != "0" {
+		t.Errorf("INFO max_request_memory: got %q, want 0", got)
+	}
+}
+
+func TestConfigRewrite(t *testing.T) {
+	if got := newTestServer(t, nil).do("CONFIG", "REWRITE"); got != "ERR the server has no config file" {
+		t.Errorf("REWRITE without a file: got %q", got)
+	}
+
+	fileName := filepath.Join(t.TempDir(), "config.yml")
+	data := "network:\n  # bytes of requests at once\n  max_request_memory: \"8MB\" # per server\n"
+	if err := os.WriteFile(fileName, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	log := logger.New("error", "")
+	cfg, _, err := config.Resolve(log, fileName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := newTestServer(t, cfg, server.WithConfigFile(fileName))
+
+	if got := ts.do("CONFIG", "SET", "network.max_request_memory", "32MB"); got != "ok" {
+		t.Fatalf("CONFIG SET: got %q", got)
+	}
+	if got := ts.do("CONFIG", "REWRITE"); got != "ok" {
+		t.Fatalf("CONFIG REWRITE: got %q", got)
+	}
+
+	rewritten, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, comment := range []string{"# bytes of requests at once", "# per server"} {
+		if !strings.Contains(string(rewritten), comment) {
+			t.Errorf("comment %q is lost:\n%s", comment, rewritten)
+		}
+	}
+	cfg, _, err = config.Resolve(log, fileName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := cfg.Get("network.max_request_memory"); got != "32MB" {
+		t.Errorf("got max_request_memory %q from the rewritten file, want 32MB", got)
+	}
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"concurrency_hw1/internal/storage"
+	"concurrency_hw1/pkg/concurrency"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+var infoSections = []string{"server", "clients", "memory", "workers"}
+
+// connectionStats is implemented by the network server, it is not part of
+// network.ServerInterface so the server can run without it.
+type connectionStats interface {
+	ConnectionStats() concurrency.SemaphoreStats
+}
+
+func validateInfo(args []string) error {
+	if len(args) > 1 {
+		return errors.New("expected at most one section")
+	}
+	if len(args) == 1 && !slices.Contains(infoSections, strings.ToLower(args[0])) {
+		return fmt.Errorf("unknown section %q, expected one of %s", args[0], strings.Join(infoSections, ", "))
+	}
+	return nil
+}
+
+// handleInfo reports the state of the server as "field:value" lines grouped
+// in "# section" blocks, all sections or the requested one.
+func (s *Server) handleInfo(ctx context.Context, args []string) string {
+	sections := infoSections
+	if len(args) == 1 {
+		sections = []string{strings.ToLower(args[0])}
+	}
+
+	var lines []string
+	for _, section := range sections {
+		lines = append(lines, "# "+section)
+		switch section {
+		case "server":
+			lines = append(lines, s.serverInfo()...)
+		case "clients":
+			lines = append(lines, s.clientsInfo()...)
+		case "memory":
+			lines = append(lines, s.memoryInfo()...)
+		case "workers":
+			lines = append(lines, s.workersInfo()...)
+		}
+	}
+	return joinLines(lines)
+}
+
+func (s *Server) serverInfo() []string {
+	s.walMu.Lock()
+	lsn := s.lsn
+	s.walMu.Unlock()
+
+	diskState := "none"
+	if s.diskBreaker != nil {
+		diskState = s.diskBreaker.State().String()
+	}
+	return []string{
+		infoLine("uptime_seconds", int64(time.Since(s.startedAt).Seconds())),
+		infoLine("lsn", lsn),
+		infoLine("readonly", yesNo(s.readOnly.Load())),
+		infoLine("disk_breaker", diskState),
+		infoLine("engine_failures", storage.Failures(s.engine)),
+	}
+}
+
+func (s *Server) clientsInfo() []string {
+	s.subscriptionsMu.Lock()
+	subscribers := len(s.subscriptions)
+	s.subscriptionsMu.Unlock()
+	s.walMu.Lock()
+	blocked := s.blocked
+	s.walMu.Unlock()
+
+	var connections concurrency.SemaphoreStats
+	if stats, ok := s.server.(connectionStats); ok {
+		connections = stats.ConnectionStats()
+	}
+	return []string{
+		infoLine("connections", connections.InUse),
+		infoLine("max_connections", connections.Size),
+		infoLine("subscribers", subscribers),
+		infoLine("blocked_clients", blocked),
+	}
+}
+
+func (s *Server) memoryInfo() []string {
+	stats := s.requestMemory.Stats()
+	return []string{
+		infoLine("request_memory", stats.InUse),
+		infoLine("max_request_memory", s.requestMemory.Size()),
+		infoLine("requests_waiting", stats.Waiting),
+		infoLine("requests_waited", stats.Waited),
+		infoLine("request_wait_max_ms", stats.MaxWait.Milliseconds()),
+	}
+}
+
+func (s *Server) workersInfo() []string {
+	if s.workers == nil {
+		return []string{infoLine("workers", 0)}
+	}
+
+	stats := s.workers.Stats()
+	return []string{
+		infoLine("workers", stats.Workers),
+		infoLine("workers_idle", stats.Idle),
+		infoLine("tasks_queued", stats.Queued),
+	}
+}
+
+func infoLine(field string, value any) string {
+	return fmt.Sprintf("%s:%v", field, value)
+}
+
+func yesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
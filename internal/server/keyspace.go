@@ -0,0 +1,89 @@
+package server
+
+import (
+	"concurrency_hw1/internal/keyspace"
+	"concurrency_hw1/internal/storage"
+	"concurrency_hw1/pkg/network"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const sinceArgument = "SINCE"
+
+// collectEvent is the notifier of the engine. Writes run under walMu, so the
+// events are kept until their record is logged and gives them its LSN.
+func (s *Server) collectEvent(event storage.Event) {
+	s.pendingEvents = append(s.pendingEvents, keyspace.Event{Type: event.Type, Key: event.Key})
+}
+
+// stampEvents gives the events made since the last record the LSN of the
+// record just logged, the caller holds walMu.
+func (s *Server) stampEvents() {
+	for i := len(s.pendingEvents) - 1; i >= 0 && s.pendingEvents[i].LSN == 0; i-- {
+		s.pendingEvents[i].LSN = s.lsn
+	}
+}
+
+// dropEvents forgets the events made since the last record, their change
+// is not logged, the caller holds walMu.
+func (s *Server) dropEvents() {
+	last := len(s.pendingEvents)
+	for last > 0 && s.pendingEvents[last-1].LSN == 0 {
+		last--
+	}
+	s.pendingEvents = s.pendingEvents[:last]
+}
+
+// publishEvents sends the changes of the current write to the watchers, the
+// caller holds walMu.
+func (s *Server) publishEvents() {
+	if len(s.pendingEvents) == 0 {
+		return
+	}
+
+	// changes no record was logged for belong to the last one
+	s.stampEvents()
+	s.keyspace.Publish(s.pendingEvents...)
+	s.pendingEvents = s.pendingEvents[:0]
+}
+
+func validateWatch(args []string) error {
+	switch {
+	case len(args) == 1:
+		return nil
+	case len(args) == 3 && strings.ToUpper(args[1]) == sinceArgument:
+		if _, err := strconv.ParseUint(args[2], 10, 64); err != nil {
+			return fmt.Errorf("invalid LSN: %s", args[2])
+		}
+		return nil
+	default:
+		return fmt.Errorf("syntax error, expected WATCH pattern [SINCE lsn]")
+	}
+}
+
+// handleWatch streams changes of keys matching the pattern as pmessage
+// frames with an "<lsn> <type>" payload. With SINCE the changes made after
+// that LSN are sent first, so a watcher can resume where it stopped.
+func (s *Server) handleWatch(ctx context.Context, args []string) string {
+	var since uint64
+	resume := len(args) == 3
+	if resume {
+		since, _ = strconv.ParseUint(args[2], 10, 64)
+	}
+
+	subscriber, err := s.subscriber(ctx)
+	if err != nil {
+		return errorResponse("%v", err)
+	}
+
+	if err := s.keyspace.Watch(subscriber, args[0], since, resume); err != nil {
+		if subscriber.Count() == 0 {
+			s.closeSubscription(network.ConnFromContext(ctx))
+		}
+		// watchers read lines whether the connection is in push mode or not
+		return errorResponse("%v", err) + "\n"
+	}
+	return ""
+}
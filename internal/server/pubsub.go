@@ -0,0 +1,142 @@
+package server
+
+import (
+	"concurrency_hw1/internal/pubsub"
+	"concurrency_hw1/pkg/network"
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// subscribedCommands are the only commands a connection in push mode may
+// send, anything else would be answered in the middle of pushed messages.
+var subscribedCommands = map[string]struct{}{
+	subscribeCommand:    {},
+	psubscribeCommand:   {},
+	unsubscribeCommand:  {},
+	punsubscribeCommand: {},
+	watchCommand:        {},
+	pingCommand:         {},
+}
+
+var errNoConnection = errors.New("subscriptions are only available over the network")
+
+// subscription is the subscriber of a connection, stop cancels closing it
+// when the connection is gone.
+type subscription struct {
+	subscriber *pubsub.Subscriber
+	stop       func() bool
+}
+
+// handleSubscribe switches the connection to push mode. The confirmations
+// are pushed by the subscriber, so the response itself is empty.
+func (s *Server) handleSubscribe(ctx context.Context, args []string) string {
+	subscriber, err := s.subscriber(ctx)
+	if err != nil {
+		return errorResponse("%v", err)
+	}
+
+	subscriber.Subscribe(args...)
+	return ""
+}
+
+func (s *Server) handlePSubscribe(ctx context.Context, args []string) string {
+	subscriber, err := s.subscriber(ctx)
+	if err != nil {
+		return errorResponse("%v", err)
+	}
+
+	subscriber.PSubscribe(args...)
+	return ""
+}
+
+func (s *Server) handleUnsubscribe(ctx context.Context, args []string) string {
+	return s.unsubscribe(ctx, "unsubscribe", func(subscriber *pubsub.Subscriber) {
+		subscriber.Unsubscribe(args...)
+	})
+}
+
+func (s *Server) handlePUnsubscribe(ctx context.Context, args []string) string {
+	return s.unsubscribe(ctx, "punsubscribe", func(subscriber *pubsub.Subscriber) {
+		subscriber.PUnsubscribe(args...)
+	})
+}
+
+// handlePublish answers with the number of subscribers the message was
+// queued for.
+func (s *Server) handlePublish(ctx context.Context, args []string) string {
+	return strconv.Itoa(s.hub.Publish(args[0], strings.Join(args[1:], " ")))
+}
+
+// unsubscribe leaves push mode once the connection has no subscriptions.
+func (s *Server) unsubscribe(ctx context.Context, kind string, remove func(*pubsub.Subscriber)) string {
+	conn := network.ConnFromContext(ctx)
+	if conn == nil {
+		return errorResponse("%v", errNoConnection)
+	}
+
+	s.subscriptionsMu.Lock()
+	sub, ok := s.subscriptions[conn]
+	s.subscriptionsMu.Unlock()
+	if !ok {
+		return kind + " (nil) 0\n"
+	}
+
+	remove(sub.subscriber)
+	if sub.subscriber.Count() == 0 {
+		s.closeSubscription(conn)
+	}
+	return ""
+}
+
+// subscriber returns the subscriber of the connection of the request and
+// creates it on the first subscription.
+func (s *Server) subscriber(ctx context.Context) (*pubsub.Subscriber, error) {
+	conn := network.ConnFromContext(ctx)
+	if conn == nil {
+		return nil, errNoConnection
+	}
+
+	s.subscriptionsMu.Lock()
+	defer s.subscriptionsMu.Unlock()
+
+	if sub, ok := s.subscriptions[conn]; ok {
+		return sub.subscriber, nil
+	}
+
+	sub := subscription{subscriber: s.hub.NewSubscriber(conn)}
+	sub.stop = context.AfterFunc(conn.Context(), func() {
+		s.closeSubscription(conn)
+	})
+	s.subscriptions[conn] = sub
+	conn.SetPushMode(true)
+	return sub.subscriber, nil
+}
+
+func (s *Server) closeSubscription(conn *network.Conn) {
+	s.subscriptionsMu.Lock()
+	sub, ok := s.subscriptions[conn]
+	delete(s.subscriptions, conn)
+	s.subscriptionsMu.Unlock()
+	if !ok {
+		return
+	}
+
+	sub.stop()
+	sub.subscriber.Close()
+	conn.SetPushMode(false)
+}
+
+// subscribed reports whether the connection of the request is in push mode.
+func (s *Server) subscribed(ctx context.Context) bool {
+	conn := network.ConnFromContext(ctx)
+	if conn == nil {
+		return false
+	}
+
+	s.subscriptionsMu.Lock()
+	defer s.subscriptionsMu.Unlock()
+	_, ok := s.subscriptions[conn]
+	return ok
+}
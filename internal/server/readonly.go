@@ -0,0 +1,88 @@
+package server
+
+import (
+	"concurrency_hw1/internal/storage"
+	"concurrency_hw1/pkg/concurrency"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+var (
+	errReadOnly    = errors.New("server is read-only, writes are rejected")
+	errDiskFailing = errors.New("server is read-only: disk storage is failing, writes are rejected")
+	// the engine may have applied a part of a failed write, it is not
+	// trusted with more until a restart
+	errEngineFailing = errors.New("server is read-only: storage engine is failing, writes are rejected")
+	errEngineFailed  = errors.New("storage engine failed, see the server log")
+)
+
+// checkWritable fails while writes are frozen by READONLY or cannot be
+// logged or stored. Writes wait for the disk breaker to close, the disk
+// storage probes the disk itself.
+func (s *Server) checkWritable() error {
+	if s.readOnly.Load() {
+		return errReadOnly
+	}
+	if storage.Failures(s.engine) != 0 {
+		return errEngineFailing
+	}
+	if s.diskBreaker != nil && s.diskBreaker.State() != concurrency.BreakerClosed {
+		return errDiskFailing
+	}
+	return nil
+}
+
+func validateReadOnly(args []string) error {
+	if len(args) != 1 || (args[0] != "yes" && args[0] != "no") {
+		return errors.New("expected yes or no")
+	}
+	return nil
+}
+
+func (s *Server) handleReadOnly(ctx context.Context, args []string) string {
+	if err := s.SetReadOnly(args[0] == "yes"); err != nil {
+		return errorResponse("%v", err)
+	}
+	return "ok"
+}
+
+// SetReadOnly freezes or unfreezes writes. The mode is kept in the marker
+// file, if there is one, so it survives a restart. The writes in progress
+// are finished before it returns.
+func (s *Server) SetReadOnly(on bool) error {
+	s.walMu.Lock()
+	defer s.walMu.Unlock()
+
+	if s.readOnlyMarker != "" {
+		var err error
+		if on {
+			err = os.WriteFile(s.readOnlyMarker, nil, 0644)
+		} else if err = os.Remove(s.readOnlyMarker); errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to persist read-only mode: %w", err)
+		}
+	}
+
+	if s.readOnly.Swap(on) != on {
+		s.logger.Info("read-only mode: %v", on)
+	}
+	return nil
+}
+
+// loadReadOnly restores the mode kept in the marker file.
+func (s *Server) loadReadOnly() {
+	if s.readOnlyMarker == "" {
+		return
+	}
+	if _, err := os.Stat(s.readOnlyMarker); err == nil {
+		s.readOnly.Store(true)
+		s.logger.Info("starting in read-only mode, %s exists", s.readOnlyMarker)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		s.logger.Error("failed to check read-only marker: %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package server_test
+
+import (
+	"concurrency_hw1/internal/server"
+	"concurrency_hw1/pkg/concurrency"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadOnlyRejectsWrites(t *testing.T) {
+	ts := newTestServer(t, nil)
+	ts.do("SET", "key", "before")
+
+	if got := ts.do("READONLY", "yes"); got != "ok" {
+		t.Fatalf("READONLY yes: got %q", got)
+	}
+	for _, command := range [][]string{{"SET", "key", "after"}, {"DEL", "key"}, {"LPUSH", "list", "a"}, {"BLPOP", "list", "0"}} {
+		if got, want := ts.do(command[0], command[1:]...), "ERR server is read-only, writes are rejected"; got != want {
+			t.Errorf("%s: got %q, want %q", command[0], got, want)
+		}
+	}
+	if got := ts.do("GET", "key"); got != "before" {
+		t.Errorf("GET: got %q, want reads to be served", got)
+	}
+	if got := ts.info("readonly"); got != "yes" {
+		t.Errorf("INFO readonly: got %q, want yes", got)
+	}
+
+	ts.do("READONLY", "no")
+	if got := ts.do("SET", "key", "after"); got != "ok" {
+		t.Errorf("SET after READONLY no: got %q", got)
+	}
+	if got := ts.logged(2); !slices.Equal(got, []string{"SET key before", "SET key after"}) {
+		t.Errorf("got records %q, want the rejected writes not logged", got)
+	}
+}
+
+func TestReadOnlySurvivesRestart(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "readonly")
+
+	ts := newTestServer(t, nil, server.WithReadOnlyMarker(marker))
+	ts.do("READONLY", "yes")
+	if _, err := os.Stat(marker); err != nil {
+		t.Fatalf("marker is not written: %v", err)
+	}
+
+	restarted := newTestServer(t, nil, server.WithReadOnlyMarker(marker))
+	if got := restarted.info("readonly"); got != "yes" {
+		t.Errorf("INFO readonly after a restart: got %q, want yes", got)
+	}
+	if got := restarted.do("SET", "key", "value"); got != "ERR server is read-only, writes are rejected" {
+		t.Errorf("SET after a restart: got %q", got)
+	}
+
+	restarted.do("READONLY", "no")
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Errorf("marker is kept after READONLY no: %v", err)
+	}
+	if got := newTestServer(t, nil, server.WithReadOnlyMarker(marker)).info("readonly"); got != "no" {
+		t.Errorf("INFO readonly after a second restart: got %q, want no", got)
+	}
+}
+
+func TestInfoFields(t *testing.T) {
+	ts := newTestServer(t, nil)
+
+	fields := []string{
+		"uptime_seconds", "lsn", "readonly", "disk_breaker", "engine_failures",
+		"connections", "max_connections", "subscribers", "blocked_clients",
+		"request_memory", "max_request_memory", "requests_waiting", "requests_waited", "request_wait_max_ms",
+		"workers",
+	}
+	for _, field := range fields {
+		if ts.info(field) == "" {
+			t.Errorf("INFO has no %s", field)
+		}
+	}
+	if got := ts.info("readonly"); got != "no" {
+		t.Errorf("INFO readonly: got %q, want no", got)
+	}
+	if got := ts.do("INFO", "nosuch"); !strings.HasPrefix(got, "ERR ") {
+		t.Errorf("INFO of an unknown section: got %q, want an error", got)
+	}
+}
+
+func TestDiskBreakerRejectsWritesUntilClosed(t *testing.T) {
+	breaker := concurrency.NewCircuitBreaker(
+		concurrency.WithFailureThreshold(1),
+		concurrency.WithOpenTimeout(time.Millisecond),
+		concurrency.WithHalfOpenSuccesses(1),
+	)
+	ts := newTestServer(t, nil, server.WithDiskBreaker(breaker))
+
+	breaker.Failure()
+	time.Sleep(5 * time.Millisecond)
+	if state := breaker.State(); state != concurrency.BreakerHalfOpen {
+		t.Fatalf("got breaker %s, want half-open", state)
+	}
+	want := "ERR server is read-only: disk storage is failing, writes are rejected"
+	for i := 0; i < 3; i++ {
+		if got := ts.do("SET", "key", "value"); got != want {
+			t.Fatalf("SET while half-open: got %q, want %q", got, want)
+		}
+	}
+
+	if err := breaker.Execute(func() error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if got := ts.do("SET", "key", "value"); got != "ok" {
+		t.Errorf("SET once closed: got %q", got)
+	}
+}
@@ -0,0 +1,127 @@
+package server_test
+
+import (
+	"concurrency_hw1/internal/compute"
+	"concurrency_hw1/internal/config"
+	"concurrency_hw1/internal/server"
+	"concurrency_hw1/internal/storage"
+	"concurrency_hw1/pkg/logger"
+	"context"
+	"net"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testServer is a server listening on a unix socket once started, it keeps
+// the records it logs.
+type testServer struct {
+	*server.Server
+	cfg     *config.Config
+	address string
+	ctx     context.Context
+	wg      *sync.WaitGroup
+
+	mu      sync.Mutex
+	records []string
+}
+
+func newTestServer(t *testing.T, cfg *config.Config, options ...server.ServerOption) *testServer {
+	t.Helper()
+	return newTestServerOn(t, storage.NewEngine(), cfg, options...)
+}
+
+// newTestServerOn is newTestServer keeping its data in engine.
+func newTestServerOn(t *testing.T, engine storage.EngineInterface, cfg *config.Config, options ...server.ServerOption) *testServer {
+	t.Helper()
+	if cfg == nil {
+		cfg = config.Default()
+	}
+	cfg.Network.Address = "unix://" + filepath.Join(t.TempDir(), "kv.sock")
+	cfg.Network.CommandTimeout = 0
+
+	ts := &testServer{cfg: cfg, address: strings.TrimPrefix(cfg.Network.Address, "unix://")}
+	walCh := make(chan []byte)
+	ts.Server = server.NewServer(logger.New("error", ""), compute.NewParser(), engine, walCh, cfg, options...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	ts.ctx, ts.wg = ctx, &wg
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case record := <-walCh:
+				ts.mu.Lock()
+				ts.records = append(ts.records, strings.TrimSuffix(string(record), "\n"))
+				ts.mu.Unlock()
+			}
+		}
+	}()
+	t.Cleanup(func() {
+		cancel()
+		wg.Wait()
+	})
+	return ts
+}
+
+// start serves connections, the WAL is replayed before.
+func (ts *testServer) start() {
+	ts.wg.Add(1)
+	go func() {
+		defer ts.wg.Done()
+		ts.Execute(ts.ctx)
+	}()
+}
+
+// dial connects to a started server.
+func (ts *testServer) dial(t *testing.T) net.Conn {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		connection, err := net.Dial("unix", ts.address)
+		if err == nil {
+			t.Cleanup(func() { connection.Close() })
+			return connection
+		}
+		if time.Now().After(deadline) {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (ts *testServer) do(command string, args ...string) string {
+	return ts.Dispatch(context.Background(), command, args)
+}
+
+// logged returns the records once there are at least n of them, the
+// records are taken off the WAL channel right after they are sent.
+func (ts *testServer) logged(n int) []string {
+	deadline := time.Now().Add(time.Second)
+	for {
+		ts.mu.Lock()
+		records := slices.Clone(ts.records)
+		ts.mu.Unlock()
+		if len(records) >= n || time.Now().After(deadline) {
+			return records
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// info returns a field of INFO.
+func (ts *testServer) info(field string) string {
+	for _, line := range strings.Split(ts.do("INFO"), "\n") {
+		if name, value, ok := strings.Cut(line, ":"); ok && name == field {
+			return value
+		}
+	}
+	return ""
+}
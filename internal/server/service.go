@@ -4,60 +4,283 @@ import (
 	"bufio"
 	"concurrency_hw1/internal/compute"
 	"concurrency_hw1/internal/config"
+	"concurrency_hw1/internal/keyspace"
+	"concurrency_hw1/internal/pubsub"
 	"concurrency_hw1/internal/storage"
+	"concurrency_hw1/pkg/common"
 	"concurrency_hw1/pkg/concurrency"
 	"concurrency_hw1/pkg/logger"
 	"concurrency_hw1/pkg/network"
 
 	"context"
+	"errors"
+	"fmt"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
-	setCommand  = "SET"
-	getCommand  = "GET"
-	delCommand  = "DEL"
-	helpCommand = "help"
-	exitCommand = "exit"
-	guide       = "query = set_command | get_command | del_command \n set_command = \"SET\" argument argument \n get_command = \"GET\" argument \n del_command = \"DEL\" argument \n argument    = punctuation | letter | digit { punctuation | letter | digit } \n punctuation = \"*\" | \"/\" | \"_\" | ... \n letter      = \"a\" | ... | \"z\" | \"A\" | ... | \"Z\" \n digit       = \"0\" | ... | \"9\" \n exit_command = \"exit\""
+	setCommand           = "SET"
+	getCommand           = "GET"
+	delCommand           = "DEL"
+	mgetCommand          = "MGET"
+	msetCommand          = "MSET"
+	mdelCommand          = "MDEL"
+	existsCommand        = "EXISTS"
+	keysCommand          = "KEYS"
+	scanCommand          = "SCAN"
+	rangeCommand         = "RANGE"
+	revRangeCommand      = "REVRANGE"
+	prefixCommand        = "PREFIX"
+	revPrefixCommand     = "REVPREFIX"
+	typeCommand          = "TYPE"
+	lpushCommand         = "LPUSH"
+	rpushCommand         = "RPUSH"
+	lpopCommand          = "LPOP"
+	rpopCommand          = "RPOP"
+	blpopCommand         = "BLPOP"
+	brpopCommand         = "BRPOP"
+	lrangeCommand        = "LRANGE"
+	hsetCommand          = "HSET"
+	hgetCommand          = "HGET"
+	hdelCommand          = "HDEL"
+	hgetallCommand       = "HGETALL"
+	saddCommand          = "SADD"
+	sremCommand          = "SREM"
+	smembersCommand      = "SMEMBERS"
+	sismemberCommand     = "SISMEMBER"
+	zaddCommand          = "ZADD"
+	zremCommand          = "ZREM"
+	zscoreCommand        = "ZSCORE"
+	zincrbyCommand       = "ZINCRBY"
+	zrankCommand         = "ZRANK"
+	zrangeCommand        = "ZRANGE"
+	zrangeByScoreCommand = "ZRANGEBYSCORE"
+	incrCommand          = "INCR"
+	incrByCommand        = "INCRBY"
+	decrCommand          = "DECR"
+	appendCommand        = "APPEND"
+	getSetCommand        = "GETSET"
+	setNXCommand         = "SETNX"
+	casCommand           = "CAS"
+	subscribeCommand     = "SUBSCRIBE"
+	psubscribeCommand    = "PSUBSCRIBE"
+	unsubscribeCommand   = "UNSUBSCRIBE"
+	punsubscribeCommand  = "PUNSUBSCRIBE"
+	publishCommand       = "PUBLISH"
+	watchCommand         = "WATCH"
+	pingCommand          = "PING"
+	readOnlyCommand      = "READONLY"
+	infoCommand          = "INFO"
+	configCommand        = "CONFIG"
+	slowLogCommand       = "SLOWLOG"
+	helpCommand          = "help"
+	exitCommand          = "exit"
+	guide                = "query = set_command | get_command | del_command | mget_command | mset_command | mdel_command | exists_command | keys_command | scan_command | range_command | prefix_command | type_command | counter_command | list_command | hash_command | set_type_command | zset_command | pubsub_command | watch_command | ping_command | admin_command \n" +
+		" set_command = \"SET\" argument argument \n" +
+		" get_command = \"GET\" argument \n" +
+		" del_command = \"DEL\" argument \n" +
+		" mget_command = \"MGET\" argument { argument } \n" +
+		" mset_command = \"MSET\" argument argument { argument argument } \n" +
+		" mdel_command = \"MDEL\" argument { argument } \n" +
+		" exists_command = \"EXISTS\" argument { argument } \n" +
+		" keys_command = \"KEYS\" pattern \n" +
+		" scan_command = \"SCAN\" cursor [ \"MATCH\" pattern ] [ \"COUNT\" digit { digit } ] \n" +
+		" range_command = ( \"RANGE\" | \"REVRANGE\" ) argument argument [ \"LIMIT\" digit { digit } ] (ordered engine only) \n" +
+		" prefix_command = ( \"PREFIX\" | \"REVPREFIX\" ) argument [ \"LIMIT\" digit { digit } ] (ordered engine only) \n" +
+		" type_command = \"TYPE\" argument \n" +
+		" counter_command = ( \"INCR\" | \"DECR\" ) argument | \"INCRBY\" argument index | ( \"APPEND\" | \"GETSET\" | \"SETNX\" ) argument argument \n" +
+		"   | \"CAS\" argument argument argument \n" +
+		" list_command = ( \"LPUSH\" | \"RPUSH\" ) argument argument { argument } | ( \"LPOP\" | \"RPOP\" ) argument | \"LRANGE\" argument index index \n" +
+		"   | ( \"BLPOP\" | \"BRPOP\" ) argument { argument } timeout \n" +
+		" hash_command = \"HSET\" argument argument argument { argument argument } | \"HGET\" argument argument | \"HDEL\" argument argument { argument } | \"HGETALL\" argument \n" +
+		" set_type_command = ( \"SADD\" | \"SREM\" ) argument argument { argument } | \"SMEMBERS\" argument | \"SISMEMBER\" argument argument \n" +
+		" zset_command = \"ZADD\" argument score argument { score argument } | \"ZREM\" argument argument { argument } | ( \"ZSCORE\" | \"ZRANK\" ) argument argument | \"ZINCRBY\" argument score argument \n" +
+		"   | \"ZRANGE\" argument index index [ \"WITHSCORES\" ] | \"ZRANGEBYSCORE\" argument bound bound [ \"WITHSCORES\" ] [ \"LIMIT\" digit { digit } index ] \n" +
+		" pubsub_command = ( \"SUBSCRIBE\" | \"PSUBSCRIBE\" ) argument { argument } | ( \"UNSUBSCRIBE\" | \"PUNSUBSCRIBE\" ) { argument } | \"PUBLISH\" argument argument { argument } \n" +
+		" watch_command = \"WATCH\" pattern [ \"SINCE\" digit { digit } ] \n" +
+		" ping_command = \"PING\" \n" +
+		" admin_command = \"READONLY\" ( \"yes\" | \"no\" ) | \"INFO\" [ \"server\" | \"clients\" | \"memory\" | \"workers\" ] \n" +
+		"   | \"CONFIG\" ( \"GET\" pattern | \"SET\" argument argument | \"REWRITE\" ) \n" +
+		"   | \"SLOWLOG\" ( \"GET\" [ digit { digit } ] | \"LEN\" | \"RESET\" ) \n" +
+		" argument    = punctuation | letter | digit { punctuation | letter | digit } \n" +
+		" pattern     = argument with glob wildcards \"*\" | \"?\" | \"[...]\" \n" +
+		" punctuation = \"*\" | \"/\" | \"_\" | ... \n" +
+		" letter      = \"a\" | ... | \"z\" | \"A\" | ... | \"Z\" \n" +
+		" index       = [ \"-\" ] digit { digit } \n" +
+		" score       = float | \"-inf\" | \"+inf\" \n" +
+		" timeout     = digit { digit } [ \".\" digit { digit } ] \n" +
+		" bound       = [ \"(\" ] score \n" +
+		" digit       = \"0\" | ... | \"9\" \n" +
+		" a missing key or value is answered with \" \", in MGET on its own line, a timed out BLPOP or BRPOP with \"(nil)\" \n" +
+		" exit_command = \"exit\""
 )
 
-type commandFunc func(args []string) string
+type commandFunc func(ctx context.Context, args []string) string
+
+// stateFunc handles a write whose outcome depends on the stored value. It
+// returns the command to log in the WAL instead of the request, so replay
+// sets the resulting state, or nil if nothing was changed.
+type stateFunc func(args []string) (response string, record []string)
 
 type CommandDefinition struct {
 	minArgs int
-	handler commandFunc
-	isWAL   bool
+	// maxArgs is set for commands taking a fixed number of arguments, so a
+	// mangled WAL record is not replayed with its extra arguments ignored
+	maxArgs int
+	// validate checks arguments before the command is written to the WAL
+	validate func(args []string) error
+	handler  commandFunc
+	// stateHandler replaces handler for commands logged as their result
+	stateHandler stateFunc
+	isWAL        bool
+	// blocking commands may wait for other requests, they never run on the
+	// worker pool
+	blocking bool
+	// mutates is set for commands changing the server outside the WAL, e.g.
+	// its config or subscriptions
+	mutates bool
+}
+
+// abandonable reports whether the command may be answered with a timeout
+// while it still runs. Writes are not, they would take effect after the
+// client was told they failed, and blocking commands have their own
+// timeout.
+func (d CommandDefinition) abandonable() bool {
+	return !d.isWAL && !d.blocking && !d.mutates
+}
+
+type ServerOption func(*Server)
+
+// WithWorkerPool runs commands on the pool instead of the goroutines of the
+// connections, so the number of connections does not decide how many
+// commands run at once. The caller shuts the pool down after the server.
+func WithWorkerPool(pool *concurrency.WorkerPool) ServerOption {
+	return func(server *Server) {
+		server.workers = pool
+	}
+}
+
+// WithDiskBreaker makes the server read-only until the breaker of the disk
+// storage of the WAL is closed again.
+func WithDiskBreaker(breaker *concurrency.CircuitBreaker) ServerOption {
+	return func(server *Server) {
+		server.diskBreaker = breaker
+	}
+}
+
+// WithConfigFile is the YAML file CONFIG REWRITE writes to.
+func WithConfigFile(fileName string) ServerOption {
+	return func(server *Server) {
+		server.configFile = fileName
+	}
+}
+
+// WithWALBatcher lets the batch settings of the WAL change live.
+func WithWALBatcher(batcher WALBatcher) ServerOption {
+	return func(server *Server) {
+		server.walBatcher = batcher
+	}
+}
+
+// WithReadOnlyMarker keeps the read-only mode in a file at path, the server
+// starts read-only while it exists.
+func WithReadOnlyMarker(path string) ServerOption {
+	return func(server *Server) {
+		server.readOnlyMarker = path
+	}
 }
 
 type Server struct {
-	config    *config.Config
-	logger    logger.LoggerInterface
-	reader    *bufio.Reader
-	parser    compute.ParserInterface
-	engine    storage.EngineInterface
-	walCh     chan ([]byte)
-	server    network.ServerInterface
-	commands  map[string]CommandDefinition
-	semaphore concurrency.Semaphore
-}
-
-func NewServer(logger logger.LoggerInterface, parser compute.ParserInterface, engine storage.EngineInterface, walCh chan ([]byte), config *config.Config) *Server {
+	// config is the current config, changed by ApplyConfig under configMu.
+	// resolved is the config as last resolved from its sources, CONFIG
+	// REWRITE writes only the settings changed at runtime since.
+	configMu   sync.Mutex
+	config     *config.Config
+	resolved   *config.Config
+	configFile string
+	walBatcher WALBatcher
+	logger     logger.LoggerInterface
+	reader     *bufio.Reader
+	parser     compute.ParserInterface
+	engine     storage.EngineInterface
+	walCh      chan ([]byte)
+	server     network.ServerInterface
+	commands   map[string]CommandDefinition
+	// requestMemory admits requests while the bytes of the requests in
+	// flight fit into max_request_memory
+	requestMemory *concurrency.Semaphore
+	workers       *concurrency.WorkerPool
+	// commandTimeouts are the deadlines of commands by name, the default
+	// one is under the empty name
+	commandTimeouts map[string]time.Duration
+	diskBreaker     *concurrency.CircuitBreaker
+	// readOnly is set by READONLY, it is changed under walMu so no write is
+	// in progress when it is set
+	readOnly       atomic.Bool
+	readOnlyMarker string
+	startedAt      time.Time
+	slowLog        slowLog
+
+	// walMu keeps the WAL in the order writes are applied, lsn is the number
+	// of the last record and pendingEvents are the changes made by the
+	// current write, all of them are guarded by walMu. A write may log
+	// several records, e.g. a push and the pops it serves, so every event
+	// gets the LSN of the record logged after it was made.
+	walMu         sync.Mutex
+	lsn           uint64
+	pendingEvents []keyspace.Event
+	// waiters are the blocked pops of every key in arrival order, blocked
+	// is how many pops wait and pushedKeys are the keys the current write
+	// pushed to, guarded by walMu
+	waiters    map[string][]*popWaiter
+	blocked    int
+	pushedKeys []string
+
+	hub             *pubsub.Hub
+	subscriptionsMu sync.Mutex
+	subscriptions   map[*network.Conn]subscription
+	keyspace        *keyspace.Bus
+}
+
+func NewServer(logger logger.LoggerInterface, parser compute.ParserInterface, engine storage.EngineInterface, walCh chan ([]byte), config *config.Config, options ...ServerOption) *Server {
 	server, err := network.NewServer(config, logger)
 	if err != nil {
 		logger.Fatal(err)
 	}
+	memorySize, err := requestMemorySize(config)
+	if err != nil {
+		logger.Fatal(err)
+	}
 
 	s := &Server{
-		config:    config,
-		logger:    logger,
-		reader:    bufio.NewReader(os.Stdin),
-		parser:    parser,
-		engine:    engine,
-		walCh:     walCh,
-		server:    server,
-		semaphore: concurrency.NewSemaphore(config.Network.MaxConnections),
+		config:          config.Clone(),
+		resolved:        config.Clone(),
+		logger:          logger,
+		reader:          bufio.NewReader(os.Stdin),
+		parser:          parser,
+		engine:          engine,
+		walCh:           walCh,
+		server:          server,
+		requestMemory:   new(concurrency.Semaphore),
+		commandTimeouts: commandTimeouts(config),
+		startedAt:       time.Now(),
+
+		hub:           pubsub.NewHub(subscriberQueueSize(config), logger),
+		subscriptions: make(map[*network.Conn]subscription),
+		waiters:       make(map[string][]*popWaiter),
+	}
+	for _, option := range options {
+		option(s)
 	}
+	s.requestMemory.Resize(memorySize)
+	s.slowLog.configure(slowLogLimits(config))
+	s.keyspace = keyspace.NewBus(s.hub, keyspaceHistorySize(config))
+	s.loadReadOnly()
+	engine.SetNotifier(s.collectEvent)
 	s.initCommands()
 
 	return s
@@ -65,25 +288,171 @@ func NewServer(logger logger.LoggerInterface, parser compute.ParserInterface, en
 
 func (s *Server) initCommands() {
 	s.commands = map[string]CommandDefinition{
-		setCommand:  {minArgs: 2, handler: s.handleSet, isWAL: true},
-		getCommand:  {minArgs: 1, handler: s.handleGet, isWAL: false},
-		delCommand:  {minArgs: 1, handler: s.handleDel, isWAL: true},
-		helpCommand: {minArgs: 0, handler: s.handleHelp, isWAL: false},
+		setCommand:    {minArgs: 2, maxArgs: 2, handler: s.handleSet, isWAL: true},
+		getCommand:    {minArgs: 1, handler: s.handleGet, isWAL: false},
+		delCommand:    {minArgs: 1, maxArgs: 1, handler: s.handleDel, isWAL: true},
+		mgetCommand:   {minArgs: 1, handler: s.handleMGet, isWAL: false},
+		msetCommand:   {minArgs: 2, validate: validatePairs, handler: s.handleMSet, isWAL: true},
+		mdelCommand:   {minArgs: 1, handler: s.handleMDel, isWAL: true},
+		existsCommand: {minArgs: 1, handler: s.handleExists, isWAL: false},
+		keysCommand:   {minArgs: 1, handler: s.handleKeys, isWAL: false},
+		scanCommand:   {minArgs: 1, handler: s.handleScan, isWAL: false},
+		typeCommand:   {minArgs: 1, handler: s.handleType, isWAL: false},
+
+		incrCommand:     {minArgs: 1, maxArgs: 1, stateHandler: s.incrHandler(1), isWAL: true},
+		decrCommand:     {minArgs: 1, maxArgs: 1, stateHandler: s.incrHandler(-1), isWAL: true},
+		incrByCommand:   {minArgs: 2, maxArgs: 2, validate: validateInteger, stateHandler: s.handleIncrBy, isWAL: true},
+		appendCommand:   {minArgs: 2, maxArgs: 2, stateHandler: s.handleAppend, isWAL: true},
+		getSetCommand:   {minArgs: 2, maxArgs: 2, stateHandler: s.handleGetSet, isWAL: true},
+		setNXCommand:    {minArgs: 2, maxArgs: 2, stateHandler: s.handleSetNX, isWAL: true},
+		casCommand:      {minArgs: 3, maxArgs: 3, stateHandler: s.handleCAS, isWAL: true},
+		pingCommand:     {minArgs: 0, handler: s.handlePing, isWAL: false},
+		readOnlyCommand: {minArgs: 1, validate: validateReadOnly, handler: s.handleReadOnly, isWAL: false, mutates: true},
+		infoCommand:     {minArgs: 0, validate: validateInfo, handler: s.handleInfo, isWAL: false},
+		configCommand:   {minArgs: 1, validate: validateConfig, handler: s.handleConfig, isWAL: false, mutates: true},
+		slowLogCommand:  {minArgs: 1, validate: validateSlowLog, handler: s.handleSlowLog, isWAL: false, mutates: true},
+		helpCommand:     {minArgs: 0, handler: s.handleHelp, isWAL: false},
+
+		subscribeCommand:    {minArgs: 1, handler: s.handleSubscribe, isWAL: false, mutates: true},
+		psubscribeCommand:   {minArgs: 1, handler: s.handlePSubscribe, isWAL: false, mutates: true},
+		unsubscribeCommand:  {minArgs: 0, handler: s.handleUnsubscribe, isWAL: false, mutates: true},
+		punsubscribeCommand: {minArgs: 0, handler: s.handlePUnsubscribe, isWAL: false, mutates: true},
+		publishCommand:      {minArgs: 2, handler: s.handlePublish, isWAL: false, mutates: true},
+		watchCommand:        {minArgs: 1, validate: validateWatch, handler: s.handleWatch, isWAL: false, mutates: true},
+
+		lpushCommand:         {minArgs: 2, handler: s.handleLPush, isWAL: true},
+		rpushCommand:         {minArgs: 2, handler: s.handleRPush, isWAL: true},
+		lpopCommand:          {minArgs: 1, maxArgs: 1, handler: s.handleLPop, isWAL: true},
+		rpopCommand:          {minArgs: 1, maxArgs: 1, handler: s.handleRPop, isWAL: true},
+		blpopCommand:         {minArgs: 2, validate: validateBlockingPop, handler: s.handleBLPop, isWAL: false, blocking: true},
+		brpopCommand:         {minArgs: 2, validate: validateBlockingPop, handler: s.handleBRPop, isWAL: false, blocking: true},
+		lrangeCommand:        {minArgs: 3, validate: validateRange, handler: s.handleLRange, isWAL: false},
+		hsetCommand:          {minArgs: 3, validate: validateFieldPairs, handler: s.handleHSet, isWAL: true},
+		hgetCommand:          {minArgs: 2, handler: s.handleHGet, isWAL: false},
+		hdelCommand:          {minArgs: 2, handler: s.handleHDel, isWAL: true},
+		hgetallCommand:       {minArgs: 1, handler: s.handleHGetAll, isWAL: false},
+		saddCommand:          {minArgs: 2, handler: s.handleSAdd, isWAL: true},
+		sremCommand:          {minArgs: 2, handler: s.handleSRem, isWAL: true},
+		smembersCommand:      {minArgs: 1, handler: s.handleSMembers, isWAL: false},
+		sismemberCommand:     {minArgs: 2, handler: s.handleSIsMember, isWAL: false},
+		zaddCommand:          {minArgs: 3, validate: validateScorePairs, handler: s.handleZAdd, isWAL: true},
+		zremCommand:          {minArgs: 2, handler: s.handleZRem, isWAL: true},
+		zscoreCommand:        {minArgs: 2, handler: s.handleZScore, isWAL: false},
+		zincrbyCommand:       {minArgs: 3, maxArgs: 3, validate: validateScoreIncrement, handler: s.handleZIncrBy, isWAL: true},
+		zrankCommand:         {minArgs: 2, handler: s.handleZRank, isWAL: false},
+		zrangeCommand:        {minArgs: 3, validate: validateRange, handler: s.handleZRange, isWAL: false},
+		zrangeByScoreCommand: {minArgs: 3, validate: validateScoreRange, handler: s.handleZRangeByScore, isWAL: false},
+	}
+
+	if engine, ok := s.engine.(storage.OrderedEngineInterface); ok {
+		s.commands[rangeCommand] = CommandDefinition{minArgs: 2, handler: s.rangeHandler(engine, false), isWAL: false}
+		s.commands[revRangeCommand] = CommandDefinition{minArgs: 2, handler: s.rangeHandler(engine, true), isWAL: false}
+		s.commands[prefixCommand] = CommandDefinition{minArgs: 1, handler: s.prefixHandler(engine, false), isWAL: false}
+		s.commands[revPrefixCommand] = CommandDefinition{minArgs: 1, handler: s.prefixHandler(engine, true), isWAL: false}
 	}
 }
 
 func (s *Server) Execute(ctx context.Context) error {
+	// changes recovered from the WAL were never published
+	s.walMu.Lock()
+	s.keyspace.Reset(s.lsn)
+	s.walMu.Unlock()
 	s.server.Execute(ctx, s.handleRequest)
 	return nil
 }
 
 func (s *Server) handleRequest(ctx context.Context, request []byte) []byte {
 	s.logger.Info("handleRequest request: %v", string(request))
+	release, err := s.admitRequest(ctx, len(request))
+	if err != nil {
+		return nil
+	}
+	defer release()
+
 	command, args, err := s.parser.Parse(string(request))
 	if err != nil {
 		s.logger.Error(err)
 		return nil
 	}
 
-	return []byte(s.dispatchCommand(command, args))
+	// in push mode every response is a line, like the pushed messages
+	if s.subscribed(ctx) {
+		if _, ok := subscribedCommands[command]; !ok {
+			return []byte(errorResponse("only (P)SUBSCRIBE, (P)UNSUBSCRIBE, WATCH and PING are allowed in push mode") + "\n")
+		}
+		if response := s.dispatchCommand(ctx, command, args); response != "" {
+			return []byte(strings.TrimSuffix(response, "\n") + "\n")
+		}
+		return nil
+	}
+
+	return []byte(s.dispatchCommand(ctx, command, args))
+}
+
+// Dispatch runs a command that did not come from the TCP protocol, e.g.
+// from the HTTP gateway, through the same command table.
+func (s *Server) Dispatch(ctx context.Context, command string, args []string) string {
+	s.logger.Info("dispatch request: %s %v", command, args)
+	size := len(command)
+	for _, arg := range args {
+		size += len(arg) + 1
+	}
+	release, err := s.admitRequest(ctx, size)
+	if err != nil {
+		return errorResponse("%v", err)
+	}
+	defer release()
+
+	return s.dispatchCommand(ctx, command, args)
+}
+
+// admitRequest waits until a request of size bytes fits into the request
+// memory. A request larger than the whole memory waits for all of it.
+func (s *Server) admitRequest(ctx context.Context, size int) (func(), error) {
+	for {
+		weight := min(int64(size), s.requestMemory.Size())
+		err := s.requestMemory.Acquire(ctx, weight)
+		if errors.Is(err, concurrency.ErrWeightTooLarge) {
+			// the memory was shrunk by CONFIG SET in the meantime
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return func() { s.requestMemory.Release(weight) }, nil
+	}
+}
+
+func requestMemorySize(cfg *config.Config) (int64, error) {
+	if cfg.Network.MaxRequestMemory == "" {
+		return 0, nil
+	}
+
+	size, err := common.ParseSize(cfg.Network.MaxRequestMemory)
+	if err != nil {
+		return 0, fmt.Errorf("incorrect max request memory: %w", err)
+	}
+	return int64(size), nil
+}
+
+func commandTimeouts(cfg *config.Config) map[string]time.Duration {
+	timeouts := map[string]time.Duration{"": cfg.Network.CommandTimeout}
+	for command, timeout := range cfg.Network.CommandTimeouts {
+		timeouts[strings.ToUpper(command)] = timeout
+	}
+	return timeouts
+}
+
+func keyspaceHistorySize(cfg *config.Config) int {
+	if cfg.PubSub == nil {
+		return keyspace.DefaultHistorySize
+	}
+	return cfg.PubSub.KeyspaceHistorySize
+}
+
+func subscriberQueueSize(cfg *config.Config) int {
+	if cfg.PubSub == nil {
+		return pubsub.DefaultQueueSize
+	}
+	return cfg.PubSub.SubscriberQueueSize
 }
@@ -0,0 +1,219 @@
+package server
+
+import (
+	"concurrency_hw1/internal/config"
+	"concurrency_hw1/pkg/network"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultSlowLogCount = 10
+	// long requests are cut so the log does not keep their values
+	maxSlowLogArgs   = 16
+	maxSlowLogArgLen = 64
+	redactedArg      = "(redacted)"
+)
+
+type slowLogEntry struct {
+	id       uint64
+	time     time.Time
+	duration time.Duration
+	command  string
+	args     []string
+	client   string
+}
+
+// slowLog is a ring buffer of the last commands that ran longer than the
+// threshold.
+type slowLog struct {
+	// threshold is checked by every command without taking mu
+	threshold atomic.Int64
+
+	mu sync.Mutex
+	// entries are the ring, next is where the next entry goes and count is
+	// how many of them are used
+	entries []slowLogEntry
+	next    int
+	count   int
+	lastID  uint64
+}
+
+// configure sets the threshold and the size, the newest entries are kept.
+func (l *slowLog) configure(threshold time.Duration, maxLen int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.threshold.Store(int64(threshold))
+	if maxLen == len(l.entries) {
+		return
+	}
+	newest := l.newest(maxLen)
+	l.entries = make([]slowLogEntry, maxLen)
+	l.count = len(newest)
+	for i, entry := range newest {
+		l.entries[l.count-1-i] = entry
+	}
+	l.next = 0
+	if maxLen > 0 {
+		l.next = l.count % maxLen
+	}
+}
+
+// add keeps the entry in place of the oldest one once the ring is full.
+func (l *slowLog) add(entry slowLogEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.entries) == 0 {
+		return
+	}
+	l.lastID++
+	entry.id = l.lastID
+	l.entries[l.next] = entry
+	l.next = (l.next + 1) % len(l.entries)
+	l.count = min(l.count+1, len(l.entries))
+}
+
+// newest returns up to n entries, the newest first. The caller holds mu.
+func (l *slowLog) newest(n int) []slowLogEntry {
+	n = min(n, l.count)
+	entries := make([]slowLogEntry, 0, n)
+	for i := 1; i <= n; i++ {
+		entries = append(entries, l.entries[(l.next-i+len(l.entries))%len(l.entries)])
+	}
+	return entries
+}
+
+func (l *slowLog) get(n int) []slowLogEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.newest(n)
+}
+
+func (l *slowLog) len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.count
+}
+
+func (l *slowLog) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.count = 0
+	l.next = 0
+	clear(l.entries)
+}
+
+// logSlow records the command in the slow log if it ran longer than the
+// threshold since start.
+func (s *Server) logSlow(ctx context.Context, command string, args []string, start time.Time) {
+	duration := time.Since(start)
+	if threshold := time.Duration(s.slowLog.threshold.Load()); threshold <= 0 || duration <= threshold {
+		return
+	}
+	s.slowLog.add(slowLogEntry{
+		time:     start,
+		duration: duration,
+		command:  command,
+		args:     truncateArgs(redactArgs(command, args)),
+		client:   network.RemoteAddrFromContext(ctx),
+	})
+}
+
+// redactArgs hides the values of CONFIG SET, the slow log is readable by
+// every client.
+func redactArgs(command string, args []string) []string {
+	const from = 2
+	if command != configCommand || len(args) <= from || !strings.EqualFold(args[0], "SET") {
+		return args
+	}
+
+	redacted := append(make([]string, 0, len(args)), args[:from]...)
+	for range args[from:] {
+		redacted = append(redacted, redactedArg)
+	}
+	return redacted
+}
+
+func truncateArgs(args []string) []string {
+	truncated := make([]string, 0, min(len(args), maxSlowLogArgs+1))
+	for i, arg := range args {
+		if i == maxSlowLogArgs {
+			truncated = append(truncated, fmt.Sprintf("...(%d more arguments)", len(args)-i))
+			break
+		}
+		if len(arg) > maxSlowLogArgLen {
+			arg = fmt.Sprintf("%s...(%d more bytes)", arg[:maxSlowLogArgLen], len(arg)-maxSlowLogArgLen)
+		}
+		truncated = append(truncated, arg)
+	}
+	return truncated
+}
+
+func validateSlowLog(args []string) error {
+	switch strings.ToUpper(args[0]) {
+	case "GET":
+		if len(args) > 2 {
+			return errors.New("SLOWLOG GET expects at most one argument")
+		}
+		if len(args) == 2 {
+			if n, err := strconv.Atoi(args[1]); err != nil || n < 0 {
+				return errors.New("count must be a non-negative integer")
+			}
+		}
+	case "LEN", "RESET":
+		if len(args) != 1 {
+			return fmt.Errorf("SLOWLOG %s expects no arguments", strings.ToUpper(args[0]))
+		}
+	default:
+		return fmt.Errorf("unknown subcommand %q, expected GET, LEN or RESET", args[0])
+	}
+	return nil
+}
+
+// handleSlowLog reads the slow log. GET answers a line of the newest
+// entries each, 10 of them by default:
+// "id timestamp duration client command args...".
+func (s *Server) handleSlowLog(ctx context.Context, args []string) string {
+	switch strings.ToUpper(args[0]) {
+	case "LEN":
+		return strconv.Itoa(s.slowLog.len())
+	case "RESET":
+		s.slowLog.reset()
+		return "ok"
+	}
+
+	n := defaultSlowLogCount
+	if len(args) == 2 {
+		n, _ = strconv.Atoi(args[1])
+	}
+	var lines []string
+	for _, entry := range s.slowLog.get(n) {
+		client := entry.client
+		if client == "" {
+			client = "-"
+		}
+		fields := []string{
+			strconv.FormatUint(entry.id, 10),
+			entry.time.UTC().Format(time.RFC3339Nano),
+			entry.duration.String(),
+			client,
+			entry.command,
+		}
+		lines = append(lines, strings.Join(append(fields, entry.args...), " "))
+	}
+	return joinLines(lines)
+}
+
+func slowLogLimits(cfg *config.Config) (time.Duration, int) {
+	if cfg.SlowLog == nil {
+		cfg = config.Default()
+	}
+	return cfg.SlowLog.SlowerThan, cfg.SlowLog.MaxLen
+}
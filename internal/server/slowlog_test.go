@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// commands returns the commands of the newest entries.
+func (l *slowLog) commands(n int) []string {
+	var commands []string
+	for _, entry := range l.get(n) {
+		commands = append(commands, entry.command)
+	}
+	return commands
+}
+
+func addSlow(l *slowLog, commands ...string) {
+	for _, command := range commands {
+		l.add(slowLogEntry{command: command})
+	}
+}
+
+func TestSlowLogRing(t *testing.T) {
+	var l slowLog
+	l.configure(time.Millisecond, 3)
+	addSlow(&l, "1", "2", "3", "4", "5")
+
+	steps := []struct {
+		name   string
+		action func()
+		want   []string
+	}{
+		{name: "wrapped around", action: func() {}, want: []string{"5", "4", "3"}},
+		{name: "shrunk", action: func() { l.configure(time.Millisecond, 2) }, want: []string{"5", "4"}},
+		{name: "added after shrinking", action: func() { addSlow(&l, "6") }, want: []string{"6", "5"}},
+		{name: "grown", action: func() { l.configure(time.Millisecond, 4) }, want: []string{"6", "5"}},
+		{name: "added after growing", action: func() { addSlow(&l, "7", "8", "9") }, want: []string{"9", "8", "7", "6"}},
+		{name: "disabled", action: func() { l.configure(time.Millisecond, 0); addSlow(&l, "10") }, want: nil},
+		{name: "enabled again", action: func() { l.configure(time.Millisecond, 2); addSlow(&l, "11") }, want: []string{"11"}},
+	}
+	for _, step := range steps {
+		step.action()
+		if got := l.commands(10); !slices.Equal(got, step.want) {
+			t.Fatalf("%s: got %v, want %v", step.name, got, step.want)
+		}
+		if l.len() != len(step.want) {
+			t.Fatalf("%s: got len %d, want %d", step.name, l.len(), len(step.want))
+		}
+	}
+	if got := l.commands(1); !slices.Equal(got, []string{"11"}) {
+		t.Errorf("got %v, want the newest entry", got)
+	}
+}
+
+func TestSlowLogCommand(t *testing.T) {
+	s := &Server{}
+	s.slowLog.configure(time.Millisecond, 10)
+	ctx := context.Background()
+	slow := time.Now().Add(-time.Second)
+
+	s.logSlow(ctx, getCommand, []string{"fast"}, time.Now())
+	s.logSlow(ctx, getCommand, []string{"key"}, slow)
+	s.logSlow(ctx, configCommand, []string{"SET", "network.max_request_memory", "8MB"}, slow)
+	s.logSlow(ctx, msetCommand, []string{strings.Repeat("v", maxSlowLogArgLen+2)}, slow)
+
+	if got := s.handleSlowLog(ctx, []string{"LEN"}); got != "3" {
+		t.Errorf("LEN: got %q, want 3", got)
+	}
+	lines := strings.Split(s.handleSlowLog(ctx, []string{"GET"}), "\n")
+	want := []string{
+		fmt.Sprintf("MSET %s...(2 more bytes)", strings.Repeat("v", maxSlowLogArgLen)),
+		"CONFIG SET network.max_request_memory (redacted)",
+		"GET key",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("GET: got %q, want %d entries", lines, len(want))
+	}
+	for i, line := range lines {
+		// id timestamp duration client command args...
+		fields := strings.SplitN(line, " ", 5)
+		if len(fields) != 5 || fields[0] != fmt.Sprint(3-i) || fields[3] != "-" || fields[4] != want[i] {
+			t.Errorf("GET: got %q, want %q", line, want[i])
+		}
+	}
+	if got := s.handleSlowLog(ctx, []string{"GET", "1"}); strings.Count(got, "\n") != 0 || !strings.Contains(got, "MSET") {
+		t.Errorf("GET 1: got %q, want the newest entry", got)
+	}
+
+	if got := s.handleSlowLog(ctx, []string{"RESET"}); got != "ok" {
+		t.Errorf("RESET: got %q", got)
+	}
+	if got := s.handleSlowLog(ctx, []string{"LEN"}); got != "0" {
+		t.Errorf("LEN after RESET: got %q, want 0", got)
+	}
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"concurrency_hw1/internal/storage"
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const withScoresOption = "WITHSCORES"
+
+func (s *Server) handleZAdd(ctx context.Context, args []string) string {
+	members := make([]storage.ScoredMember, 0, len(args)/2)
+	for i := 1; i < len(args); i += 2 {
+		score, _ := parseScore(args[i])
+		members = append(members, storage.ScoredMember{Member: args[i+1], Score: score})
+	}
+	return countResponse(s.engine.ZAdd(args[0], members))
+}
+
+func (s *Server) handleZRem(ctx context.Context, args []string) string {
+	return countResponse(s.engine.ZRem(args[0], args[1:]...))
+}
+
+func (s *Server) handleZScore(ctx context.Context, args []string) string {
+	score, ok, err := s.engine.ZScore(args[0], args[1])
+	return valueResponse(formatScore(score), ok, err)
+}
+
+func (s *Server) handleZIncrBy(ctx context.Context, args []string) string {
+	increment, _ := parseScore(args[1])
+	score, err := s.engine.ZIncrBy(args[0], increment, args[2])
+	if err != nil {
+		return errorResponse("%v", err)
+	}
+	return formatScore(score)
+}
+
+func (s *Server) handleZRank(ctx context.Context, args []string) string {
+	rank, ok, err := s.engine.ZRank(args[0], args[1])
+	return valueResponse(strconv.Itoa(rank), ok, err)
+}
+
+// handleZRange answers with a member per line, "member score" lines with
+// WITHSCORES.
+func (s *Server) handleZRange(ctx context.Context, args []string) string {
+	start, _ := strconv.Atoi(args[1])
+	stop, _ := strconv.Atoi(args[2])
+	withScores, rest := parseWithScores(args[3:])
+	if len(rest) != 0 {
+		return errorResponse("unknown option: %s", rest[0])
+	}
+
+	members, err := s.engine.ZRange(args[0], start, stop)
+	if err != nil {
+		return errorResponse("%v", err)
+	}
+	return formatMembers(members, withScores)
+}
+
+func (s *Server) handleZRangeByScore(ctx context.Context, args []string) string {
+	r, _ := parseScoreRange(args[1], args[2])
+	withScores, rest := parseWithScores(args[3:])
+
+	offset, count := 0, 0
+	if len(rest) != 0 {
+		if len(rest) != 3 || strings.ToUpper(rest[0]) != "LIMIT" {
+			return errorResponse("expected LIMIT offset count")
+		}
+		var err error
+		if offset, err = strconv.Atoi(rest[1]); err != nil || offset < 0 {
+			return errorResponse("LIMIT offset must be a non-negative integer")
+		}
+		if count, err = strconv.Atoi(rest[2]); err != nil {
+			return errorResponse("LIMIT count must be an integer")
+		}
+	}
+
+	members, err := s.engine.ZRangeByScore(args[0], r, offset, count)
+	if err != nil {
+		return errorResponse("%v", err)
+	}
+	return formatMembers(members, withScores)
+}
+
+// validateScorePairs checks that a key is followed by score member pairs.
+func validateScorePairs(args []string) error {
+	if err := validateFieldPairs(args); err != nil {
+		return errors.New("arguments must be a key followed by score member pairs")
+	}
+	for i := 1; i < len(args); i += 2 {
+		if _, err := parseScore(args[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateScoreIncrement(args []string) error {
+	_, err := parseScore(args[1])
+	return err
+}
+
+func validateScoreRange(args []string) error {
+	_, err := parseScoreRange(args[1], args[2])
+	return err
+}
+
+func parseScore(arg string) (float64, error) {
+	score, err := strconv.ParseFloat(arg, 64)
+	if err != nil || math.IsNaN(score) {
+		return 0, errors.New("score is not a valid float")
+	}
+	return score, nil
+}
+
+// parseScoreRange parses min and max scores, a "(" prefix makes an end
+// exclusive and "-inf" and "+inf" are accepted.
+func parseScoreRange(min, max string) (storage.ScoreRange, error) {
+	var r storage.ScoreRange
+	var err error
+
+	min, r.MinExclusive = strings.CutPrefix(min, "(")
+	if r.Min, err = parseScore(min); err != nil {
+		return r, errors.New("min or max is not a float")
+	}
+	max, r.MaxExclusive = strings.CutPrefix(max, "(")
+	if r.Max, err = parseScore(max); err != nil {
+		return r, errors.New("min or max is not a float")
+	}
+	return r, nil
+}
+
+func parseWithScores(args []string) (bool, []string) {
+	if len(args) != 0 && strings.ToUpper(args[0]) == withScoresOption {
+		return true, args[1:]
+	}
+	return false, args
+}
+
+func formatScore(score float64) string {
+	return strconv.FormatFloat(score, 'g', -1, 64)
+}
+
+func formatMembers(members []storage.ScoredMember, withScores bool) string {
+	lines := make([]string, len(members))
+	for i, m := range members {
+		if withScores {
+			lines[i] = m.Member + " " + formatScore(m.Score)
+		} else {
+			lines[i] = m.Member
+		}
+	}
+	return joinLines(lines)
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"errors"
+	"math"
+	"strconv"
+)
+
+var (
+	ErrNotInteger = errors.New("value is not an integer or out of range")
+	ErrOverflow   = errors.New("increment or decrement would overflow")
+)
+
+// The operations below read and write a string key under one lock, so
+// concurrent clients do not need a GET followed by a SET.
+
+func (e *Engine) Incr(key string) (int64, error) {
+	return e.IncrBy(key, 1)
+}
+
+func (e *Engine) Decr(key string) (int64, error) {
+	return e.IncrBy(key, -1)
+}
+
+// IncrBy adds delta to the integer stored at key, a missing key counts as
+// zero. It returns the new value.
+func (e *Engine) IncrBy(key string, delta int64) (int64, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	v, err := e.lookup(key, typeString)
+	if err != nil {
+		return 0, err
+	}
+
+	var current int64
+	if v != nil {
+		if current, err = strconv.ParseInt(v.str, 10, 64); err != nil {
+			return 0, ErrNotInteger
+		}
+	}
+	if (delta > 0 && current > math.MaxInt64-delta) || (delta < 0 && current < math.MinInt64-delta) {
+		return 0, ErrOverflow
+	}
+
+	current += delta
+	e.put(key, stringValue(strconv.FormatInt(current, 10)))
+	return current, nil
+}
+
+// Append adds suffix to the string at key and returns the resulting value.
+func (e *Engine) Append(key, suffix string) (string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	v, err := e.lookup(key, typeString)
+	if err != nil {
+		return "", err
+	}
+
+	result := suffix
+	if v != nil {
+		result = v.str + suffix
+	}
+	e.put(key, stringValue(result))
+	return result, nil
+}
+
+// GetSet stores value and returns the previous one.
+func (e *Engine) GetSet(key, value string) (string, bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	v, err := e.lookup(key, typeString)
+	if err != nil {
+		return "", false, err
+	}
+
+	e.put(key, stringValue(value))
+	if v == nil {
+		return "", false, nil
+	}
+	return v.str, true, nil
+}
+
+// SetNX stores value only if the key does not exist and reports whether it
+// did.
+func (e *Engine) SetNX(key, value string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, ok := e.table.get(key); ok {
+		return false
+	}
+	e.put(key, stringValue(value))
+	return true
+}
+
+// CAS replaces the value at key with value only if it currently equals
+// expected and reports whether it did. A missing key never matches.
+func (e *Engine) CAS(key, expected, value string) (bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	v, err := e.lookup(key, typeString)
+	if err != nil || v == nil || v.str != expected {
+		return false, err
+	}
+
+	e.put(key, stringValue(value))
+	return true, nil
+}
@@ -0,0 +1,143 @@
+package storage_test
+
+import (
+	"concurrency_hw1/internal/storage"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestAtomicOperations(t *testing.T) {
+	e := storage.NewEngine()
+	e.Set("text", "abc")
+	e.Set("max", strconv.FormatInt(math.MaxInt64, 10))
+	e.LPush("list", "item")
+
+	tests := []struct {
+		name   string
+		action func() string
+		want   string
+	}{
+		{
+			name: "Incr of a missing key starts from zero",
+			action: func() string {
+				value, err := e.Incr("counter")
+				return fmt.Sprint(value, err)
+			},
+			want: "1 <nil>",
+		},
+		{
+			name: "IncrBy and Decr",
+			action: func() string {
+				e.IncrBy("counter", 10)
+				value, err := e.Decr("counter")
+				return fmt.Sprint(value, err)
+			},
+			want: "10 <nil>",
+		},
+		{
+			name: "Incr of a non integer",
+			action: func() string {
+				_, err := e.Incr("text")
+				return fmt.Sprint(errors.Is(err, storage.ErrNotInteger))
+			},
+			want: "true",
+		},
+		{
+			name: "Incr overflow",
+			action: func() string {
+				_, err := e.Incr("max")
+				return fmt.Sprint(errors.Is(err, storage.ErrOverflow))
+			},
+			want: "true",
+		},
+		{
+			name: "Incr of a list",
+			action: func() string {
+				_, err := e.Incr("list")
+				return fmt.Sprint(errors.Is(err, storage.ErrWrongType))
+			},
+			want: "true",
+		},
+		{
+			name: "Append",
+			action: func() string {
+				value, _ := e.Append("text", "def")
+				return value
+			},
+			want: "abcdef",
+		},
+		{
+			name: "GetSet returns the old value",
+			action: func() string {
+				old, ok, _ := e.GetSet("text", "new")
+				value, _ := e.Get("text")
+				return fmt.Sprintf("%s %t %s", old, ok, value)
+			},
+			want: "abcdef true new",
+		},
+		{
+			name: "SetNX only sets missing keys",
+			action: func() string {
+				return fmt.Sprint(e.SetNX("text", "x"), e.SetNX("fresh", "x"))
+			},
+			want: "false true",
+		},
+		{
+			name: "CAS",
+			action: func() string {
+				stale, _ := e.CAS("text", "old", "v1")
+				swapped, _ := e.CAS("text", "new", "v2")
+				missing, _ := e.CAS("missing", "", "v")
+				value, _ := e.Get("text")
+				return fmt.Sprintf("%t %t %t %s", stale, swapped, missing, value)
+			},
+			want: "false true false v2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.action(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAtomicOperationsConcurrently(t *testing.T) {
+	e := storage.NewEngine()
+	e.Set("cas", "0")
+
+	const workers, iterations = 8, 500
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				e.Incr("incr")
+
+				// a CAS loop is a counter built from GET and CAS
+				for {
+					current, _ := e.Get("cas")
+					n, _ := strconv.Atoi(current)
+					if ok, _ := e.CAS("cas", current, strconv.Itoa(n+1)); ok {
+						break
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	want := strconv.Itoa(workers * iterations)
+	for _, key := range []string{"incr", "cas"} {
+		if got, _ := e.Get(key); got != want {
+			t.Errorf("%s: got %s, want %s", key, got, want)
+		}
+	}
+}
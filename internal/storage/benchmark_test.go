@@ -0,0 +1,86 @@
+package storage_test
+
+import (
+	"concurrency_hw1/internal/storage"
+	"fmt"
+	"testing"
+)
+
+const benchmarkKeys = 100_000
+
+func benchmarkEngines() map[string]func() storage.EngineInterface {
+	return map[string]func() storage.EngineInterface{
+		storage.EngineTypeHash:    func() storage.EngineInterface { return storage.NewEngine() },
+		storage.EngineTypeOrdered: func() storage.EngineInterface { return storage.NewOrderedEngine() },
+	}
+}
+
+func filledEngine(create func() storage.EngineInterface) storage.EngineInterface {
+	e := create()
+	for i := 0; i < benchmarkKeys; i++ {
+		e.Set(fmt.Sprintf("user:%06d", i), "value")
+	}
+	return e
+}
+
+func BenchmarkSet(b *testing.B) {
+	for name, create := range benchmarkEngines() {
+		b.Run(name, func(b *testing.B) {
+			e := create()
+			for i := 0; i < b.N; i++ {
+				e.Set(fmt.Sprintf("user:%06d", i%benchmarkKeys), "value")
+			}
+		})
+	}
+}
+
+func BenchmarkGet(b *testing.B) {
+	for name, create := range benchmarkEngines() {
+		b.Run(name, func(b *testing.B) {
+			e := filledEngine(create)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				e.Get(fmt.Sprintf("user:%06d", i%benchmarkKeys))
+			}
+		})
+	}
+}
+
+func BenchmarkParallelGet(b *testing.B) {
+	for name, create := range benchmarkEngines() {
+		b.Run(name, func(b *testing.B) {
+			e := filledEngine(create)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					e.Get(fmt.Sprintf("user:%06d", i%benchmarkKeys))
+					i++
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkPrefix compares a prefix listing of 100 keys: the hash engine has
+// to match the whole keyspace, the ordered one seeks to the first key.
+func BenchmarkPrefix(b *testing.B) {
+	b.Run(storage.EngineTypeHash, func(b *testing.B) {
+		e := filledEngine(func() storage.EngineInterface { return storage.NewEngine() })
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			e.Keys("user:0500*")
+		}
+	})
+
+	b.Run(storage.EngineTypeOrdered, func(b *testing.B) {
+		e := storage.NewOrderedEngine()
+		for i := 0; i < benchmarkKeys; i++ {
+			e.Set(fmt.Sprintf("user:%06d", i), "value")
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			e.Prefix("user:0500", 0, false)
+		}
+	})
+}
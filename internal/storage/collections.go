@@ -0,0 +1,260 @@
+package storage
+
+// Collection values are mutated in place and written back with put, so
+// engines that keep encoded values (LSM) see every change. A collection that
+// becomes empty is deleted, like in Redis.
+
+func (e *Engine) LPush(key string, values ...string) (int, error) {
+	return e.push(key, values, true)
+}
+
+func (e *Engine) RPush(key string, values ...string) (int, error) {
+	return e.push(key, values, false)
+}
+
+func (e *Engine) push(key string, values []string, front bool) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	v, err := e.getOrCreate(key, typeList)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, item := range values {
+		if front {
+			v.list.pushFront(item)
+		} else {
+			v.list.pushBack(item)
+		}
+	}
+	e.put(key, v)
+	return v.list.len(), nil
+}
+
+func (e *Engine) LPop(key string) (string, bool, error) {
+	return e.pop(key, true)
+}
+
+func (e *Engine) RPop(key string) (string, bool, error) {
+	return e.pop(key, false)
+}
+
+func (e *Engine) pop(key string, front bool) (string, bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	v, err := e.lookup(key, typeList)
+	if err != nil || v == nil {
+		return "", false, err
+	}
+
+	var item string
+	if front {
+		item, _ = v.list.popFront()
+	} else {
+		item, _ = v.list.popBack()
+	}
+	e.store(key, v)
+	return item, true, nil
+}
+
+// LRange returns list items from start to stop inclusive, negative indexes
+// count from the end of the list.
+func (e *Engine) LRange(key string, start, stop int) ([]string, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	v, err := e.lookup(key, typeList)
+	if err != nil || v == nil {
+		return nil, err
+	}
+
+	size := v.list.len()
+	if start < 0 {
+		start = max(size+start, 0)
+	}
+	if stop < 0 {
+		stop = size + stop
+	}
+	stop = min(stop, size-1)
+	if start > stop {
+		return nil, nil
+	}
+	return v.list.slice(start, stop), nil
+}
+
+// HSet sets fields of a hash and returns the number of fields that were
+// added.
+func (e *Engine) HSet(key string, pairs map[string]string) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	v, err := e.getOrCreate(key, typeHash)
+	if err != nil {
+		return 0, err
+	}
+
+	added := 0
+	for field, fieldValue := range pairs {
+		if _, ok := v.hash[field]; !ok {
+			added++
+		}
+		v.hash[field] = fieldValue
+	}
+	e.put(key, v)
+	return added, nil
+}
+
+func (e *Engine) HGet(key, field string) (string, bool, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	v, err := e.lookup(key, typeHash)
+	if err != nil || v == nil {
+		return "", false, err
+	}
+
+	fieldValue, ok := v.hash[field]
+	return fieldValue, ok, nil
+}
+
+func (e *Engine) HDel(key string, fields ...string) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	v, err := e.lookup(key, typeHash)
+	if err != nil || v == nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, field := range fields {
+		if _, ok := v.hash[field]; ok {
+			delete(v.hash, field)
+			deleted++
+		}
+	}
+	if deleted > 0 {
+		e.store(key, v)
+	}
+	return deleted, nil
+}
+
+// HGetAll returns a copy of the hash, nil if the key is missing.
+func (e *Engine) HGetAll(key string) (map[string]string, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	v, err := e.lookup(key, typeHash)
+	if err != nil || v == nil {
+		return nil, err
+	}
+
+	pairs := make(map[string]string, len(v.hash))
+	for field, fieldValue := range v.hash {
+		pairs[field] = fieldValue
+	}
+	return pairs, nil
+}
+
+// SAdd adds members to a set and returns the number of new members.
+func (e *Engine) SAdd(key string, members ...string) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	v, err := e.getOrCreate(key, typeSet)
+	if err != nil {
+		return 0, err
+	}
+
+	added := 0
+	for _, member := range members {
+		if _, ok := v.set[member]; !ok {
+			v.set[member] = struct{}{}
+			added++
+		}
+	}
+	e.put(key, v)
+	return added, nil
+}
+
+func (e *Engine) SRem(key string, members ...string) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	v, err := e.lookup(key, typeSet)
+	if err != nil || v == nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, member := range members {
+		if _, ok := v.set[member]; ok {
+			delete(v.set, member)
+			removed++
+		}
+	}
+	if removed > 0 {
+		e.store(key, v)
+	}
+	return removed, nil
+}
+
+// SMembers returns the members of a set in sorted order.
+func (e *Engine) SMembers(key string) ([]string, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	v, err := e.lookup(key, typeSet)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	return sortedKeys(v.set), nil
+}
+
+func (e *Engine) SIsMember(key, member string) (bool, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	v, err := e.lookup(key, typeSet)
+	if err != nil || v == nil {
+		return false, err
+	}
+
+	_, ok := v.set[member]
+	return ok, nil
+}
+
+// lookup returns the value at key, nil if the key is missing and
+// ErrWrongType if it holds another type.
+func (e *Engine) lookup(key string, kind valueType) (*value, error) {
+	v, ok := e.table.get(key)
+	if !ok {
+		return nil, nil
+	}
+	if v.kind != kind {
+		return nil, ErrWrongType
+	}
+	return v, nil
+}
+
+func (e *Engine) getOrCreate(key string, kind valueType) (*value, error) {
+	v, err := e.lookup(key, kind)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		v = newValue(kind)
+	}
+	return v, nil
+}
+
+// store writes a mutated collection back or deletes it if it is empty.
+func (e *Engine) store(key string, v *value) {
+	if v.empty() {
+		e.remove(key)
+		return
+	}
+	e.put(key, v)
+}
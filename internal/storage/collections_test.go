@@ -0,0 +1,124 @@
+package storage_test
+
+import (
+	"concurrency_hw1/internal/storage"
+	"concurrency_hw1/internal/storage/lsm"
+	"concurrency_hw1/pkg/logger"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCollections(t *testing.T) {
+	lsmEngine, err := storage.NewLSMEngine(lsm.Options{Dir: t.TempDir(), Logger: logger.New("error", "")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lsmEngine.Close()
+
+	engines := map[string]storage.EngineInterface{
+		"hash":    storage.NewEngine(),
+		"ordered": storage.NewOrderedEngine(),
+		"lsm":     lsmEngine,
+	}
+
+	for name, e := range engines {
+		e.RPush("list", "b", "c")
+		e.LPush("list", "a")
+		e.RPush("single", "x")
+		e.RPop("single")
+		e.HSet("hash", map[string]string{"f1": "v1", "f2": "v2"})
+		e.HDel("hash", "f2")
+		e.SAdd("set", "m2", "m1", "m2")
+		e.Set("str", "\x00value")
+
+		tests := []struct {
+			name   string
+			action func() string
+			want   string
+		}{
+			{
+				name:   "LRange with negative stop",
+				action: func() string { return result(e.LRange("list", 0, -1)) },
+				want:   "a,b,c",
+			},
+			{
+				name:   "LRange out of bounds",
+				action: func() string { return result(e.LRange("list", 5, 10)) },
+				want:   "",
+			},
+			{
+				name:   "Popping the last item deletes the list",
+				action: func() string { return e.Type("single") },
+				want:   storage.TypeNone,
+			},
+			{
+				name: "HGet",
+				action: func() string {
+					value, ok, _ := e.HGet("hash", "f1")
+					_, deleted, _ := e.HGet("hash", "f2")
+					return fmt.Sprintf("%s %t %t", value, ok, deleted)
+				},
+				want: "v1 true false",
+			},
+			{
+				name:   "SMembers are sorted and unique",
+				action: func() string { return result(e.SMembers("set")) },
+				want:   "m1,m2",
+			},
+			{
+				name:   "Type of a set",
+				action: func() string { return e.Type("set") },
+				want:   "set",
+			},
+			{
+				name: "Get of a list is a miss",
+				action: func() string {
+					_, ok := e.Get("list")
+					return fmt.Sprint(ok)
+				},
+				want: "false",
+			},
+			{
+				name: "List command on a hash",
+				action: func() string {
+					_, err := e.LPush("hash", "x")
+					return fmt.Sprint(errors.Is(err, storage.ErrWrongType))
+				},
+				want: "true",
+			},
+			{
+				name: "Set overwrites a collection",
+				action: func() string {
+					e.Set("set", "plain")
+					return e.Type("set")
+				},
+				want: "string",
+			},
+			{
+				name: "String starting with a zero byte",
+				action: func() string {
+					value, _ := e.Get("str")
+					return value
+				},
+				want: "\x00value",
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				if got := tt.action(); got != tt.want {
+					t.Errorf("got %q, want %q", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func result(items []string, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return strings.Join(items, ",")
+}
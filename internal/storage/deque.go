@@ -0,0 +1,75 @@
+package storage
+
+// deque is a ring buffer of strings with O(1) push and pop on both ends.
+type deque struct {
+	items []string
+	head  int
+	size  int
+}
+
+func (d *deque) len() int {
+	return d.size
+}
+
+func (d *deque) pushFront(item string) {
+	d.grow()
+	d.head = (d.head - 1 + len(d.items)) % len(d.items)
+	d.items[d.head] = item
+	d.size++
+}
+
+func (d *deque) pushBack(item string) {
+	d.grow()
+	d.items[(d.head+d.size)%len(d.items)] = item
+	d.size++
+}
+
+func (d *deque) popFront() (string, bool) {
+	if d.size == 0 {
+		return "", false
+	}
+
+	item := d.items[d.head]
+	d.items[d.head] = ""
+	d.head = (d.head + 1) % len(d.items)
+	d.size--
+	return item, true
+}
+
+func (d *deque) popBack() (string, bool) {
+	if d.size == 0 {
+		return "", false
+	}
+
+	index := (d.head + d.size - 1) % len(d.items)
+	item := d.items[index]
+	d.items[index] = ""
+	d.size--
+	return item, true
+}
+
+func (d *deque) at(i int) string {
+	return d.items[(d.head+i)%len(d.items)]
+}
+
+// slice returns items from start to stop inclusive, indexes must be valid.
+func (d *deque) slice(start, stop int) []string {
+	items := make([]string, 0, stop-start+1)
+	for i := start; i <= stop; i++ {
+		items = append(items, d.at(i))
+	}
+	return items
+}
+
+func (d *deque) grow() {
+	if d.size < len(d.items) {
+		return
+	}
+
+	items := make([]string, max(4, len(d.items)*2))
+	for i := 0; i < d.size; i++ {
+		items[i] = d.at(i)
+	}
+	d.items = items
+	d.head = 0
+}
@@ -1,42 +1,346 @@
 package storage
 
 import (
+	"concurrency_hw1/internal/config"
+	"concurrency_hw1/internal/storage/lsm"
+	"concurrency_hw1/pkg/common"
+	"concurrency_hw1/pkg/logger"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
 	"sync"
 )
 
+const (
+	EngineTypeHash    = "hash"
+	EngineTypeOrdered = "ordered"
+	EngineTypeLSM     = "lsm"
+)
+
+const (
+	ScanStartCursor  = "0"
+	defaultScanCount = 10
+	// how many keys KeysContext matches between checks of the context
+	keysCheckInterval = 256
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
 type EngineInterface interface {
 	Get(key string) (string, bool)
 	Set(key, value string)
 	Delete(key string)
+	MGet(keys []string) []*string
+	MSet(pairs map[string]string)
+	MDelete(keys []string) int
+	Exists(keys []string) int
+	Keys(pattern string) []string
+	// KeysContext is Keys that stops once ctx is done
+	KeysContext(ctx context.Context, pattern string) ([]string, error)
+	Type(key string) string
+	// Scan returns keys matching pattern starting from cursor and the cursor
+	// of the next page. Iteration starts and ends with ScanStartCursor.
+	Scan(cursor string, pattern string, count int) (string, []string, error)
+	// ScanContext is Scan that stops once ctx is done
+	ScanContext(ctx context.Context, cursor string, pattern string, count int) (string, []string, error)
+
+	Incr(key string) (int64, error)
+	Decr(key string) (int64, error)
+	IncrBy(key string, delta int64) (int64, error)
+	Append(key, suffix string) (string, error)
+	GetSet(key, value string) (string, bool, error)
+	SetNX(key, value string) bool
+	CAS(key, expected, value string) (bool, error)
+
+	LPush(key string, values ...string) (int, error)
+	RPush(key string, values ...string) (int, error)
+	LPop(key string) (string, bool, error)
+	RPop(key string) (string, bool, error)
+	LRange(key string, start, stop int) ([]string, error)
+
+	HSet(key string, pairs map[string]string) (int, error)
+	HGet(key, field string) (string, bool, error)
+	HDel(key string, fields ...string) (int, error)
+	HGetAll(key string) (map[string]string, error)
+
+	SAdd(key string, members ...string) (int, error)
+	SRem(key string, members ...string) (int, error)
+	SMembers(key string) ([]string, error)
+	SIsMember(key, member string) (bool, error)
+
+	ZAdd(key string, members []ScoredMember) (int, error)
+	ZRem(key string, members ...string) (int, error)
+	ZScore(key, member string) (float64, bool, error)
+	ZIncrBy(key string, increment float64, member string) (float64, error)
+	ZRank(key, member string) (int, bool, error)
+	ZRange(key string, start, stop int) ([]ScoredMember, error)
+	ZRangeByScore(key string, r ScoreRange, offset, count int) ([]ScoredMember, error)
+
+	SetNotifier(fn func(Event))
+}
+
+// table is the data structure an Engine keeps its keys in. Methods are
+// called with the engine lock held.
+type table interface {
+	get(key string) (*value, bool)
+	set(key string, v *value)
+	delete(key string) bool
+	each(fn func(key string, v *value) bool)
+	// scan visits a small chunk of keys starting at cursor and returns the
+	// cursor of the next chunk or ScanStartCursor after the last one.
+	scan(cursor string, fn func(key string)) (string, error)
 }
 
 type Engine struct {
-	storage map[string]string
-	mu      sync.RWMutex
+	table  table
+	mu     sync.RWMutex
+	notify func(Event)
 }
 
+// New creates an engine described by the config, the hash engine is the
+// default.
+func New(cfg *config.EngineConfig, logger logger.LoggerInterface) (EngineInterface, error) {
+	if cfg == nil {
+		return NewEngine(), nil
+	}
+
+	switch cfg.Type {
+	case EngineTypeHash, "":
+		return NewEngine(), nil
+	case EngineTypeOrdered:
+		return NewOrderedEngine(), nil
+	case EngineTypeLSM:
+		options, err := lsmOptions(cfg, logger)
+		if err != nil {
+			return nil, err
+		}
+		return NewLSMEngine(options)
+	default:
+		return nil, fmt.Errorf("unknown engine type: %s", cfg.Type)
+	}
+}
+
+func lsmOptions(cfg *config.EngineConfig, logger logger.LoggerInterface) (lsm.Options, error) {
+	options := lsm.Options{
+		Dir:                 cfg.DataDirectory,
+		CompactionThreshold: cfg.CompactionThreshold,
+		Logger:              logger,
+	}
+
+	sizes := []struct {
+		name  string
+		text  string
+		value *int
+	}{
+		{name: "memtable size", text: cfg.MemtableSize, value: &options.MemtableSize},
+		{name: "block size", text: cfg.BlockSize, value: &options.BlockSize},
+		{name: "block cache size", text: cfg.BlockCacheSize, value: &options.BlockCacheSize},
+	}
+	for _, size := range sizes {
+		if size.text == "" {
+			continue
+		}
+		value, err := common.ParseSize(size.text)
+		if err != nil {
+			return lsm.Options{}, fmt.Errorf("incorrect %s: %w", size.name, err)
+		}
+		*size.value = value
+	}
+
+	return options, nil
+}
+
+func NewEngine() *Engine {
+	return &Engine{
+		table: newHashTable(),
+		mu:    sync.RWMutex{},
+	}
+}
+
+// Get returns the value of a string key, other types are reported as
+// missing, use Type to tell them apart.
 func (e *Engine) Get(key string) (string, bool) {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
-	value, ok := e.storage[key]
-	return value, ok
+
+	v, ok := e.table.get(key)
+	if !ok || v.kind != typeString {
+		return "", false
+	}
+	return v.str, true
 }
 
+// Set stores a string, replacing a value of any type.
 func (e *Engine) Set(key, value string) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
-	e.storage[key] = value
+	e.put(key, stringValue(value))
+}
+
+// Type returns the type name of the value stored at key or TypeNone.
+func (e *Engine) Type(key string) string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	v, ok := e.table.get(key)
+	if !ok {
+		return TypeNone
+	}
+	return v.kind.String()
 }
 
 func (e *Engine) Delete(key string) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
-	delete(e.storage, key)
+	e.remove(key)
 }
 
-func NewEngine() *Engine {
-	return &Engine{
-		storage: make(map[string]string),
-		mu:      sync.RWMutex{},
+// MGet returns values in the order of keys, nil for missing keys and keys
+// that do not hold a string.
+func (e *Engine) MGet(keys []string) []*string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	values := make([]*string, len(keys))
+	for i, key := range keys {
+		if v, ok := e.table.get(key); ok && v.kind == typeString {
+			values[i] = &v.str
+		}
+	}
+	return values
+}
+
+// MSet sets all pairs at once, no reader can observe a part of them.
+func (e *Engine) MSet(pairs map[string]string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for key, value := range pairs {
+		e.put(key, stringValue(value))
+	}
+}
+
+// MDelete removes keys and returns how many of them existed.
+func (e *Engine) MDelete(keys []string) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	deleted := 0
+	for _, key := range keys {
+		if e.remove(key) {
+			deleted++
+		}
+	}
+	return deleted
+}
+
+// Exists returns how many of keys are present, repeated keys are counted
+// every time.
+func (e *Engine) Exists(keys []string) int {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	count := 0
+	for _, key := range keys {
+		if _, ok := e.table.get(key); ok {
+			count++
+		}
+	}
+	return count
+}
+
+// Keys returns sorted keys matching the glob pattern.
+func (e *Engine) Keys(pattern string) []string {
+	keys, _ := e.KeysContext(context.Background(), pattern)
+	return keys
+}
+
+// KeysContext is Keys that stops with the error of ctx once it is done, so
+// a command that timed out does not keep the lock for the whole keyspace.
+func (e *Engine) KeysContext(ctx context.Context, pattern string) ([]string, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var keys []string
+	var err error
+	examined := 0
+	e.table.each(func(key string, _ *value) bool {
+		if examined++; examined%keysCheckInterval == 0 {
+			if err = ctx.Err(); err != nil {
+				return false
+			}
+		}
+		if common.MatchPattern(pattern, key) {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Scan walks the table chunk by chunk until at least count keys were
+// examined. The lock is taken for one chunk at a time, so writers are never
+// blocked for the whole keyspace. A key that exists during the whole scan is
+// returned exactly once; keys added or removed in the meantime may or may
+// not be returned.
+func (e *Engine) Scan(cursor string, pattern string, count int) (string, []string, error) {
+	return e.ScanContext(context.Background(), cursor, pattern, count)
+}
+
+// ScanContext is Scan that stops with the error of ctx once it is done, it
+// is checked before every chunk.
+func (e *Engine) ScanContext(ctx context.Context, cursor string, pattern string, count int) (string, []string, error) {
+	if count <= 0 {
+		count = defaultScanCount
+	}
+
+	var keys []string
+	examined := 0
+	for examined < count {
+		if err := ctx.Err(); err != nil {
+			return "", nil, err
+		}
+		e.mu.RLock()
+		next, err := e.table.scan(cursor, func(key string) {
+			examined++
+			if common.MatchPattern(pattern, key) {
+				keys = append(keys, key)
+			}
+		})
+		e.mu.RUnlock()
+		if err != nil {
+			return "", nil, err
+		}
+
+		cursor = next
+		if cursor == ScanStartCursor {
+			break
+		}
+	}
+
+	return cursor, keys, nil
+}
+
+// Ordered tables use the hex encoded key to continue from as a SCAN cursor,
+// so the position survives insertions and deletions between calls.
+func encodeKeyCursor(key string) string {
+	return hex.EncodeToString([]byte(key))
+}
+
+func decodeKeyCursor(cursor string) (string, error) {
+	if cursor == ScanStartCursor {
+		return "", nil
+	}
+
+	key, err := hex.DecodeString(cursor)
+	if err != nil || len(key) == 0 {
+		return "", ErrInvalidCursor
 	}
+	return string(key), nil
 }
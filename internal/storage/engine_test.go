@@ -2,6 +2,10 @@ package storage_test
 
 import (
 	"concurrency_hw1/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -60,6 +64,45 @@ func TestEngine(t *testing.T) {
 			},
 			want: "newval",
 		},
+		{
+			name: "MSet then MGet with a missing key",
+			setup: func() {
+				e.MSet(map[string]string{"a": "1", "b": "2"})
+			},
+			action: func() string {
+				var values []string
+				for _, val := range e.MGet([]string{"a", "missing", "b"}) {
+					if val == nil {
+						values = append(values, "nil")
+					} else {
+						values = append(values, *val)
+					}
+				}
+				return strings.Join(values, ",")
+			},
+			want: "1,nil,2",
+		},
+		{
+			name: "MDelete counts only existing keys",
+			setup: func() {
+				e.MSet(map[string]string{"a": "1", "b": "2"})
+			},
+			action: func() string {
+				deleted := e.MDelete([]string{"a", "b", "c"})
+				return fmt.Sprintf("%d %d", deleted, e.Exists([]string{"a", "b"}))
+			},
+			want: "2 0",
+		},
+		{
+			name: "Keys matching a glob pattern",
+			setup: func() {
+				e.MSet(map[string]string{"user:1": "a", "user:2": "b", "user/10": "c", "order:1": "d"})
+			},
+			action: func() string {
+				return strings.Join(e.Keys("user[:/]*"), ",")
+			},
+			want: "user/10,user:1,user:2",
+		},
 	}
 
 	for _, tt := range tests {
@@ -77,3 +120,71 @@ func TestEngine(t *testing.T) {
 		})
 	}
 }
+
+func TestEngineScanWithConcurrentWrites(t *testing.T) {
+	e := storage.NewEngine()
+
+	stable := make(map[string]string)
+	for i := 0; i < 1000; i++ {
+		stable[fmt.Sprintf("stable:%d", i)] = "value"
+	}
+	e.MSet(stable)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 5000; i++ {
+			key := fmt.Sprintf("volatile:%d", i)
+			e.Set(key, "value")
+			if i%2 == 0 {
+				e.Delete(key)
+			}
+		}
+	}()
+
+	seen := make(map[string]int)
+	cursor := storage.ScanStartCursor
+	for {
+		next, keys, err := e.Scan(cursor, "stable:*", 50)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, key := range keys {
+			seen[key]++
+		}
+		if next == storage.ScanStartCursor {
+			break
+		}
+		cursor = next
+	}
+	<-done
+
+	for key := range stable {
+		if seen[key] != 1 {
+			t.Errorf("key %q returned %d times, want 1", key, seen[key])
+		}
+	}
+
+	if _, _, err := e.Scan("bad", "*", 10); err != storage.ErrInvalidCursor {
+		t.Errorf("got %v, want %v", err, storage.ErrInvalidCursor)
+	}
+}
+
+func TestEngineKeysAndScanStopWhenCancelled(t *testing.T) {
+	e := storage.NewEngine()
+	for i := 0; i < 1000; i++ {
+		e.Set(fmt.Sprintf("key:%d", i), "value")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := e.KeysContext(ctx, "*"); !errors.Is(err, context.Canceled) {
+		t.Errorf("KeysContext: got %v, want %v", err, context.Canceled)
+	}
+	if _, _, err := e.ScanContext(ctx, storage.ScanStartCursor, "*", 100); !errors.Is(err, context.Canceled) {
+		t.Errorf("ScanContext: got %v, want %v", err, context.Canceled)
+	}
+	if keys, err := e.KeysContext(context.Background(), "key:99*"); err != nil || len(keys) != 11 {
+		t.Errorf("KeysContext: got %d keys and %v, want 11 keys", len(keys), err)
+	}
+}
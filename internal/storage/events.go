@@ -0,0 +1,48 @@
+package storage
+
+// EventType is the kind of change of a key.
+type EventType string
+
+const (
+	EventSet EventType = "set"
+	EventDel EventType = "del"
+	// EventExpire and EventEvict are reserved for keys removed by a TTL and
+	// by memory eviction, the engine does neither yet
+	EventExpire EventType = "expire"
+	EventEvict  EventType = "evict"
+)
+
+// Event describes a change of a key. Writes to lists, hashes and sets are
+// reported as EventSet, a collection that becomes empty as EventDel.
+type Event struct {
+	Type EventType
+	Key  string
+}
+
+// SetNotifier registers fn to be called for every change. It is called with
+// the engine lock held, so it must be fast and must not use the engine. The
+// notifier must be set before the engine is used concurrently.
+func (e *Engine) SetNotifier(fn func(Event)) {
+	e.notify = fn
+}
+
+// put stores a value and reports the change.
+func (e *Engine) put(key string, v *value) {
+	e.table.set(key, v)
+	e.emit(EventSet, key)
+}
+
+// remove deletes a key and reports the change if the key existed.
+func (e *Engine) remove(key string) bool {
+	if !e.table.delete(key) {
+		return false
+	}
+	e.emit(EventDel, key)
+	return true
+}
+
+func (e *Engine) emit(kind EventType, key string) {
+	if e.notify != nil {
+		e.notify(Event{Type: kind, Key: key})
+	}
+}
@@ -0,0 +1,63 @@
+package storage_test
+
+import (
+	"concurrency_hw1/internal/storage"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEngineNotifier(t *testing.T) {
+	tests := []struct {
+		name   string
+		action func(e storage.EngineInterface)
+		want   string
+	}{
+		{
+			name:   "Set",
+			action: func(e storage.EngineInterface) { e.Set("a", "1") },
+			want:   "set a",
+		},
+		{
+			name:   "Deleting a missing key is not a change",
+			action: func(e storage.EngineInterface) { e.Delete("missing") },
+			want:   "",
+		},
+		{
+			name:   "MDelete reports existing keys",
+			action: func(e storage.EngineInterface) { e.MDelete([]string{"a", "missing"}) },
+			want:   "del a",
+		},
+		{
+			name: "Popping the last item deletes the list",
+			action: func(e storage.EngineInterface) {
+				e.RPush("list", "x")
+				e.LPop("list")
+			},
+			want: "set list,del list",
+		},
+		{
+			name:   "Failed write",
+			action: func(e storage.EngineInterface) { e.SAdd("b", "x") },
+			want:   "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := storage.NewOrderedEngine()
+			e.Set("a", "0")
+			e.Set("b", "0")
+
+			var events []string
+			e.SetNotifier(func(event storage.Event) {
+				events = append(events, fmt.Sprintf("%s %s", event.Type, event.Key))
+			})
+			tt.action(e)
+
+			if got := strings.Join(events, ","); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package storage
+
+import "strconv"
+
+// scanBuckets is the number of maps the keyspace is split into. The number
+// never changes, so a key always lives in the same bucket and a SCAN cursor
+// can be a bucket index.
+const scanBuckets = 1024
+
+type hashTable struct {
+	buckets [scanBuckets]map[string]*value
+}
+
+func newHashTable() *hashTable {
+	return &hashTable{}
+}
+
+func (t *hashTable) get(key string) (*value, bool) {
+	v, ok := t.buckets[bucketIndex(key)][key]
+	return v, ok
+}
+
+func (t *hashTable) set(key string, v *value) {
+	index := bucketIndex(key)
+	if t.buckets[index] == nil {
+		t.buckets[index] = make(map[string]*value)
+	}
+	t.buckets[index][key] = v
+}
+
+func (t *hashTable) delete(key string) bool {
+	bucket := t.buckets[bucketIndex(key)]
+	if _, ok := bucket[key]; !ok {
+		return false
+	}
+	delete(bucket, key)
+	return true
+}
+
+func (t *hashTable) each(fn func(key string, v *value) bool) {
+	for _, bucket := range t.buckets {
+		for key, v := range bucket {
+			if !fn(key, v) {
+				return
+			}
+		}
+	}
+}
+
+// scan visits one bucket, the cursor is the bucket index.
+func (t *hashTable) scan(cursor string, fn func(key string)) (string, error) {
+	index, err := strconv.Atoi(cursor)
+	if err != nil || index < 0 || index >= scanBuckets {
+		return "", ErrInvalidCursor
+	}
+
+	for key := range t.buckets[index] {
+		fn(key)
+	}
+
+	if index+1 == scanBuckets {
+		return ScanStartCursor, nil
+	}
+	return strconv.Itoa(index + 1), nil
+}
+
+// bucketIndex hashes the key with 32-bit FNV-1a.
+func bucketIndex(key string) int {
+	hash := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint32(key[i])
+		hash *= 16777619
+	}
+	return int(hash % scanBuckets)
+}
@@ -0,0 +1,55 @@
+package lsm
+
+import "math"
+
+// bloomFilter answers "definitely not present" for most missing keys, so a
+// lookup does not have to read a block of every table. The first byte of
+// the encoded filter is the number of hash functions.
+type bloomFilter []byte
+
+func newBloomFilter(hashes []uint64, bitsPerKey int) bloomFilter {
+	probes := int(math.Round(float64(bitsPerKey) * math.Ln2))
+	probes = max(1, min(probes, 30))
+
+	bits := max(len(hashes)*bitsPerKey, 64)
+	filter := make(bloomFilter, 1+(bits+7)/8)
+	filter[0] = byte(probes)
+	bits = (len(filter) - 1) * 8
+
+	for _, hash := range hashes {
+		// double hashing: probe i is h1 + i*h2
+		h1, h2 := uint32(hash), uint32(hash>>32)
+		for i := 0; i < probes; i++ {
+			bit := (h1 + uint32(i)*h2) % uint32(bits)
+			filter[1+bit/8] |= 1 << (bit % 8)
+		}
+	}
+	return filter
+}
+
+func (f bloomFilter) mayContain(hash uint64) bool {
+	if len(f) < 2 {
+		return true
+	}
+
+	probes := int(f[0])
+	bits := uint32((len(f) - 1) * 8)
+	h1, h2 := uint32(hash), uint32(hash>>32)
+	for i := 0; i < probes; i++ {
+		bit := (h1 + uint32(i)*h2) % bits
+		if f[1+bit/8]&(1<<(bit%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// keyHash is 64-bit FNV-1a.
+func keyHash(key string) uint64 {
+	hash := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint64(key[i])
+		hash *= 1099511628211
+	}
+	return hash
+}
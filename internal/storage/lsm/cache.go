@@ -0,0 +1,80 @@
+package lsm
+
+import (
+	"container/list"
+	"sync"
+)
+
+type blockKey struct {
+	table  uint64
+	offset uint64
+}
+
+type cachedBlock struct {
+	key   blockKey
+	block block
+	size  int
+}
+
+// blockCache is an LRU cache of decoded data blocks shared by all tables.
+type blockCache struct {
+	mu       sync.Mutex
+	capacity int
+	size     int
+	order    *list.List
+	items    map[blockKey]*list.Element
+}
+
+func newBlockCache(capacity int) *blockCache {
+	return &blockCache{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[blockKey]*list.Element),
+	}
+}
+
+func (c *blockCache) get(key blockKey) (block, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*cachedBlock).block, true
+}
+
+func (c *blockCache) put(key blockKey, value block, size int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.items[key]; ok || size > c.capacity {
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&cachedBlock{key: key, block: value, size: size})
+	c.size += size
+
+	for c.size > c.capacity {
+		oldest := c.order.Back()
+		item := oldest.Value.(*cachedBlock)
+		c.order.Remove(oldest)
+		delete(c.items, item.key)
+		c.size -= item.size
+	}
+}
+
+// evictTable drops blocks of a table that was removed by compaction.
+func (c *blockCache) evictTable(table uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, element := range c.items {
+		if key.table == table {
+			c.order.Remove(element)
+			delete(c.items, key)
+			c.size -= element.Value.(*cachedBlock).size
+		}
+	}
+}
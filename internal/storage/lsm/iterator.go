@@ -0,0 +1,82 @@
+package lsm
+
+import "sort"
+
+type iterator interface {
+	valid() bool
+	current() record
+	next()
+	error() error
+}
+
+// memIterator walks a snapshot of memtable keys. Values are read under the
+// tree lock, so writes that happen during the walk are seen for keys that
+// existed when the iterator was created.
+type memIterator struct {
+	tree *Tree
+	mem  *memtable
+	keys []string
+	pos  int
+}
+
+func newMemIterator(tree *Tree, mem *memtable, from string) *memIterator {
+	keys := mem.sortedKeys()
+	pos := sort.SearchStrings(keys, from)
+
+	return &memIterator{tree: tree, mem: mem, keys: keys, pos: pos}
+}
+
+func (it *memIterator) valid() bool {
+	return it.pos < len(it.keys)
+}
+
+func (it *memIterator) current() record {
+	key := it.keys[it.pos]
+
+	it.tree.mu.RLock()
+	e := it.mem.entries[key]
+	it.tree.mu.RUnlock()
+
+	return record{key: key, entry: e}
+}
+
+func (it *memIterator) next() {
+	it.pos++
+}
+
+func (it *memIterator) error() error {
+	return nil
+}
+
+// mergeIterator merges sources ordered from the newest to the oldest. When
+// several sources have the same key, the record of the newest one wins.
+type mergeIterator struct {
+	sources []iterator
+}
+
+func (m *mergeIterator) next() (record, bool, error) {
+	best := -1
+	var bestRecord record
+	for i, source := range m.sources {
+		if err := source.error(); err != nil {
+			return record{}, false, err
+		}
+		if !source.valid() {
+			continue
+		}
+		if current := source.current(); best < 0 || current.key < bestRecord.key {
+			best, bestRecord = i, current
+		}
+	}
+
+	if best < 0 {
+		return record{}, false, nil
+	}
+
+	for _, source := range m.sources {
+		if source.valid() && source.current().key == bestRecord.key {
+			source.next()
+		}
+	}
+	return bestRecord, true, nil
+}
@@ -0,0 +1,106 @@
+package lsm
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The manifest lists live tables from the newest to the oldest, one id per
+// line. It is replaced atomically after every flush and compaction; files
+// that are not in the manifest are leftovers of an interrupted operation.
+const (
+	manifestName = "MANIFEST"
+	tableExt     = ".sst"
+	logExt       = ".log"
+)
+
+func tablePath(dir string, id uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%06d%s", id, tableExt))
+}
+
+func logPath(dir string, id uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%06d%s", id, logExt))
+}
+
+func readManifest(dir string) ([]uint64, error) {
+	file, err := os.Open(filepath.Join(dir, manifestName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var ids []uint64
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(line, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("corrupted manifest: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, scanner.Err()
+}
+
+func writeManifest(dir string, tables []*sstable) error {
+	var builder strings.Builder
+	for _, table := range tables {
+		builder.WriteString(strconv.FormatUint(table.id, 10))
+		builder.WriteByte('\n')
+	}
+
+	tmpPath := filepath.Join(dir, manifestName+".tmp")
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(builder.String()); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, filepath.Join(dir, manifestName))
+}
+
+// listFiles returns ids of files with the given extension in ascending order.
+func listFiles(dir, ext string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []uint64
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, ext) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(name, ext), 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
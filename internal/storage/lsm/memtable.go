@@ -0,0 +1,165 @@
+package lsm
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+// entry is a value or a tombstone that hides older values of the key.
+type entry struct {
+	value   string
+	deleted bool
+}
+
+// memtable keeps the latest writes in memory. Every write is appended to
+// the memtable log first, so the memtable can be rebuilt after a crash.
+// Entries are never removed from a memtable, a delete stores a tombstone.
+type memtable struct {
+	entries map[string]entry
+	size    int
+	// sorted is built on demand and dropped when a new key is added
+	sorted []string
+	sortMu sync.Mutex
+
+	logID uint64
+	log   *os.File
+}
+
+func newMemtable(logPath string, logID uint64) (*memtable, error) {
+	log, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open memtable log: %w", err)
+	}
+
+	return &memtable{
+		entries: make(map[string]entry),
+		logID:   logID,
+		log:     log,
+	}, nil
+}
+
+func (m *memtable) put(key string, e entry) error {
+	if m.log != nil {
+		if _, err := m.log.Write(encodeRecord(nil, key, e)); err != nil {
+			return fmt.Errorf("failed to write memtable log: %w", err)
+		}
+	}
+
+	m.apply(key, e)
+	return nil
+}
+
+func (m *memtable) apply(key string, e entry) {
+	if old, ok := m.entries[key]; ok {
+		m.size -= len(old.value)
+	} else {
+		m.size += len(key)
+		m.sorted = nil
+	}
+	m.size += len(e.value)
+	m.entries[key] = e
+}
+
+func (m *memtable) get(key string) (entry, bool) {
+	e, ok := m.entries[key]
+	return e, ok
+}
+
+// sortedKeys returns all keys in ascending order. The returned slice is
+// never modified afterwards.
+func (m *memtable) sortedKeys() []string {
+	m.sortMu.Lock()
+	defer m.sortMu.Unlock()
+
+	if m.sorted == nil {
+		keys := make([]string, 0, len(m.entries))
+		for key := range m.entries {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		m.sorted = keys
+	}
+	return m.sorted
+}
+
+func (m *memtable) closeLog() error {
+	if m.log == nil {
+		return nil
+	}
+	err := m.log.Close()
+	m.log = nil
+	return err
+}
+
+// replayLog applies all complete records of a memtable log. A torn record
+// at the end of the file is the result of a crash during a write and is
+// ignored.
+func replayLog(path string, m *memtable) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		key, e, err := readRecord(reader)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		m.apply(key, e)
+	}
+}
+
+const (
+	flagValue     byte = 0
+	flagTombstone byte = 1
+)
+
+// encodeRecord appends a record in the format used by both memtable logs
+// and data blocks: flag, key length, value length, key, value.
+func encodeRecord(buf []byte, key string, e entry) []byte {
+	flag := flagValue
+	if e.deleted {
+		flag = flagTombstone
+	}
+
+	buf = append(buf, flag)
+	buf = binary.AppendUvarint(buf, uint64(len(key)))
+	buf = binary.AppendUvarint(buf, uint64(len(e.value)))
+	buf = append(buf, key...)
+	buf = append(buf, e.value...)
+	return buf
+}
+
+func readRecord(reader *bufio.Reader) (string, entry, error) {
+	flag, err := reader.ReadByte()
+	if err != nil {
+		return "", entry{}, err
+	}
+
+	keyLen, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return "", entry{}, io.ErrUnexpectedEOF
+	}
+	valueLen, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return "", entry{}, io.ErrUnexpectedEOF
+	}
+
+	data := make([]byte, keyLen+valueLen)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return "", entry{}, io.ErrUnexpectedEOF
+	}
+
+	return string(data[:keyLen]), entry{value: string(data[keyLen:]), deleted: flag == flagTombstone}, nil
+}
@@ -0,0 +1,47 @@
+package lsm
+
+import "concurrency_hw1/pkg/logger"
+
+const (
+	defaultMemtableSize        = 4 << 20
+	defaultBlockSize           = 4 << 10
+	defaultBlockCacheSize      = 8 << 20
+	defaultCompactionThreshold = 4
+	defaultBloomBitsPerKey     = 10
+)
+
+type Options struct {
+	// Dir keeps tables, memtable logs and the manifest
+	Dir string
+	// MemtableSize is the approximate size in bytes after which the
+	// memtable is flushed to a table
+	MemtableSize int
+	// BlockSize is the approximate size of a data block in a table
+	BlockSize int
+	// BlockCacheSize is the capacity of the cache of decoded blocks
+	BlockCacheSize int
+	// CompactionThreshold is the number of adjacent tables of a similar
+	// size that are merged into one
+	CompactionThreshold int
+	// BloomBitsPerKey trades memory for false positive rate of the filters
+	BloomBitsPerKey int
+	Logger          logger.LoggerInterface
+}
+
+func (o *Options) setDefaults() {
+	if o.MemtableSize <= 0 {
+		o.MemtableSize = defaultMemtableSize
+	}
+	if o.BlockSize <= 0 {
+		o.BlockSize = defaultBlockSize
+	}
+	if o.BlockCacheSize <= 0 {
+		o.BlockCacheSize = defaultBlockCacheSize
+	}
+	if o.CompactionThreshold < 2 {
+		o.CompactionThreshold = defaultCompactionThreshold
+	}
+	if o.BloomBitsPerKey <= 0 {
+		o.BloomBitsPerKey = defaultBloomBitsPerKey
+	}
+}
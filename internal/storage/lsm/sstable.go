@@ -0,0 +1,351 @@
+package lsm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+	"sync/atomic"
+)
+
+// Table layout:
+//
+//	data block 0 | ... | data block n | index | bloom filter | footer
+//
+// A data block is a sequence of records followed by CRC32 of the records.
+// The index has the last key, offset and length of every data block. The
+// footer keeps offsets and lengths of the index and of the bloom filter.
+const (
+	footerSize = 5 * 8
+	tableMagic = 0x6c736d7461626c65
+)
+
+var errCorruptedTable = errors.New("corrupted table")
+
+type record struct {
+	key   string
+	entry entry
+}
+
+type block []record
+
+type indexEntry struct {
+	lastKey string
+	offset  uint64
+	length  uint64
+}
+
+type tableWriter struct {
+	file       *os.File
+	writer     *bufio.Writer
+	offset     uint64
+	blockSize  int
+	bitsPerKey int
+
+	block   []byte
+	lastKey string
+	index   []indexEntry
+	hashes  []uint64
+}
+
+func createTable(path string, blockSize, bitsPerKey int) (*tableWriter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create table: %w", err)
+	}
+
+	return &tableWriter{
+		file:       file,
+		writer:     bufio.NewWriter(file),
+		blockSize:  blockSize,
+		bitsPerKey: bitsPerKey,
+	}, nil
+}
+
+// add appends a record, keys must be added in ascending order.
+func (w *tableWriter) add(key string, e entry) error {
+	w.block = encodeRecord(w.block, key, e)
+	w.lastKey = key
+	w.hashes = append(w.hashes, keyHash(key))
+
+	if len(w.block) >= w.blockSize {
+		return w.flushBlock()
+	}
+	return nil
+}
+
+func (w *tableWriter) flushBlock() error {
+	if len(w.block) == 0 {
+		return nil
+	}
+
+	w.block = binary.LittleEndian.AppendUint32(w.block, crc32.ChecksumIEEE(w.block))
+	if _, err := w.writer.Write(w.block); err != nil {
+		return err
+	}
+
+	w.index = append(w.index, indexEntry{lastKey: w.lastKey, offset: w.offset, length: uint64(len(w.block))})
+	w.offset += uint64(len(w.block))
+	w.block = w.block[:0]
+	return nil
+}
+
+// finish writes the index, the filter and the footer and syncs the file.
+func (w *tableWriter) finish() error {
+	if err := w.flushBlock(); err != nil {
+		return err
+	}
+
+	var index []byte
+	for _, e := range w.index {
+		index = binary.AppendUvarint(index, uint64(len(e.lastKey)))
+		index = append(index, e.lastKey...)
+		index = binary.AppendUvarint(index, e.offset)
+		index = binary.AppendUvarint(index, e.length)
+	}
+	bloom := newBloomFilter(w.hashes, w.bitsPerKey)
+
+	footer := make([]byte, 0, footerSize)
+	footer = binary.LittleEndian.AppendUint64(footer, w.offset)
+	footer = binary.LittleEndian.AppendUint64(footer, uint64(len(index)))
+	footer = binary.LittleEndian.AppendUint64(footer, w.offset+uint64(len(index)))
+	footer = binary.LittleEndian.AppendUint64(footer, uint64(len(bloom)))
+	footer = binary.LittleEndian.AppendUint64(footer, tableMagic)
+
+	for _, data := range [][]byte{index, bloom, footer} {
+		if _, err := w.writer.Write(data); err != nil {
+			return err
+		}
+	}
+	if err := w.writer.Flush(); err != nil {
+		return err
+	}
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	return w.file.Close()
+}
+
+func (w *tableWriter) abort() {
+	_ = w.file.Close()
+	_ = os.Remove(w.file.Name())
+}
+
+// sstable is an immutable sorted table on disk. The index and the bloom
+// filter are kept in memory, data blocks are read through the block cache.
+type sstable struct {
+	id    uint64
+	file  *os.File
+	size  int64
+	index []indexEntry
+	bloom bloomFilter
+	cache *blockCache
+
+	// refs is 1 while the table is live plus one for every reader; an
+	// obsolete table is deleted when the last reader is done
+	refs     atomic.Int32
+	obsolete atomic.Bool
+}
+
+func openTable(path string, id uint64, cache *blockCache) (*sstable, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	table, err := readTableMeta(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to open table %s: %w", path, err)
+	}
+
+	table.id = id
+	table.cache = cache
+	table.refs.Store(1)
+	return table, nil
+}
+
+func readTableMeta(file *os.File) (*sstable, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() < footerSize {
+		return nil, errCorruptedTable
+	}
+
+	footer := make([]byte, footerSize)
+	if _, err := file.ReadAt(footer, info.Size()-footerSize); err != nil {
+		return nil, err
+	}
+	indexOffset := binary.LittleEndian.Uint64(footer[0:])
+	indexLen := binary.LittleEndian.Uint64(footer[8:])
+	bloomOffset := binary.LittleEndian.Uint64(footer[16:])
+	bloomLen := binary.LittleEndian.Uint64(footer[24:])
+	if binary.LittleEndian.Uint64(footer[32:]) != tableMagic || bloomOffset+bloomLen+footerSize != uint64(info.Size()) {
+		return nil, errCorruptedTable
+	}
+
+	meta := make([]byte, indexLen+bloomLen)
+	if _, err := file.ReadAt(meta, int64(indexOffset)); err != nil {
+		return nil, err
+	}
+
+	var index []indexEntry
+	reader := bytes.NewReader(meta[:indexLen])
+	for reader.Len() > 0 {
+		keyLen, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return nil, errCorruptedTable
+		}
+		key := make([]byte, keyLen)
+		if _, err := io.ReadFull(reader, key); err != nil {
+			return nil, errCorruptedTable
+		}
+		offset, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return nil, errCorruptedTable
+		}
+		length, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return nil, errCorruptedTable
+		}
+		index = append(index, indexEntry{lastKey: string(key), offset: offset, length: length})
+	}
+
+	return &sstable{
+		file:  file,
+		size:  info.Size(),
+		index: index,
+		bloom: bloomFilter(meta[indexLen:]),
+	}, nil
+}
+
+func (t *sstable) get(key string) (entry, bool, error) {
+	if !t.bloom.mayContain(keyHash(key)) {
+		return entry{}, false, nil
+	}
+
+	i := sort.Search(len(t.index), func(i int) bool { return t.index[i].lastKey >= key })
+	if i == len(t.index) {
+		return entry{}, false, nil
+	}
+
+	records, err := t.readBlock(i)
+	if err != nil {
+		return entry{}, false, err
+	}
+
+	j := sort.Search(len(records), func(j int) bool { return records[j].key >= key })
+	if j < len(records) && records[j].key == key {
+		return records[j].entry, true, nil
+	}
+	return entry{}, false, nil
+}
+
+func (t *sstable) readBlock(i int) (block, error) {
+	meta := t.index[i]
+	key := blockKey{table: t.id, offset: meta.offset}
+	if cached, ok := t.cache.get(key); ok {
+		return cached, nil
+	}
+
+	data := make([]byte, meta.length)
+	if _, err := t.file.ReadAt(data, int64(meta.offset)); err != nil {
+		return nil, fmt.Errorf("failed to read block: %w", err)
+	}
+	if len(data) < 4 {
+		return nil, errCorruptedTable
+	}
+
+	payload := data[:len(data)-4]
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(data[len(data)-4:]) {
+		return nil, errCorruptedTable
+	}
+
+	var records block
+	reader := bufio.NewReader(bytes.NewReader(payload))
+	for {
+		key, e, err := readRecord(reader)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, errCorruptedTable
+		}
+		records = append(records, record{key: key, entry: e})
+	}
+
+	t.cache.put(key, records, len(data))
+	return records, nil
+}
+
+func (t *sstable) ref() {
+	t.refs.Add(1)
+}
+
+func (t *sstable) unref() {
+	if t.refs.Add(-1) != 0 {
+		return
+	}
+
+	_ = t.file.Close()
+	if t.obsolete.Load() {
+		_ = os.Remove(t.file.Name())
+		t.cache.evictTable(t.id)
+	}
+}
+
+// tableIterator walks records of a table in key order.
+type tableIterator struct {
+	table   *sstable
+	block   int
+	records block
+	pos     int
+	err     error
+}
+
+func (t *sstable) seek(from string) *tableIterator {
+	it := &tableIterator{
+		table: t,
+		block: sort.Search(len(t.index), func(i int) bool { return t.index[i].lastKey >= from }),
+	}
+	it.load()
+	if it.records != nil {
+		it.pos = sort.Search(len(it.records), func(i int) bool { return it.records[i].key >= from })
+	}
+	return it
+}
+
+func (it *tableIterator) load() {
+	it.records, it.pos = nil, 0
+	if it.block >= len(it.table.index) {
+		return
+	}
+	it.records, it.err = it.table.readBlock(it.block)
+}
+
+func (it *tableIterator) valid() bool {
+	return it.err == nil && it.pos < len(it.records)
+}
+
+func (it *tableIterator) current() record {
+	return it.records[it.pos]
+}
+
+func (it *tableIterator) next() {
+	it.pos++
+	if it.pos == len(it.records) {
+		it.block++
+		it.load()
+	}
+}
+
+func (it *tableIterator) error() error {
+	return it.err
+}
@@ -0,0 +1,576 @@
+package lsm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const flushRetryDelay = time.Second
+
+var ErrClosed = errors.New("lsm tree is closed")
+
+// Tree is a log-structured merge tree. Writes go to the memtable; a full
+// memtable becomes immutable and is flushed to a sorted table by a
+// background goroutine, another goroutine merges tables of a similar size
+// (size-tiered compaction). Lookups check the memtables and then the tables
+// from the newest to the oldest.
+type Tree struct {
+	opts  Options
+	cache *blockCache
+
+	mu sync.RWMutex
+	// flushed is signalled when the immutable memtable has been written
+	flushed *sync.Cond
+	mem     *memtable
+	imm     *memtable
+	// tables are ordered from the newest to the oldest
+	tables []*sstable
+	closed bool
+	nextID atomic.Uint64
+
+	flushCh   chan struct{}
+	compactCh chan struct{}
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+func Open(opts Options) (*Tree, error) {
+	opts.setDefaults()
+	if opts.Dir == "" {
+		return nil, errors.New("empty data directory")
+	}
+	if opts.Logger == nil {
+		return nil, errors.New("empty logger")
+	}
+	if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	t := &Tree{
+		opts:      opts,
+		cache:     newBlockCache(opts.BlockCacheSize),
+		flushCh:   make(chan struct{}, 1),
+		compactCh: make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+	t.flushed = sync.NewCond(&t.mu)
+
+	if err := t.load(); err != nil {
+		t.closeTables()
+		return nil, err
+	}
+
+	id := t.nextID.Add(1) - 1
+	mem, err := newMemtable(logPath(opts.Dir, id), id)
+	if err != nil {
+		t.closeTables()
+		return nil, err
+	}
+	t.mem = mem
+
+	t.wg.Add(2)
+	go t.flushLoop()
+	go t.compactionLoop()
+	t.scheduleCompaction()
+
+	return t, nil
+}
+
+// load opens tables from the manifest, removes leftovers of interrupted
+// operations and turns memtable logs of the previous run into a table.
+func (t *Tree) load() error {
+	ids, err := readManifest(t.opts.Dir)
+	if err != nil {
+		return err
+	}
+
+	live := make(map[uint64]bool, len(ids))
+	for _, id := range ids {
+		table, err := openTable(tablePath(t.opts.Dir, id), id, t.cache)
+		if err != nil {
+			return err
+		}
+		t.tables = append(t.tables, table)
+		live[id] = true
+	}
+
+	tableIDs, err := listFiles(t.opts.Dir, tableExt)
+	if err != nil {
+		return err
+	}
+	logIDs, err := listFiles(t.opts.Dir, logExt)
+	if err != nil {
+		return err
+	}
+
+	maxID := uint64(0)
+	for _, id := range append(tableIDs, logIDs...) {
+		maxID = max(maxID, id)
+	}
+	t.nextID.Store(maxID + 1)
+
+	for _, id := range tableIDs {
+		if !live[id] {
+			_ = os.Remove(tablePath(t.opts.Dir, id))
+		}
+	}
+
+	if len(logIDs) == 0 {
+		return nil
+	}
+
+	recovered := &memtable{entries: make(map[string]entry)}
+	for _, id := range logIDs {
+		if err := replayLog(logPath(t.opts.Dir, id), recovered); err != nil {
+			return fmt.Errorf("failed to replay memtable log: %w", err)
+		}
+	}
+
+	if len(recovered.entries) != 0 {
+		table, err := t.writeMemtable(recovered)
+		if err != nil {
+			return err
+		}
+		t.tables = append([]*sstable{table}, t.tables...)
+		if err := writeManifest(t.opts.Dir, t.tables); err != nil {
+			return err
+		}
+	}
+
+	for _, id := range logIDs {
+		_ = os.Remove(logPath(t.opts.Dir, id))
+	}
+	return nil
+}
+
+// Get returns the value of key, ok is false for missing and deleted keys.
+func (t *Tree) Get(key string) (string, bool, error) {
+	t.mu.RLock()
+	if t.closed {
+		t.mu.RUnlock()
+		return "", false, ErrClosed
+	}
+	for _, mem := range []*memtable{t.mem, t.imm} {
+		if mem == nil {
+			continue
+		}
+		if e, ok := mem.get(key); ok {
+			t.mu.RUnlock()
+			return e.value, !e.deleted, nil
+		}
+	}
+	tables := t.acquireTables()
+	t.mu.RUnlock()
+	defer releaseTables(tables)
+
+	for _, table := range tables {
+		e, ok, err := table.get(key)
+		if err != nil {
+			return "", false, err
+		}
+		if ok {
+			return e.value, !e.deleted, nil
+		}
+	}
+	return "", false, nil
+}
+
+func (t *Tree) Put(key, value string) error {
+	return t.write(key, entry{value: value})
+}
+
+func (t *Tree) Delete(key string) error {
+	return t.write(key, entry{deleted: true})
+}
+
+// Ascend calls fn for live keys >= from in ascending order while fn
+// returns true.
+func (t *Tree) Ascend(from string, fn func(key, value string) bool) error {
+	t.mu.RLock()
+	if t.closed {
+		t.mu.RUnlock()
+		return ErrClosed
+	}
+	var sources []iterator
+	for _, mem := range []*memtable{t.mem, t.imm} {
+		if mem != nil {
+			sources = append(sources, newMemIterator(t, mem, from))
+		}
+	}
+	tables := t.acquireTables()
+	t.mu.RUnlock()
+	defer releaseTables(tables)
+
+	for _, table := range tables {
+		sources = append(sources, table.seek(from))
+	}
+
+	merged := &mergeIterator{sources: sources}
+	for {
+		rec, ok, err := merged.next()
+		if err != nil || !ok {
+			return err
+		}
+		if rec.entry.deleted {
+			continue
+		}
+		if !fn(rec.key, rec.entry.value) {
+			return nil
+		}
+	}
+}
+
+// Close stops background work, flushes memtables and closes all files.
+func (t *Tree) Close() error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	t.flushed.Broadcast()
+	t.mu.Unlock()
+
+	close(t.done)
+	t.wg.Wait()
+
+	var errs []error
+	if err := t.flushImmutable(); err != nil {
+		errs = append(errs, err)
+	}
+
+	t.mu.Lock()
+	if len(t.mem.entries) != 0 {
+		t.imm, t.mem = t.mem, nil
+		t.mu.Unlock()
+		if err := t.flushImmutable(); err != nil {
+			errs = append(errs, err)
+		}
+	} else {
+		mem := t.mem
+		t.mu.Unlock()
+		errs = append(errs, mem.closeLog())
+		_ = os.Remove(logPath(t.opts.Dir, mem.logID))
+	}
+
+	t.closeTables()
+	return errors.Join(errs...)
+}
+
+func (t *Tree) write(key string, e entry) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return ErrClosed
+	}
+	if err := t.mem.put(key, e); err != nil {
+		return err
+	}
+	if t.mem.size >= t.opts.MemtableSize {
+		return t.rotate()
+	}
+	return nil
+}
+
+// rotate makes the memtable immutable and starts a new one. If the previous
+// immutable memtable is still being flushed, the writer waits for it.
+func (t *Tree) rotate() error {
+	for t.imm != nil && !t.closed {
+		t.flushed.Wait()
+	}
+	if t.closed {
+		return ErrClosed
+	}
+
+	id := t.nextID.Add(1) - 1
+	mem, err := newMemtable(logPath(t.opts.Dir, id), id)
+	if err != nil {
+		return err
+	}
+	if err := t.mem.closeLog(); err != nil {
+		t.opts.Logger.Warn("failed to close memtable log: %v", err)
+	}
+
+	t.imm, t.mem = t.mem, mem
+	select {
+	case t.flushCh <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (t *Tree) flushLoop() {
+	defer t.wg.Done()
+
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-t.flushCh:
+		}
+
+		if err := t.flushImmutable(); err != nil {
+			t.opts.Logger.Error("failed to flush memtable: %v", err)
+			time.AfterFunc(flushRetryDelay, func() {
+				select {
+				case t.flushCh <- struct{}{}:
+				default:
+				}
+			})
+		}
+	}
+}
+
+// flushImmutable writes the immutable memtable to a new table. The memtable
+// log is removed only after the table is in the manifest.
+func (t *Tree) flushImmutable() error {
+	t.mu.RLock()
+	imm := t.imm
+	t.mu.RUnlock()
+	if imm == nil {
+		return nil
+	}
+
+	table, err := t.writeMemtable(imm)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	tables := append([]*sstable{table}, t.tables...)
+	if err := writeManifest(t.opts.Dir, tables); err != nil {
+		t.mu.Unlock()
+		table.obsolete.Store(true)
+		table.unref()
+		return err
+	}
+	t.tables = tables
+	t.imm = nil
+	t.flushed.Broadcast()
+	t.mu.Unlock()
+
+	_ = imm.closeLog()
+	_ = os.Remove(logPath(t.opts.Dir, imm.logID))
+	t.scheduleCompaction()
+	return nil
+}
+
+func (t *Tree) writeMemtable(mem *memtable) (*sstable, error) {
+	id := t.nextID.Add(1) - 1
+	path := tablePath(t.opts.Dir, id)
+
+	writer, err := createTable(path, t.opts.BlockSize, t.opts.BloomBitsPerKey)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range mem.sortedKeys() {
+		if err := writer.add(key, mem.entries[key]); err != nil {
+			writer.abort()
+			return nil, err
+		}
+	}
+	if err := writer.finish(); err != nil {
+		writer.abort()
+		return nil, err
+	}
+
+	return openTable(path, id, t.cache)
+}
+
+func (t *Tree) scheduleCompaction() {
+	select {
+	case t.compactCh <- struct{}{}:
+	default:
+	}
+}
+
+func (t *Tree) compactionLoop() {
+	defer t.wg.Done()
+
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-t.compactCh:
+		}
+
+		for {
+			compacted, err := t.compact()
+			if err != nil {
+				t.opts.Logger.Error("failed to compact tables: %v", err)
+				break
+			}
+			if !compacted {
+				break
+			}
+
+			select {
+			case <-t.done:
+				return
+			default:
+			}
+		}
+	}
+}
+
+// compact merges the first run of CompactionThreshold adjacent tables of a
+// similar size into one table. Tombstones are dropped only when the run
+// includes the oldest table, otherwise they still hide older values.
+func (t *Tree) compact() (bool, error) {
+	t.mu.RLock()
+	start := t.pickCompaction()
+	if start < 0 {
+		t.mu.RUnlock()
+		return false, nil
+	}
+	run := make([]*sstable, t.opts.CompactionThreshold)
+	copy(run, t.tables[start:])
+	dropTombstones := start+len(run) == len(t.tables)
+	for _, table := range run {
+		table.ref()
+	}
+	t.mu.RUnlock()
+	defer releaseTables(run)
+
+	merged, err := t.mergeTables(run, dropTombstones)
+	if err != nil {
+		return false, err
+	}
+
+	t.mu.Lock()
+	// new tables may have been flushed in front of the run meanwhile
+	position := -1
+	for i, table := range t.tables {
+		if table == run[0] {
+			position = i
+			break
+		}
+	}
+	if position < 0 {
+		t.mu.Unlock()
+		if merged != nil {
+			merged.obsolete.Store(true)
+			merged.unref()
+		}
+		return false, errors.New("compacted tables are gone")
+	}
+
+	tables := append([]*sstable{}, t.tables[:position]...)
+	if merged != nil {
+		tables = append(tables, merged)
+	}
+	tables = append(tables, t.tables[position+len(run):]...)
+	if err := writeManifest(t.opts.Dir, tables); err != nil {
+		t.mu.Unlock()
+		if merged != nil {
+			merged.obsolete.Store(true)
+			merged.unref()
+		}
+		return false, err
+	}
+	t.tables = tables
+	t.mu.Unlock()
+
+	for _, table := range run {
+		table.obsolete.Store(true)
+		table.unref()
+	}
+	return true, nil
+}
+
+// pickCompaction returns the start of the run to compact or -1. Tables
+// smaller than a memtable are considered to be of the same size.
+func (t *Tree) pickCompaction() int {
+	threshold := t.opts.CompactionThreshold
+	for start := 0; start+threshold <= len(t.tables); start++ {
+		smallest, largest := int64(-1), int64(0)
+		for _, table := range t.tables[start : start+threshold] {
+			size := max(table.size, int64(t.opts.MemtableSize))
+			if smallest < 0 || size < smallest {
+				smallest = size
+			}
+			largest = max(largest, size)
+		}
+		if largest <= 2*smallest {
+			return start
+		}
+	}
+	return -1
+}
+
+// mergeTables writes records of tables into a new table, it returns nil if
+// nothing is left after dropping tombstones.
+func (t *Tree) mergeTables(tables []*sstable, dropTombstones bool) (*sstable, error) {
+	sources := make([]iterator, len(tables))
+	for i, table := range tables {
+		sources[i] = table.seek("")
+	}
+	merged := &mergeIterator{sources: sources}
+
+	id := t.nextID.Add(1) - 1
+	path := tablePath(t.opts.Dir, id)
+	writer, err := createTable(path, t.opts.BlockSize, t.opts.BloomBitsPerKey)
+	if err != nil {
+		return nil, err
+	}
+
+	written := 0
+	for {
+		rec, ok, err := merged.next()
+		if err != nil {
+			writer.abort()
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		if rec.entry.deleted && dropTombstones {
+			continue
+		}
+		if err := writer.add(rec.key, rec.entry); err != nil {
+			writer.abort()
+			return nil, err
+		}
+		written++
+	}
+
+	if written == 0 {
+		writer.abort()
+		return nil, nil
+	}
+	if err := writer.finish(); err != nil {
+		writer.abort()
+		return nil, err
+	}
+	return openTable(path, id, t.cache)
+}
+
+// acquireTables returns a copy of the table list with a reference taken on
+// every table, so compaction cannot delete them while they are read. The
+// caller must hold the tree lock.
+func (t *Tree) acquireTables() []*sstable {
+	tables := make([]*sstable, len(t.tables))
+	copy(tables, t.tables)
+	for _, table := range tables {
+		table.ref()
+	}
+	return tables
+}
+
+func releaseTables(tables []*sstable) {
+	for _, table := range tables {
+		table.unref()
+	}
+}
+
+func (t *Tree) closeTables() {
+	for _, table := range t.tables {
+		table.unref()
+	}
+	t.tables = nil
+}
@@ -0,0 +1,110 @@
+package lsm_test
+
+import (
+	"concurrency_hw1/internal/storage/lsm"
+	"concurrency_hw1/pkg/logger"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTree(t *testing.T, dir string) *lsm.Tree {
+	t.Helper()
+
+	tree, err := lsm.Open(lsm.Options{
+		Dir:                 dir,
+		MemtableSize:        4 << 10,
+		BlockSize:           512,
+		CompactionThreshold: 3,
+		Logger:              logger.New("error", ""),
+	})
+	if err != nil {
+		t.Fatalf("failed to open tree: %v", err)
+	}
+	return tree
+}
+
+func TestTreePersistsAcrossFlushesAndCompactions(t *testing.T) {
+	dir := t.TempDir()
+	tree := openTree(t, dir)
+
+	const keys = 2000
+	for i := 0; i < keys; i++ {
+		if err := tree.Put(fmt.Sprintf("key:%05d", i), fmt.Sprintf("value:%d", i)); err != nil {
+			t.Fatalf("put failed: %v", err)
+		}
+	}
+	for i := 0; i < keys; i += 2 {
+		if err := tree.Delete(fmt.Sprintf("key:%05d", i)); err != nil {
+			t.Fatalf("delete failed: %v", err)
+		}
+	}
+	// give background flushes and compactions a chance to run
+	time.Sleep(100 * time.Millisecond)
+
+	if err := tree.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	tables, _ := filepath.Glob(filepath.Join(dir, "*.sst"))
+	if len(tables) == 0 {
+		t.Fatalf("expected tables on disk")
+	}
+
+	tree = openTree(t, dir)
+	defer tree.Close()
+
+	for i := 0; i < keys; i++ {
+		value, ok, err := tree.Get(fmt.Sprintf("key:%05d", i))
+		if err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+		if i%2 == 0 && ok {
+			t.Errorf("deleted key:%05d is visible", i)
+		}
+		if i%2 == 1 && (!ok || value != fmt.Sprintf("value:%d", i)) {
+			t.Errorf("key:%05d = %q, %v", i, value, ok)
+		}
+	}
+
+	var visited []string
+	err := tree.Ascend("key:01990", func(key, _ string) bool {
+		visited = append(visited, key)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("ascend failed: %v", err)
+	}
+	want := []string{"key:01991", "key:01993", "key:01995", "key:01997", "key:01999"}
+	if fmt.Sprint(visited) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", visited, want)
+	}
+}
+
+func TestTreeRecoversMemtableLog(t *testing.T) {
+	dir := t.TempDir()
+	tree := openTree(t, dir)
+
+	if err := tree.Put("hello", "world"); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+
+	// simulate a crash: copy the directory before the memtable is flushed
+	crashed := t.TempDir()
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		data, _ := os.ReadFile(filepath.Join(dir, entry.Name()))
+		_ = os.WriteFile(filepath.Join(crashed, entry.Name()), data, 0644)
+	}
+	tree.Close()
+
+	tree = openTree(t, crashed)
+	defer tree.Close()
+
+	value, ok, err := tree.Get("hello")
+	if err != nil || !ok || value != "world" {
+		t.Errorf("got %q, %v, %v", value, ok, err)
+	}
+}
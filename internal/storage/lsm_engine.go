@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"concurrency_hw1/internal/storage/lsm"
+	"concurrency_hw1/pkg/logger"
+	"sync/atomic"
+)
+
+// lsmScanChunk is how many keys one scan step of the LSM engine visits
+const lsmScanChunk = 64
+
+// LSMEngine is an Engine that keeps its data on disk in an LSM tree, so the
+// dataset is not limited by the available memory. It must be closed to
+// flush the memtable.
+type LSMEngine struct {
+	*Engine
+	tree  *lsm.Tree
+	table *lsmTable
+}
+
+func NewLSMEngine(options lsm.Options) (*LSMEngine, error) {
+	tree, err := lsm.Open(options)
+	if err != nil {
+		return nil, err
+	}
+
+	table := &lsmTable{tree: tree, logger: options.Logger}
+	return &LSMEngine{
+		Engine: &Engine{table: table},
+		tree:   tree,
+		table:  table,
+	}, nil
+}
+
+// Failures returns how many reads and writes of the tree have failed.
+func (e *LSMEngine) Failures() uint64 {
+	return e.table.failures.Load()
+}
+
+func (e *LSMEngine) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.tree.Close()
+}
+
+// lsmTable adapts the tree to the table interface. Values are encoded on
+// every write, so a list or a hash is rewritten as a whole on every change.
+// The interface has no room for I/O errors, they are logged and counted, so
+// the caller of the engine learns from Failures that the result is wrong.
+type lsmTable struct {
+	tree     *lsm.Tree
+	logger   logger.LoggerInterface
+	failures atomic.Uint64
+}
+
+func (t *lsmTable) fail(format string, args ...any) {
+	t.failures.Add(1)
+	t.logger.Error(format, args...)
+}
+
+func (t *lsmTable) get(key string) (*value, bool) {
+	data, ok, err := t.tree.Get(key)
+	if err != nil {
+		t.fail("failed to read key %s: %v", key, err)
+		return nil, false
+	}
+	if !ok {
+		return nil, false
+	}
+
+	v, err := decodeValue(data)
+	if err != nil {
+		t.fail("failed to decode key %s: %v", key, err)
+		return nil, false
+	}
+	return v, true
+}
+
+func (t *lsmTable) set(key string, v *value) {
+	if err := t.tree.Put(key, encodeValue(v)); err != nil {
+		t.fail("failed to write key %s: %v", key, err)
+	}
+}
+
+func (t *lsmTable) delete(key string) bool {
+	_, ok, err := t.tree.Get(key)
+	if err != nil {
+		t.fail("failed to read key %s: %v", key, err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+
+	if err := t.tree.Delete(key); err != nil {
+		t.fail("failed to delete key %s: %v", key, err)
+		return false
+	}
+	return true
+}
+
+func (t *lsmTable) each(fn func(key string, v *value) bool) {
+	err := t.tree.Ascend("", func(key, data string) bool {
+		v, err := decodeValue(data)
+		if err != nil {
+			t.fail("failed to decode key %s: %v", key, err)
+			return true
+		}
+		return fn(key, v)
+	})
+	if err != nil {
+		t.fail("failed to iterate keys: %v", err)
+	}
+}
+
+func (t *lsmTable) scan(cursor string, fn func(key string)) (string, error) {
+	from, err := decodeKeyCursor(cursor)
+	if err != nil {
+		return "", err
+	}
+
+	next := ScanStartCursor
+	visited := 0
+	err = t.tree.Ascend(from, func(key, _ string) bool {
+		if visited == lsmScanChunk {
+			next = encodeKeyCursor(key)
+			return false
+		}
+		fn(key)
+		visited++
+		return true
+	})
+	return next, err
+}
+
+// Failures returns how many operations of the engine have failed, always 0
+// for an engine in memory.
+func Failures(engine EngineInterface) uint64 {
+	if e, ok := engine.(*LSMEngine); ok {
+		return e.Failures()
+	}
+	return 0
+}
+
+// IsPersistent reports whether the engine keeps its data across restarts.
+// Such an engine must not be rebuilt from the WAL on startup.
+func IsPersistent(engine EngineInterface) bool {
+	_, ok := engine.(*LSMEngine)
+	return ok
+}
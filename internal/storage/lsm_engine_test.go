@@ -0,0 +1,52 @@
+package storage_test
+
+import (
+	"concurrency_hw1/internal/storage"
+	"concurrency_hw1/internal/storage/lsm"
+	"concurrency_hw1/pkg/logger"
+	"testing"
+)
+
+func TestLSMEngineCountsFailures(t *testing.T) {
+	options := lsm.Options{Dir: t.TempDir(), Logger: logger.New("error", "")}
+
+	// a value claiming more items than it has bytes
+	tree, err := lsm.Open(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.Put("corrupted", "\x00\x02\xff\xff\xff\xff\xff\xff\xff\xff\x7f"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := storage.NewLSMEngine(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Set("key", "value")
+	if got := storage.Failures(e); got != 0 {
+		t.Fatalf("got %d failures, want 0", got)
+	}
+
+	if _, ok := e.Get("corrupted"); ok {
+		t.Error("got a corrupted value")
+	}
+	if got := storage.Failures(e); got != 1 {
+		t.Errorf("got %d failures after reading a corrupted value, want 1", got)
+	}
+
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	e.Set("key", "other")
+	e.Delete("key")
+	if got := storage.Failures(e); got != 3 {
+		t.Errorf("got %d failures after writing to a closed tree, want 3", got)
+	}
+	if got := storage.Failures(storage.NewEngine()); got != 0 {
+		t.Errorf("got %d failures of an engine in memory, want 0", got)
+	}
+}
@@ -0,0 +1,105 @@
+package storage
+
+import "strings"
+
+// Entry is a key with its value, Value is empty for keys that do not hold
+// a string.
+type Entry struct {
+	Key   string
+	Type  string
+	Value string
+}
+
+// OrderedEngineInterface is implemented by engines that keep keys sorted and
+// can answer range queries. A limit <= 0 means no limit.
+type OrderedEngineInterface interface {
+	EngineInterface
+	// Range returns entries with start <= key <= end, in descending order
+	// when reverse is set.
+	Range(start, end string, limit int, reverse bool) []Entry
+	// Prefix returns entries whose keys start with prefix.
+	Prefix(prefix string, limit int, reverse bool) []Entry
+}
+
+// OrderedEngine is an Engine backed by a skip list instead of a hash table.
+// Point operations are O(log n) instead of O(1), in exchange keys can be
+// listed in order.
+type OrderedEngine struct {
+	*Engine
+	list *skipList
+}
+
+var _ OrderedEngineInterface = (*OrderedEngine)(nil)
+
+func NewOrderedEngine() *OrderedEngine {
+	list := newSkipList()
+	return &OrderedEngine{
+		Engine: &Engine{table: list},
+		list:   list,
+	}
+}
+
+func (e *OrderedEngine) Range(start, end string, limit int, reverse bool) []Entry {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var entries []Entry
+	collect := func(key string, v *value) bool {
+		entries = append(entries, newEntry(key, v))
+		return limit <= 0 || len(entries) < limit
+	}
+
+	if reverse {
+		e.list.descend(end, func(key string, v *value) bool {
+			return key >= start && collect(key, v)
+		})
+	} else {
+		e.list.ascend(start, func(key string, v *value) bool {
+			return key <= end && collect(key, v)
+		})
+	}
+	return entries
+}
+
+func (e *OrderedEngine) Prefix(prefix string, limit int, reverse bool) []Entry {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var entries []Entry
+	collect := func(key string, v *value) bool {
+		entries = append(entries, newEntry(key, v))
+		return limit <= 0 || len(entries) < limit
+	}
+
+	if reverse {
+		e.list.descend(prefixEnd(prefix), func(key string, v *value) bool {
+			if !strings.HasPrefix(key, prefix) {
+				// keys above the prefix range are skipped, below it we stop
+				return key > prefix
+			}
+			return collect(key, v)
+		})
+	} else {
+		e.list.ascend(prefix, func(key string, v *value) bool {
+			return strings.HasPrefix(key, prefix) && collect(key, v)
+		})
+	}
+	return entries
+}
+
+func newEntry(key string, v *value) Entry {
+	return Entry{Key: key, Type: v.kind.String(), Value: v.str}
+}
+
+// prefixEnd returns the smallest key greater than every key with the given
+// prefix, or "" if there is no such key.
+func prefixEnd(prefix string) string {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return string(end[:i+1])
+		}
+	}
+	return ""
+}
@@ -0,0 +1,101 @@
+package storage_test
+
+import (
+	"concurrency_hw1/internal/storage"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestOrderedEngine(t *testing.T) {
+	e := storage.NewOrderedEngine()
+	for _, key := range []string{"user:100", "user:150", "user:200", "user:201", "order:1", "user", "user:\xff"} {
+		e.Set(key, "v"+key)
+	}
+	e.Delete("user:201")
+
+	keys := func(entries []storage.Entry) string {
+		var result []string
+		for _, entry := range entries {
+			result = append(result, entry.Key)
+		}
+		return strings.Join(result, ",")
+	}
+
+	tests := []struct {
+		name   string
+		action func() string
+		want   string
+	}{
+		{
+			name:   "Range is inclusive on both ends",
+			action: func() string { return keys(e.Range("user:100", "user:200", 0, false)) },
+			want:   "user:100,user:150,user:200",
+		},
+		{
+			name:   "Range with limit",
+			action: func() string { return keys(e.Range("user:100", "user:200", 2, false)) },
+			want:   "user:100,user:150",
+		},
+		{
+			name:   "Reverse range",
+			action: func() string { return keys(e.Range("user:100", "user:200", 0, true)) },
+			want:   "user:200,user:150,user:100",
+		},
+		{
+			name:   "Reverse range from a missing end key",
+			action: func() string { return keys(e.Range("user:1", "user:199", 0, true)) },
+			want:   "user:150,user:100",
+		},
+		{
+			name:   "Prefix",
+			action: func() string { return keys(e.Prefix("user:", 0, false)) },
+			want:   "user:100,user:150,user:200,user:\xff",
+		},
+		{
+			name:   "Reverse prefix with limit",
+			action: func() string { return keys(e.Prefix("user:", 2, true)) },
+			want:   "user:\xff,user:200",
+		},
+		{
+			name:   "Keys are sorted",
+			action: func() string { return strings.Join(e.Keys("*"), ",") },
+			want:   "order:1,user,user:100,user:150,user:200,user:\xff",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.action(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrderedEngineScan(t *testing.T) {
+	e := storage.NewOrderedEngine()
+	for i := 0; i < 500; i++ {
+		e.Set(fmt.Sprintf("key:%03d", i), "value")
+	}
+
+	var all []string
+	cursor := storage.ScanStartCursor
+	for {
+		next, keys, err := e.Scan(cursor, "*", 100)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		all = append(all, keys...)
+		// keys deleted behind the cursor must not break the iteration
+		e.Delete(fmt.Sprintf("key:%03d", len(all)-1))
+		if next == storage.ScanStartCursor {
+			break
+		}
+		cursor = next
+	}
+
+	if len(all) != 500 {
+		t.Errorf("got %d keys, want 500", len(all))
+	}
+}
@@ -0,0 +1,192 @@
+package storage
+
+import "math/rand"
+
+const (
+	skipListMaxLevel = 32
+	// skipListP is the probability for a node to get one more level
+	skipListP = 0.25
+	// skipListScanChunk is how many keys one scan step visits
+	skipListScanChunk = 64
+)
+
+type skipListNode struct {
+	key   string
+	value *value
+	prev  *skipListNode
+	next  []*skipListNode
+}
+
+// skipList keeps keys sorted. Level 0 is a doubly linked list, so it can be
+// walked in both directions.
+type skipList struct {
+	head   *skipListNode
+	tail   *skipListNode
+	level  int
+	length int
+	rand   *rand.Rand
+}
+
+func newSkipList() *skipList {
+	return &skipList{
+		head:  &skipListNode{next: make([]*skipListNode, skipListMaxLevel)},
+		level: 1,
+		rand:  rand.New(rand.NewSource(rand.Int63())),
+	}
+}
+
+func (l *skipList) get(key string) (*value, bool) {
+	node := l.seek(key)
+	if node == nil || node.key != key {
+		return nil, false
+	}
+	return node.value, true
+}
+
+func (l *skipList) set(key string, v *value) {
+	var update [skipListMaxLevel]*skipListNode
+	node := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for node.next[i] != nil && node.next[i].key < key {
+			node = node.next[i]
+		}
+		update[i] = node
+	}
+
+	if next := node.next[0]; next != nil && next.key == key {
+		next.value = v
+		return
+	}
+
+	level := l.randomLevel()
+	if level > l.level {
+		for i := l.level; i < level; i++ {
+			update[i] = l.head
+		}
+		l.level = level
+	}
+
+	created := &skipListNode{
+		key:   key,
+		value: v,
+		next:  make([]*skipListNode, level),
+	}
+	for i := 0; i < level; i++ {
+		created.next[i] = update[i].next[i]
+		update[i].next[i] = created
+	}
+
+	if update[0] != l.head {
+		created.prev = update[0]
+	}
+	if created.next[0] != nil {
+		created.next[0].prev = created
+	} else {
+		l.tail = created
+	}
+	l.length++
+}
+
+func (l *skipList) delete(key string) bool {
+	var update [skipListMaxLevel]*skipListNode
+	node := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for node.next[i] != nil && node.next[i].key < key {
+			node = node.next[i]
+		}
+		update[i] = node
+	}
+
+	target := node.next[0]
+	if target == nil || target.key != key {
+		return false
+	}
+
+	for i := 0; i < len(target.next); i++ {
+		update[i].next[i] = target.next[i]
+	}
+	if target.next[0] != nil {
+		target.next[0].prev = target.prev
+	} else {
+		l.tail = target.prev
+	}
+	for l.level > 1 && l.head.next[l.level-1] == nil {
+		l.level--
+	}
+	l.length--
+	return true
+}
+
+func (l *skipList) each(fn func(key string, v *value) bool) {
+	l.ascend("", fn)
+}
+
+// scan visits up to skipListScanChunk keys starting from the key encoded in
+// cursor.
+func (l *skipList) scan(cursor string, fn func(key string)) (string, error) {
+	from, err := decodeKeyCursor(cursor)
+	if err != nil {
+		return "", err
+	}
+
+	node := l.seek(from)
+	for i := 0; node != nil && i < skipListScanChunk; i++ {
+		fn(node.key)
+		node = node.next[0]
+	}
+
+	if node == nil {
+		return ScanStartCursor, nil
+	}
+	return encodeKeyCursor(node.key), nil
+}
+
+// ascend calls fn for keys >= from in ascending order while fn returns true.
+func (l *skipList) ascend(from string, fn func(key string, v *value) bool) {
+	for node := l.seek(from); node != nil; node = node.next[0] {
+		if !fn(node.key, node.value) {
+			return
+		}
+	}
+}
+
+// descend calls fn for keys <= from in descending order while fn returns
+// true. An empty from starts at the last key.
+func (l *skipList) descend(from string, fn func(key string, v *value) bool) {
+	var node *skipListNode
+	if from == "" {
+		node = l.tail
+	} else {
+		node = l.seek(from)
+		if node == nil {
+			node = l.tail
+		} else if node.key != from {
+			node = node.prev
+		}
+	}
+
+	for ; node != nil; node = node.prev {
+		if !fn(node.key, node.value) {
+			return
+		}
+	}
+}
+
+// seek returns the first node with a key >= key.
+func (l *skipList) seek(key string) *skipListNode {
+	node := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for node.next[i] != nil && node.next[i].key < key {
+			node = node.next[i]
+		}
+	}
+	return node.next[0]
+}
+
+func (l *skipList) randomLevel() int {
+	level := 1
+	for level < skipListMaxLevel && l.rand.Float64() < skipListP {
+		level++
+	}
+	return level
+}
@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+	"strconv"
+)
+
+var ErrWrongType = errors.New("WRONGTYPE operation against a key holding the wrong kind of value")
+
+type valueType uint8
+
+const (
+	typeString valueType = iota
+	typeList
+	typeHash
+	typeSet
+	typeZSet
+)
+
+const TypeNone = "none"
+
+func (t valueType) String() string {
+	switch t {
+	case typeString:
+		return "string"
+	case typeList:
+		return "list"
+	case typeHash:
+		return "hash"
+	case typeSet:
+		return "set"
+	case typeZSet:
+		return "zset"
+	default:
+		return "unknown"
+	}
+}
+
+// value is what a table stores for a key. Only the field matching kind is
+// used.
+type value struct {
+	kind valueType
+	str  string
+	list *deque
+	hash map[string]string
+	set  map[string]struct{}
+	zset *zset
+}
+
+func newValue(kind valueType) *value {
+	v := &value{kind: kind}
+	switch kind {
+	case typeList:
+		v.list = &deque{}
+	case typeHash:
+		v.hash = make(map[string]string)
+	case typeSet:
+		v.set = make(map[string]struct{})
+	case typeZSet:
+		v.zset = newZSet()
+	}
+	return v
+}
+
+func stringValue(str string) *value {
+	return &value{kind: typeString, str: str}
+}
+
+func (v *value) empty() bool {
+	switch v.kind {
+	case typeList:
+		return v.list.len() == 0
+	case typeHash:
+		return len(v.hash) == 0
+	case typeSet:
+		return len(v.set) == 0
+	case typeZSet:
+		return v.zset.len() == 0
+	default:
+		return false
+	}
+}
+
+// Values are encoded for tables that keep bytes (the LSM engine). A string
+// is stored as is unless it starts with valueMarker, every other value is
+// valueMarker, the type and a list of length prefixed items.
+const valueMarker = 0
+
+func encodeValue(v *value) string {
+	if v.kind == typeString && (len(v.str) == 0 || v.str[0] != valueMarker) {
+		return v.str
+	}
+
+	var items []string
+	switch v.kind {
+	case typeString:
+		items = []string{v.str}
+	case typeList:
+		items = v.list.slice(0, v.list.len()-1)
+	case typeHash:
+		for _, field := range sortedKeys(v.hash) {
+			items = append(items, field, v.hash[field])
+		}
+	case typeSet:
+		items = sortedKeys(v.set)
+	case typeZSet:
+		for node := v.zset.list.head.levels[0].next; node != nil; node = node.levels[0].next {
+			items = append(items, node.member, strconv.FormatFloat(node.score, 'g', -1, 64))
+		}
+	}
+
+	buf := []byte{valueMarker, byte(v.kind)}
+	buf = binary.AppendUvarint(buf, uint64(len(items)))
+	for _, item := range items {
+		buf = binary.AppendUvarint(buf, uint64(len(item)))
+		buf = append(buf, item...)
+	}
+	return string(buf)
+}
+
+func decodeValue(data string) (*value, error) {
+	if len(data) == 0 || data[0] != valueMarker {
+		return stringValue(data), nil
+	}
+	if len(data) < 2 {
+		return nil, errors.New("corrupted value")
+	}
+
+	buf := []byte(data[2:])
+	count, n := binary.Uvarint(buf)
+	if n <= 0 {
+		return nil, errors.New("corrupted value")
+	}
+	buf = buf[n:]
+	// every item takes at least the byte of its length
+	if count > uint64(len(buf)) {
+		return nil, errors.New("corrupted value")
+	}
+
+	items := make([]string, 0, count)
+	for i := uint64(0); i < count; i++ {
+		length, n := binary.Uvarint(buf)
+		if n <= 0 || uint64(len(buf)-n) < length {
+			return nil, errors.New("corrupted value")
+		}
+		items = append(items, string(buf[n:n+int(length)]))
+		buf = buf[n+int(length):]
+	}
+
+	v := newValue(valueType(data[1]))
+	switch v.kind {
+	case typeString:
+		if len(items) != 1 {
+			return nil, errors.New("corrupted value")
+		}
+		v.str = items[0]
+	case typeList:
+		for _, item := range items {
+			v.list.pushBack(item)
+		}
+	case typeHash:
+		if len(items)%2 != 0 {
+			return nil, errors.New("corrupted value")
+		}
+		for i := 0; i < len(items); i += 2 {
+			v.hash[items[i]] = items[i+1]
+		}
+	case typeSet:
+		for _, item := range items {
+			v.set[item] = struct{}{}
+		}
+	case typeZSet:
+		if len(items)%2 != 0 {
+			return nil, errors.New("corrupted value")
+		}
+		for i := 0; i < len(items); i += 2 {
+			score, err := strconv.ParseFloat(items[i+1], 64)
+			if err != nil {
+				return nil, errors.New("corrupted value")
+			}
+			v.zset.add(items[i], score)
+		}
+	default:
+		return nil, errors.New("unknown value type")
+	}
+	return v, nil
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
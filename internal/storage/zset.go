@@ -0,0 +1,408 @@
+package storage
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+)
+
+var ErrScoreNaN = errors.New("resulting score is not a number (NaN)")
+
+// ScoredMember is a member of a sorted set with its score.
+type ScoredMember struct {
+	Member string
+	Score  float64
+}
+
+// ScoreRange selects scores between Min and Max, each end is inclusive
+// unless the matching Exclusive flag is set.
+type ScoreRange struct {
+	Min, Max                   float64
+	MinExclusive, MaxExclusive bool
+}
+
+func (r ScoreRange) aboveMin(score float64) bool {
+	return score > r.Min || (!r.MinExclusive && score == r.Min)
+}
+
+func (r ScoreRange) belowMax(score float64) bool {
+	return score < r.Max || (!r.MaxExclusive && score == r.Max)
+}
+
+// zset is a sorted set: the map answers score lookups in O(1), the skip list
+// keeps members ordered by score and then by member.
+type zset struct {
+	scores map[string]float64
+	list   *zskipList
+}
+
+func newZSet() *zset {
+	return &zset{
+		scores: make(map[string]float64),
+		list:   newZSkipList(),
+	}
+}
+
+func (z *zset) len() int {
+	return len(z.scores)
+}
+
+// add sets the score of a member and reports whether the member is new.
+func (z *zset) add(member string, score float64) bool {
+	old, ok := z.scores[member]
+	if ok {
+		if old == score {
+			return false
+		}
+		z.list.delete(old, member)
+	}
+	z.scores[member] = score
+	z.list.insert(score, member)
+	return !ok
+}
+
+func (z *zset) remove(member string) bool {
+	score, ok := z.scores[member]
+	if !ok {
+		return false
+	}
+	delete(z.scores, member)
+	z.list.delete(score, member)
+	return true
+}
+
+// rangeByRank returns members from start to stop inclusive, indexes must be
+// valid.
+func (z *zset) rangeByRank(start, stop int) []ScoredMember {
+	members := make([]ScoredMember, 0, stop-start+1)
+	for node := z.list.byRank(start); node != nil && len(members) < stop-start+1; node = node.levels[0].next {
+		members = append(members, ScoredMember{Member: node.member, Score: node.score})
+	}
+	return members
+}
+
+// rangeByScore skips offset members of the range and returns up to count of
+// the rest, all of them if count <= 0.
+func (z *zset) rangeByScore(r ScoreRange, offset, count int) []ScoredMember {
+	var members []ScoredMember
+	for node := z.list.firstInRange(r); node != nil && r.belowMax(node.score); node = node.levels[0].next {
+		if offset > 0 {
+			offset--
+			continue
+		}
+		if count > 0 && len(members) == count {
+			break
+		}
+		members = append(members, ScoredMember{Member: node.member, Score: node.score})
+	}
+	return members
+}
+
+type zskipListLevel struct {
+	next *zskipListNode
+	// span is the number of nodes the link jumps over, used for ranks
+	span int
+}
+
+type zskipListNode struct {
+	member string
+	score  float64
+	prev   *zskipListNode
+	levels []zskipListLevel
+}
+
+// before reports whether the node sorts before the given score and member.
+func (n *zskipListNode) before(score float64, member string) bool {
+	return n.score < score || (n.score == score && n.member < member)
+}
+
+// zskipList is a skip list ordered by score and member whose links know how
+// many nodes they skip, so ranks are found in O(log n).
+type zskipList struct {
+	head   *zskipListNode
+	tail   *zskipListNode
+	level  int
+	length int
+	rand   *rand.Rand
+}
+
+func newZSkipList() *zskipList {
+	return &zskipList{
+		head:  &zskipListNode{levels: make([]zskipListLevel, skipListMaxLevel)},
+		level: 1,
+		rand:  rand.New(rand.NewSource(rand.Int63())),
+	}
+}
+
+// insert adds a member, the caller makes sure it is not in the list yet.
+func (l *zskipList) insert(score float64, member string) {
+	var update [skipListMaxLevel]*zskipListNode
+	var rank [skipListMaxLevel]int
+
+	node := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		if i < l.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for next := node.levels[i].next; next != nil && next.before(score, member); next = node.levels[i].next {
+			rank[i] += node.levels[i].span
+			node = next
+		}
+		update[i] = node
+	}
+
+	level := l.randomLevel()
+	if level > l.level {
+		for i := l.level; i < level; i++ {
+			rank[i] = 0
+			update[i] = l.head
+			update[i].levels[i].span = l.length
+		}
+		l.level = level
+	}
+
+	created := &zskipListNode{
+		member: member,
+		score:  score,
+		levels: make([]zskipListLevel, level),
+	}
+	for i := 0; i < level; i++ {
+		created.levels[i].next = update[i].levels[i].next
+		update[i].levels[i].next = created
+		created.levels[i].span = update[i].levels[i].span - (rank[0] - rank[i])
+		update[i].levels[i].span = rank[0] - rank[i] + 1
+	}
+	for i := level; i < l.level; i++ {
+		update[i].levels[i].span++
+	}
+
+	if update[0] != l.head {
+		created.prev = update[0]
+	}
+	if next := created.levels[0].next; next != nil {
+		next.prev = created
+	} else {
+		l.tail = created
+	}
+	l.length++
+}
+
+func (l *zskipList) delete(score float64, member string) bool {
+	var update [skipListMaxLevel]*zskipListNode
+	node := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for next := node.levels[i].next; next != nil && next.before(score, member); next = node.levels[i].next {
+			node = next
+		}
+		update[i] = node
+	}
+
+	target := node.levels[0].next
+	if target == nil || target.score != score || target.member != member {
+		return false
+	}
+
+	for i := 0; i < l.level; i++ {
+		if update[i].levels[i].next == target {
+			update[i].levels[i].span += target.levels[i].span - 1
+			update[i].levels[i].next = target.levels[i].next
+		} else {
+			update[i].levels[i].span--
+		}
+	}
+	if next := target.levels[0].next; next != nil {
+		next.prev = target.prev
+	} else {
+		l.tail = target.prev
+	}
+	for l.level > 1 && l.head.levels[l.level-1].next == nil {
+		l.level--
+	}
+	l.length--
+	return true
+}
+
+// rank returns the 0-based position of a member or -1.
+func (l *zskipList) rank(score float64, member string) int {
+	rank := 0
+	node := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for next := node.levels[i].next; next != nil && (next.before(score, member) || next.score == score && next.member == member); next = node.levels[i].next {
+			rank += node.levels[i].span
+			node = next
+		}
+		if node != l.head && node.member == member {
+			return rank - 1
+		}
+	}
+	return -1
+}
+
+// byRank returns the node at the 0-based rank or nil.
+func (l *zskipList) byRank(rank int) *zskipListNode {
+	traversed := 0
+	node := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for node.levels[i].next != nil && traversed+node.levels[i].span <= rank+1 {
+			traversed += node.levels[i].span
+			node = node.levels[i].next
+		}
+		if traversed == rank+1 {
+			return node
+		}
+	}
+	return nil
+}
+
+// firstInRange returns the first node whose score is above the minimum of
+// the range or nil.
+func (l *zskipList) firstInRange(r ScoreRange) *zskipListNode {
+	node := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for next := node.levels[i].next; next != nil && !r.aboveMin(next.score); next = node.levels[i].next {
+			node = next
+		}
+	}
+	return node.levels[0].next
+}
+
+func (l *zskipList) randomLevel() int {
+	level := 1
+	for level < skipListMaxLevel && l.rand.Float64() < skipListP {
+		level++
+	}
+	return level
+}
+
+func (e *Engine) ZAdd(key string, members []ScoredMember) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	v, err := e.getOrCreate(key, typeZSet)
+	if err != nil {
+		return 0, err
+	}
+
+	added := 0
+	for _, m := range members {
+		if math.IsNaN(m.Score) {
+			return 0, ErrScoreNaN
+		}
+	}
+	for _, m := range members {
+		if v.zset.add(m.Member, m.Score) {
+			added++
+		}
+	}
+	e.put(key, v)
+	return added, nil
+}
+
+func (e *Engine) ZRem(key string, members ...string) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	v, err := e.lookup(key, typeZSet)
+	if err != nil || v == nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, member := range members {
+		if v.zset.remove(member) {
+			removed++
+		}
+	}
+	if removed > 0 {
+		e.store(key, v)
+	}
+	return removed, nil
+}
+
+func (e *Engine) ZScore(key, member string) (float64, bool, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	v, err := e.lookup(key, typeZSet)
+	if err != nil || v == nil {
+		return 0, false, err
+	}
+
+	score, ok := v.zset.scores[member]
+	return score, ok, nil
+}
+
+// ZIncrBy adds increment to the score of a member, a missing member starts
+// from zero. It returns the new score.
+func (e *Engine) ZIncrBy(key string, increment float64, member string) (float64, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	v, err := e.getOrCreate(key, typeZSet)
+	if err != nil {
+		return 0, err
+	}
+
+	score := v.zset.scores[member] + increment
+	if math.IsNaN(score) {
+		return 0, ErrScoreNaN
+	}
+	v.zset.add(member, score)
+	e.put(key, v)
+	return score, nil
+}
+
+// ZRank returns the 0-based position of a member ordered by score.
+func (e *Engine) ZRank(key, member string) (int, bool, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	v, err := e.lookup(key, typeZSet)
+	if err != nil || v == nil {
+		return 0, false, err
+	}
+
+	score, ok := v.zset.scores[member]
+	if !ok {
+		return 0, false, nil
+	}
+	return v.zset.list.rank(score, member), true, nil
+}
+
+// ZRange returns members from start to stop rank inclusive, negative
+// indexes count from the highest score.
+func (e *Engine) ZRange(key string, start, stop int) ([]ScoredMember, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	v, err := e.lookup(key, typeZSet)
+	if err != nil || v == nil {
+		return nil, err
+	}
+
+	size := v.zset.len()
+	if start < 0 {
+		start = max(size+start, 0)
+	}
+	if stop < 0 {
+		stop = size + stop
+	}
+	stop = min(stop, size-1)
+	if start > stop {
+		return nil, nil
+	}
+	return v.zset.rangeByRank(start, stop), nil
+}
+
+// ZRangeByScore returns members with scores in the range ordered by score,
+// skipping offset of them and returning at most count, all if count <= 0.
+func (e *Engine) ZRangeByScore(key string, r ScoreRange, offset, count int) ([]ScoredMember, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	v, err := e.lookup(key, typeZSet)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	return v.zset.rangeByScore(r, offset, count), nil
+}
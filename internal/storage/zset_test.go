@@ -0,0 +1,198 @@
+package storage_test
+
+import (
+	"concurrency_hw1/internal/storage"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSortedSet(t *testing.T) {
+	e := storage.NewEngine()
+	e.ZAdd("board", []storage.ScoredMember{
+		{Member: "carol", Score: 30},
+		{Member: "alice", Score: 10},
+		{Member: "bob", Score: 20},
+		{Member: "dave", Score: 20},
+		{Member: "eve", Score: 50},
+	})
+	e.ZRem("board", "eve")
+	e.Set("str", "value")
+
+	members := func(m []storage.ScoredMember, err error) string {
+		if err != nil {
+			return err.Error()
+		}
+		var result []string
+		for _, member := range m {
+			result = append(result, fmt.Sprintf("%s=%g", member.Member, member.Score))
+		}
+		return strings.Join(result, ",")
+	}
+
+	tests := []struct {
+		name   string
+		action func() string
+		want   string
+	}{
+		{
+			name:   "ZRange orders by score then member",
+			action: func() string { return members(e.ZRange("board", 0, -1)) },
+			want:   "alice=10,bob=20,dave=20,carol=30",
+		},
+		{
+			name:   "ZRange with negative start",
+			action: func() string { return members(e.ZRange("board", -2, -1)) },
+			want:   "dave=20,carol=30",
+		},
+		{
+			name: "ZRangeByScore with exclusive min",
+			action: func() string {
+				return members(e.ZRangeByScore("board", storage.ScoreRange{Min: 10, Max: math.Inf(1), MinExclusive: true}, 0, 0))
+			},
+			want: "bob=20,dave=20,carol=30",
+		},
+		{
+			name: "ZRangeByScore with offset and count",
+			action: func() string {
+				return members(e.ZRangeByScore("board", storage.ScoreRange{Min: math.Inf(-1), Max: 30}, 1, 2))
+			},
+			want: "bob=20,dave=20",
+		},
+		{
+			name: "ZRank",
+			action: func() string {
+				rank, ok, _ := e.ZRank("board", "carol")
+				_, removed, _ := e.ZRank("board", "eve")
+				return fmt.Sprint(rank, ok, removed)
+			},
+			want: "3 true false",
+		},
+		{
+			name: "ZIncrBy moves a member",
+			action: func() string {
+				e.ZIncrBy("board", 25, "alice")
+				return members(e.ZRange("board", 0, -1))
+			},
+			want: "bob=20,dave=20,carol=30,alice=35",
+		},
+		{
+			name: "ZIncrBy refuses NaN",
+			action: func() string {
+				e.ZAdd("inf", []storage.ScoredMember{{Member: "m", Score: math.Inf(1)}})
+				_, err := e.ZIncrBy("inf", math.Inf(-1), "m")
+				return fmt.Sprint(err == storage.ErrScoreNaN)
+			},
+			want: "true",
+		},
+		{
+			name: "ZScore on a string",
+			action: func() string {
+				_, _, err := e.ZScore("str", "m")
+				return fmt.Sprint(err == storage.ErrWrongType)
+			},
+			want: "true",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.action(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortedSetConcurrentIncrements(t *testing.T) {
+	e := storage.NewEngine()
+
+	const workers, increments, members = 8, 500, 10
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < increments; i++ {
+				if _, err := e.ZIncrBy("counter", 1, fmt.Sprintf("m%d", i%members)); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i := 0; i < members; i++ {
+		score, _, _ := e.ZScore("counter", fmt.Sprintf("m%d", i))
+		if want := float64(workers * increments / members); score != want {
+			t.Errorf("m%d: got score %g, want %g", i, score, want)
+		}
+	}
+}
+
+// TestSortedSetConsistentReads checks that readers never see a half applied
+// update while writers move members around.
+func TestSortedSetConsistentReads(t *testing.T) {
+	e := storage.NewEngine()
+
+	const size = 200
+	for i := 0; i < size; i++ {
+		e.ZAdd("set", []storage.ScoredMember{{Member: fmt.Sprintf("m%03d", i), Score: float64(i)}})
+	}
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				member := fmt.Sprintf("m%03d", (i*7+w)%size)
+				e.ZIncrBy("set", float64(i%5)-2, member)
+			}
+		}(w)
+	}
+
+	for i := 0; i < 200; i++ {
+		members, err := e.ZRange("set", 0, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(members) != size {
+			t.Fatalf("got %d members, want %d", len(members), size)
+		}
+		sorted := sort.SliceIsSorted(members, func(a, b int) bool {
+			if members[a].Score != members[b].Score {
+				return members[a].Score < members[b].Score
+			}
+			return members[a].Member < members[b].Member
+		})
+		if !sorted {
+			t.Fatal("members are not sorted")
+		}
+
+		probe := members[i%size]
+		rank, _, _ := e.ZRank("set", probe.Member)
+		if rank < 0 || rank >= size {
+			t.Fatalf("rank %d out of bounds", rank)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	members, _ := e.ZRange("set", 0, -1)
+	for i, m := range members {
+		if rank, _, _ := e.ZRank("set", m.Member); rank != i {
+			t.Errorf("%s: got rank %d, want %d", m.Member, rank, i)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package wal
+
+import (
+	"bufio"
+	"concurrency_hw1/pkg/disk"
+	"concurrency_hw1/pkg/logger"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ApplyFunc applies one command read back from the log.
+type ApplyFunc func(command string, args []string) error
+
+// Recover reads every segment of the log at path in write order and passes
+// its records to apply. Records that fail to apply are logged and skipped,
+// so a single bad line does not lose the rest of the log.
+func Recover(path string, apply ApplyFunc, logger *logger.Logger) (int, error) {
+	segments, err := disk.Segments(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list WAL segments: %w", err)
+	}
+
+	applied := 0
+	for _, segment := range segments {
+		n, err := recoverSegment(segment, apply, logger)
+		applied += n
+		if err != nil {
+			return applied, fmt.Errorf("failed to read WAL segment %s: %w", segment, err)
+		}
+	}
+	return applied, nil
+}
+
+func recoverSegment(path string, apply ApplyFunc, logger *logger.Logger) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	applied := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if err := apply(fields[0], fields[1:]); err != nil {
+			logger.Error("skipping WAL record %s:%d: %v", path, line, err)
+			continue
+		}
+		applied++
+	}
+	return applied, scanner.Err()
+}
@@ -0,0 +1,69 @@
+package wal_test
+
+import (
+	"concurrency_hw1/internal/wal"
+	"concurrency_hw1/pkg/logger"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRecover(t *testing.T) {
+	arity := map[string]int{"SET": 2, "DEL": 1}
+
+	tests := []struct {
+		name        string
+		segments    map[string]string
+		want        []string
+		wantApplied int
+	}{
+		{
+			// records were not newline terminated, they cannot be told apart
+			// and the arity check of apply rejects them
+			name:        "legacy records glued together",
+			segments:    map[string]string{"wal": "SET a 1SET b 2DEL x"},
+			want:        []string{"SET a 1SET b 2DEL x"},
+			wantApplied: 0,
+		},
+		{
+			name:        "legacy record terminated before newer records",
+			segments:    map[string]string{"wal": "SET 1 1\nSET 2 2\n"},
+			want:        []string{"SET 1 1", "SET 2 2"},
+			wantApplied: 2,
+		},
+		{
+			name:        "segments in write order and failing records skipped",
+			segments:    map[string]string{"wal": "SET a 1\nBAD\n", "wal.200": "DEL a\n", "wal.100": "SET b 2\n"},
+			want:        []string{"SET a 1", "BAD", "SET b 2", "DEL a"},
+			wantApplied: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, data := range tt.segments {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var got []string
+			applied, err := wal.Recover(filepath.Join(dir, "wal"), func(command string, args []string) error {
+				got = append(got, strings.Join(append([]string{command}, args...), " "))
+				if command == "BAD" || len(args) != arity[command] {
+					return errors.New("bad record")
+				}
+				return nil
+			}, logger.New("error", ""))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) || applied != tt.wantApplied {
+				t.Errorf("got %q and %d applied, want %q and %d", got, applied, tt.want, tt.wantApplied)
+			}
+		})
+	}
+}
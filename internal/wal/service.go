@@ -3,14 +3,19 @@ package wal
 import (
 	"concurrency_hw1/pkg/logger"
 	"context"
+	"sync"
 	"time"
 )
 
 type WALService struct {
-	storeChan  chan []byte
-	logger     *logger.Logger
+	storeChan chan []byte
+	logger    *logger.Logger
+	// size and timeout may be changed by SetBatch while the service runs,
+	// reset tells the loop to restart its ticker
+	mu         sync.Mutex
 	size       int
 	timeout    time.Duration
+	reset      chan struct{}
 	WALChannel chan ([]byte)
 	batch      [][]byte
 }
@@ -21,6 +26,7 @@ func NewWALService(storeChan chan []byte, size int, timeout time.Duration, logge
 		size:       size,
 		timeout:    timeout,
 		logger:     logger,
+		reset:      make(chan struct{}, 1),
 		WALChannel: make(chan []byte),
 		batch:      make([][]byte, 0),
 	}
@@ -30,8 +36,29 @@ func (w *WALService) Start(ctx context.Context) {
 	go w.run(ctx)
 }
 
+// SetBatch changes how many records are batched and how long they may
+// wait before they are flushed.
+func (w *WALService) SetBatch(size int, timeout time.Duration) {
+	w.mu.Lock()
+	w.size = size
+	w.timeout = timeout
+	w.mu.Unlock()
+
+	select {
+	case w.reset <- struct{}{}:
+	default:
+	}
+}
+
+func (w *WALService) batchSettings() (int, time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.size, w.timeout
+}
+
 func (w *WALService) run(ctx context.Context) {
-	t := time.NewTicker(w.timeout)
+	size, timeout := w.batchSettings()
+	t := time.NewTicker(timeout)
 	defer t.Stop()
 
 	for {
@@ -44,8 +71,11 @@ func (w *WALService) run(ctx context.Context) {
 		select {
 		case <-ctx.Done():
 			return
+		case <-w.reset:
+			size, timeout = w.batchSettings()
+			t.Reset(timeout)
 		case operation := <-w.WALChannel:
-			if len(w.batch) > w.size {
+			if len(w.batch) > size {
 				w.handleRecord(w.batch)
 				w.batch = nil
 			}
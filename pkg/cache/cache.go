@@ -0,0 +1,219 @@
+package cache
+
+import (
+	"concurrency_hw1/pkg/client"
+	"concurrency_hw1/pkg/concurrency"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	defaultParallelism = 8
+	keysCallKey        = "keys"
+)
+
+// ErrNotFound is returned by Get for a missing key. A backing database
+// reports missing keys with it too, possibly wrapped, so they can be cached.
+// It is the error of client.Client, the usual backing database.
+var ErrNotFound = client.ErrNotFound
+
+// KVDatabase is a key-value store, MGet returns nil for missing keys.
+type KVDatabase interface {
+	Get(key string) (string, error)
+	Keys() ([]string, error)
+	MGet(keys []string) ([]*string, error)
+}
+
+type Option func(*Cache)
+
+// WithTTL sets how long values are kept, forever if it is zero.
+func WithTTL(ttl time.Duration) Option {
+	return func(c *Cache) {
+		c.ttl = ttl
+	}
+}
+
+// WithNegativeTTL makes the cache remember missing keys for ttl, they are
+// not cached if it is zero.
+func WithNegativeTTL(ttl time.Duration) Option {
+	return func(c *Cache) {
+		c.negativeTTL = ttl
+	}
+}
+
+// WithParallelism sets how many misses of one MGet are loaded at once.
+func WithParallelism(parallelism int) Option {
+	return func(c *Cache) {
+		c.parallelism = parallelism
+	}
+}
+
+// Cache is a read-through cache in front of a KVDatabase. Concurrent
+// misses of a key are loaded from the database with a single call.
+// Expired entries are dropped when they are read.
+type Cache struct {
+	db          KVDatabase
+	ttl         time.Duration
+	negativeTTL time.Duration
+	parallelism int
+
+	mu      sync.RWMutex
+	entries map[string]entry
+
+	loads concurrency.Group[entry]
+	keys  concurrency.Group[[]string]
+}
+
+type entry struct {
+	value   string
+	found   bool
+	expires time.Time
+}
+
+var _ KVDatabase = (*Cache)(nil)
+
+func New(db KVDatabase, options ...Option) *Cache {
+	c := &Cache{
+		db:          db,
+		parallelism: defaultParallelism,
+		entries:     make(map[string]entry),
+	}
+	for _, option := range options {
+		option(c)
+	}
+	c.parallelism = max(c.parallelism, 1)
+	return c
+}
+
+func (c *Cache) Get(key string) (string, error) {
+	e, err := c.get(key)
+	if err != nil {
+		return "", err
+	}
+	if !e.found {
+		return "", ErrNotFound
+	}
+	return e.value, nil
+}
+
+// Keys is not cached, but concurrent calls share one call to the database.
+func (c *Cache) Keys() ([]string, error) {
+	keys, err, shared := c.keys.Do(keysCallKey, c.db.Keys)
+	if err != nil {
+		return nil, err
+	}
+	if shared {
+		keys = append([]string(nil), keys...)
+	}
+	return keys, nil
+}
+
+// MGet returns the cached values and loads the misses one key at a time,
+// with at most the parallelism of the cache at once. It fails with the
+// first error of a load.
+func (c *Cache) MGet(keys []string) ([]*string, error) {
+	values := make([]*string, len(keys))
+	var misses []int
+	for i, key := range keys {
+		e, ok := c.lookup(key)
+		if !ok {
+			misses = append(misses, i)
+			continue
+		}
+		if e.found {
+			values[i] = &e.value
+		}
+	}
+	if len(misses) == 0 {
+		return values, nil
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var firstErr error
+	for i := 0; i < min(c.parallelism, len(misses)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				e, err := c.get(keys[index])
+				if err != nil {
+					errOnce.Do(func() { firstErr = err })
+					continue
+				}
+				if e.found {
+					values[index] = &e.value
+				}
+			}
+		}()
+	}
+	for _, index := range misses {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return values, nil
+}
+
+func (c *Cache) get(key string) (entry, error) {
+	if e, ok := c.lookup(key); ok {
+		return e, nil
+	}
+
+	e, err, _ := c.loads.Do(key, func() (entry, error) {
+		return c.load(key)
+	})
+	return e, err
+}
+
+// lookup returns the entry of key unless it is missing or expired.
+func (c *Cache) lookup(key string) (entry, bool) {
+	c.mu.RLock()
+	e, ok := c.entries[key]
+	c.mu.RUnlock()
+	if !ok {
+		return entry{}, false
+	}
+	if !e.expires.IsZero() && !time.Now().Before(e.expires) {
+		c.mu.Lock()
+		// it may have been loaded again meanwhile
+		if current, ok := c.entries[key]; ok && current.expires.Equal(e.expires) {
+			delete(c.entries, key)
+		}
+		c.mu.Unlock()
+		return entry{}, false
+	}
+	return e, true
+}
+
+// load reads key from the database and caches the result, errors other
+// than ErrNotFound are not cached.
+func (c *Cache) load(key string) (entry, error) {
+	value, err := c.db.Get(key)
+	e := entry{value: value, found: true}
+	ttl := c.ttl
+	switch {
+	case errors.Is(err, ErrNotFound):
+		e = entry{}
+		ttl = c.negativeTTL
+		if ttl == 0 {
+			return e, nil
+		}
+	case err != nil:
+		return entry{}, err
+	}
+
+	if ttl > 0 {
+		e.expires = time.Now().Add(ttl)
+	}
+	c.mu.Lock()
+	c.entries[key] = e
+	c.mu.Unlock()
+	return e, nil
+}
@@ -0,0 +1,206 @@
+package cache_test
+
+import (
+	"concurrency_hw1/pkg/cache"
+	"concurrency_hw1/pkg/client"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeDatabase counts its calls, a Get waits for release if it is set.
+type fakeDatabase struct {
+	values  map[string]string
+	release chan struct{}
+
+	calls   atomic.Int64
+	running atomic.Int64
+	peak    atomic.Int64
+}
+
+func (d *fakeDatabase) Get(key string) (string, error) {
+	d.calls.Add(1)
+	running := d.running.Add(1)
+	defer d.running.Add(-1)
+	for {
+		peak := d.peak.Load()
+		if running <= peak || d.peak.CompareAndSwap(peak, running) {
+			break
+		}
+	}
+
+	if d.release != nil {
+		<-d.release
+	}
+	value, ok := d.values[key]
+	if !ok {
+		return "", cache.ErrNotFound
+	}
+	return value, nil
+}
+
+func (d *fakeDatabase) Keys() ([]string, error) {
+	var keys []string
+	for key := range d.values {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (d *fakeDatabase) MGet(keys []string) ([]*string, error) {
+	return nil, errors.New("not used by the cache")
+}
+
+func TestCacheCoalescesMisses(t *testing.T) {
+	db := &fakeDatabase{values: map[string]string{"a": "1"}, release: make(chan struct{})}
+	c := cache.New(db)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if value, err := c.Get("a"); err != nil || value != "1" {
+				t.Errorf("got %q, %v, want 1", value, err)
+			}
+		}()
+	}
+	for db.running.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(db.release)
+	wg.Wait()
+
+	if got := db.calls.Load(); got != 1 {
+		t.Errorf("got %d database calls, want 1", got)
+	}
+}
+
+func TestCacheExpiration(t *testing.T) {
+	tests := []struct {
+		name      string
+		options   []cache.Option
+		key       string
+		wait      time.Duration
+		wantErr   error
+		wantCalls int64
+	}{
+		{
+			name:      "Cached value",
+			options:   []cache.Option{cache.WithTTL(time.Minute)},
+			key:       "a",
+			wantCalls: 1,
+		},
+		{
+			name:      "Expired value",
+			options:   []cache.Option{cache.WithTTL(10 * time.Millisecond)},
+			key:       "a",
+			wait:      20 * time.Millisecond,
+			wantCalls: 2,
+		},
+		{
+			name:      "Missing key without negative caching",
+			key:       "b",
+			wantErr:   cache.ErrNotFound,
+			wantCalls: 2,
+		},
+		{
+			name:      "Missing key with negative caching",
+			options:   []cache.Option{cache.WithNegativeTTL(time.Minute)},
+			key:       "b",
+			wantErr:   cache.ErrNotFound,
+			wantCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &fakeDatabase{values: map[string]string{"a": "1"}}
+			c := cache.New(db, tt.options...)
+
+			for i := 0; i < 2; i++ {
+				if _, err := c.Get(tt.key); !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got %v, want %v", err, tt.wantErr)
+				}
+				time.Sleep(tt.wait)
+			}
+			if got := db.calls.Load(); got != tt.wantCalls {
+				t.Errorf("got %d database calls, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestCacheMGet(t *testing.T) {
+	db := &fakeDatabase{values: map[string]string{"a": "1", "b": "2", "c": "3", "d": "4", "e": "5"}}
+	c := cache.New(db, cache.WithParallelism(2))
+	if _, err := c.Get("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	release := make(chan struct{})
+	db.release = release
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+	}()
+
+	values, err := c.MGet([]string{"a", "b", "missing", "c", "d", "e"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"1", "2", "", "3", "4", "5"}
+	for i, value := range values {
+		switch {
+		case value == nil && want[i] != "":
+			t.Errorf("value %d is missing, want %s", i, want[i])
+		case value != nil && *value != want[i]:
+			t.Errorf("got value %d %s, want %s", i, *value, want[i])
+		}
+	}
+	if got := db.calls.Load(); got != 6 {
+		t.Errorf("got %d database calls, want 6", got)
+	}
+	if got := db.peak.Load(); got > 2 {
+		t.Errorf("%d loads ran at once, want at most 2", got)
+	}
+}
+
+// fakeSender answers GET requests of a client from values.
+type fakeSender struct {
+	values map[string]string
+	calls  atomic.Int64
+}
+
+func (s *fakeSender) Send(request []byte) ([]byte, error) {
+	s.calls.Add(1)
+	fields := strings.Fields(string(request))
+	if len(fields) != 2 || fields[0] != "GET" {
+		return []byte("ERR unexpected request"), nil
+	}
+	if value, ok := s.values[fields[1]]; ok {
+		return []byte(value), nil
+	}
+	return []byte(" "), nil
+}
+
+func TestCacheOverClient(t *testing.T) {
+	sender := &fakeSender{values: map[string]string{"a": "1"}}
+	c := cache.New(client.New(sender), cache.WithNegativeTTL(time.Minute))
+
+	for i := 0; i < 2; i++ {
+		if value, err := c.Get("a"); err != nil || value != "1" {
+			t.Errorf("got %q and %v, want 1", value, err)
+		}
+		if _, err := c.Get("missing"); !errors.Is(err, cache.ErrNotFound) || !errors.Is(err, client.ErrNotFound) {
+			t.Errorf("got %v for a missing key, want %v", err, cache.ErrNotFound)
+		}
+	}
+	if got := sender.calls.Load(); got != 2 {
+		t.Errorf("got %d requests, want the value and the miss loaded once", got)
+	}
+}
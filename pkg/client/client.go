@@ -0,0 +1,232 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	errorPrefix = "ERR "
+	nilValue    = "(nil)"
+	emptyValue  = " "
+)
+
+var (
+	ErrNotFound        = errors.New("key not found")
+	ErrInvalidArgument = errors.New("argument must be non-empty and must not contain whitespace")
+)
+
+// Sender sends a raw request to the server, both network.TCPClient and
+// network.Pool implement it.
+type Sender interface {
+	Send(request []byte) ([]byte, error)
+}
+
+// ServerError is returned when the server answers a request with an error.
+type ServerError struct {
+	Message string
+}
+
+func (e *ServerError) Error() string {
+	return e.Message
+}
+
+// Client is a typed API on top of the text protocol of the server.
+type Client struct {
+	sender Sender
+}
+
+func New(sender Sender) *Client {
+	return &Client{
+		sender: sender,
+	}
+}
+
+func (c *Client) Get(key string) (string, error) {
+	response, err := c.do("GET", key)
+	if err != nil {
+		return "", err
+	}
+	if response == emptyValue {
+		return "", ErrNotFound
+	}
+	return response, nil
+}
+
+func (c *Client) Set(key, value string) error {
+	_, err := c.do("SET", key, value)
+	return err
+}
+
+func (c *Client) Del(key string) error {
+	_, err := c.do("DEL", key)
+	return err
+}
+
+// MGet returns values in the order of keys, nil for missing keys.
+func (c *Client) MGet(keys []string) ([]*string, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	response, err := c.do("MGET", keys...)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(response, "\n")
+	if len(lines) != len(keys) {
+		return nil, fmt.Errorf("expected %d values, got %d", len(keys), len(lines))
+	}
+
+	values := make([]*string, len(lines))
+	for i, line := range lines {
+		if line != emptyValue {
+			values[i] = &lines[i]
+		}
+	}
+	return values, nil
+}
+
+// MSet atomically sets all pairs.
+func (c *Client) MSet(pairs map[string]string) error {
+	if len(pairs) == 0 {
+		return nil
+	}
+
+	args := make([]string, 0, len(pairs)*2)
+	for key, value := range pairs {
+		args = append(args, key, value)
+	}
+
+	_, err := c.do("MSET", args...)
+	return err
+}
+
+// MDel removes keys and returns how many of them existed.
+func (c *Client) MDel(keys []string) (int, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	return c.doInt("MDEL", keys...)
+}
+
+// Exists returns how many of keys are present.
+func (c *Client) Exists(keys ...string) (int, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	return c.doInt("EXISTS", keys...)
+}
+
+// Keys returns all keys of the database.
+func (c *Client) Keys() ([]string, error) {
+	return c.KeysMatch("*")
+}
+
+// KeysMatch returns keys matching the glob pattern.
+func (c *Client) KeysMatch(pattern string) ([]string, error) {
+	response, err := c.do("KEYS", pattern)
+	if err != nil {
+		return nil, err
+	}
+	return splitLines(response), nil
+}
+
+// Incr adds one to the integer at key and returns the new value.
+func (c *Client) Incr(key string) (int64, error) {
+	return c.doInt64("INCR", key)
+}
+
+// Decr subtracts one from the integer at key and returns the new value.
+func (c *Client) Decr(key string) (int64, error) {
+	return c.doInt64("DECR", key)
+}
+
+// IncrBy adds delta to the integer at key and returns the new value.
+func (c *Client) IncrBy(key string, delta int64) (int64, error) {
+	return c.doInt64("INCRBY", key, strconv.FormatInt(delta, 10))
+}
+
+// Append adds suffix to the value at key and returns the new length.
+func (c *Client) Append(key, suffix string) (int, error) {
+	return c.doInt("APPEND", key, suffix)
+}
+
+// GetSet stores value and returns the previous one, ErrNotFound if there was
+// none.
+func (c *Client) GetSet(key, value string) (string, error) {
+	return c.doValue("GETSET", key, value)
+}
+
+// SetNX stores value only if key does not exist and reports whether it did.
+func (c *Client) SetNX(key, value string) (bool, error) {
+	n, err := c.doInt("SETNX", key, value)
+	return n == 1, err
+}
+
+// CAS replaces the value at key only if it equals expected and reports
+// whether it did.
+func (c *Client) CAS(key, expected, value string) (bool, error) {
+	n, err := c.doInt("CAS", key, expected, value)
+	return n == 1, err
+}
+
+func (c *Client) do(command string, args ...string) (string, error) {
+	for _, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\r\n") {
+			return "", fmt.Errorf("%w: %q", ErrInvalidArgument, arg)
+		}
+	}
+
+	request := command
+	if len(args) != 0 {
+		request += " " + strings.Join(args, " ")
+	}
+
+	response, err := c.sender.Send([]byte(request))
+	if err != nil {
+		return "", err
+	}
+
+	text := string(response)
+	if strings.HasPrefix(text, errorPrefix) {
+		return "", &ServerError{Message: strings.TrimPrefix(text, errorPrefix)}
+	}
+	return text, nil
+}
+
+func (c *Client) doInt(command string, args ...string) (int, error) {
+	response, err := c.do(command, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	value, err := strconv.Atoi(response)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected response %q: %w", response, err)
+	}
+	return value, nil
+}
+
+func (c *Client) doInt64(command string, args ...string) (int64, error) {
+	response, err := c.do(command, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	value, err := strconv.ParseInt(response, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected response %q: %w", response, err)
+	}
+	return value, nil
+}
+
+func splitLines(response string) []string {
+	if response == emptyValue {
+		return nil
+	}
+	return strings.Split(response, "\n")
+}
@@ -0,0 +1,149 @@
+package client
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Type returns the type of the value at key, "none" if it is missing.
+func (c *Client) Type(key string) (string, error) {
+	return c.do("TYPE", key)
+}
+
+// LPush prepends values to a list and returns its length.
+func (c *Client) LPush(key string, values ...string) (int, error) {
+	return c.doInt("LPUSH", append([]string{key}, values...)...)
+}
+
+// RPush appends values to a list and returns its length.
+func (c *Client) RPush(key string, values ...string) (int, error) {
+	return c.doInt("RPUSH", append([]string{key}, values...)...)
+}
+
+func (c *Client) LPop(key string) (string, error) {
+	return c.doValue("LPOP", key)
+}
+
+func (c *Client) RPop(key string) (string, error) {
+	return c.doValue("RPOP", key)
+}
+
+// BLPop pops the first item of the first non-empty list among keys, waiting
+// up to timeout for a push if all of them are empty; zero waits forever. It
+// returns ErrNotFound after the timeout. The timeout should be shorter than
+// the idle timeout of the connection, or the request fails on the client.
+func (c *Client) BLPop(timeout time.Duration, keys ...string) (key, value string, err error) {
+	return c.blockingPop("BLPOP", timeout, keys)
+}
+
+func (c *Client) BRPop(timeout time.Duration, keys ...string) (key, value string, err error) {
+	return c.blockingPop("BRPOP", timeout, keys)
+}
+
+func (c *Client) blockingPop(command string, timeout time.Duration, keys []string) (string, string, error) {
+	if len(keys) == 0 {
+		return "", "", fmt.Errorf("%w: no keys", ErrInvalidArgument)
+	}
+
+	args := append(slices.Clone(keys), strconv.FormatFloat(timeout.Seconds(), 'f', -1, 64))
+	response, err := c.do(command, args...)
+	if err != nil {
+		return "", "", err
+	}
+	if response == nilValue {
+		return "", "", ErrNotFound
+	}
+
+	key, value, ok := strings.Cut(response, " ")
+	if !ok {
+		return "", "", fmt.Errorf("unexpected response %q", response)
+	}
+	return key, value, nil
+}
+
+// LRange returns list items from start to stop inclusive, negative indexes
+// count from the end of the list.
+func (c *Client) LRange(key string, start, stop int) ([]string, error) {
+	response, err := c.do("LRANGE", key, strconv.Itoa(start), strconv.Itoa(stop))
+	if err != nil {
+		return nil, err
+	}
+	return splitLines(response), nil
+}
+
+// HSet sets fields of a hash and returns how many of them were added.
+func (c *Client) HSet(key string, pairs map[string]string) (int, error) {
+	if len(pairs) == 0 {
+		return 0, nil
+	}
+
+	args := make([]string, 0, len(pairs)*2+1)
+	args = append(args, key)
+	for field, value := range pairs {
+		args = append(args, field, value)
+	}
+	return c.doInt("HSET", args...)
+}
+
+func (c *Client) HGet(key, field string) (string, error) {
+	return c.doValue("HGET", key, field)
+}
+
+// HDel removes fields of a hash and returns how many of them existed.
+func (c *Client) HDel(key string, fields ...string) (int, error) {
+	return c.doInt("HDEL", append([]string{key}, fields...)...)
+}
+
+func (c *Client) HGetAll(key string) (map[string]string, error) {
+	response, err := c.do("HGETALL", key)
+	if err != nil {
+		return nil, err
+	}
+
+	pairs := make(map[string]string)
+	for _, line := range splitLines(response) {
+		field, value, _ := strings.Cut(line, " ")
+		pairs[field] = value
+	}
+	return pairs, nil
+}
+
+// SAdd adds members to a set and returns how many of them are new.
+func (c *Client) SAdd(key string, members ...string) (int, error) {
+	return c.doInt("SADD", append([]string{key}, members...)...)
+}
+
+// SRem removes members from a set and returns how many of them existed.
+func (c *Client) SRem(key string, members ...string) (int, error) {
+	return c.doInt("SREM", append([]string{key}, members...)...)
+}
+
+// SMembers returns the members of a set in sorted order.
+func (c *Client) SMembers(key string) ([]string, error) {
+	response, err := c.do("SMEMBERS", key)
+	if err != nil {
+		return nil, err
+	}
+	return splitLines(response), nil
+}
+
+func (c *Client) SIsMember(key, member string) (bool, error) {
+	n, err := c.doInt("SISMEMBER", key, member)
+	return n == 1, err
+}
+
+// doValue runs a command answering with a single value, ErrNotFound if
+// there is none.
+func (c *Client) doValue(command string, args ...string) (string, error) {
+	response, err := c.do(command, args...)
+	if err != nil {
+		return "", err
+	}
+	if response == emptyValue {
+		return "", ErrNotFound
+	}
+	return response, nil
+}
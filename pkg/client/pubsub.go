@@ -0,0 +1,237 @@
+package client
+
+import (
+	"bufio"
+	"concurrency_hw1/pkg/network"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Publish sends message to channel and returns how many subscribers
+// received it.
+func (c *Client) Publish(channel, message string) (int, error) {
+	return c.doInt("PUBLISH", channel, message)
+}
+
+// Message is a message received by a Subscription. Pattern is set for
+// messages matched by PSubscribe.
+type Message struct {
+	Pattern string
+	Channel string
+	Payload string
+}
+
+const keyspacePrefix = "__keyspace__:"
+
+// KeyspaceEvent is a change of a key received by Subscription.Watch. LSN is
+// the WAL record that made it, Type is "set" or "del".
+type KeyspaceEvent struct {
+	LSN  uint64
+	Type string
+	Key  string
+}
+
+// KeyspaceEvent decodes a message received by Watch, ok is false for any
+// other message.
+func (m Message) KeyspaceEvent() (KeyspaceEvent, bool) {
+	key, ok := strings.CutPrefix(m.Channel, keyspacePrefix)
+	if !ok {
+		return KeyspaceEvent{}, false
+	}
+	lsn, kind, ok := strings.Cut(m.Payload, " ")
+	if !ok {
+		return KeyspaceEvent{}, false
+	}
+	number, err := strconv.ParseUint(lsn, 10, 64)
+	if err != nil {
+		return KeyspaceEvent{}, false
+	}
+	return KeyspaceEvent{LSN: number, Type: kind, Key: key}, true
+}
+
+// Subscription is a connection in push mode. It has a connection of its own
+// because pushed messages can arrive at any time and would be mixed up with
+// responses of a shared connection.
+//
+// Every subscription change waits for its confirmations, so the server never
+// reads two commands at once; messages arriving in the meantime are kept for
+// Receive. A Subscription must not be used from several goroutines.
+type Subscription struct {
+	connection net.Conn
+	reader     *bufio.Reader
+	pending    []Message
+	channels   map[string]struct{}
+	patterns   map[string]struct{}
+}
+
+func NewSubscription(address string) (*Subscription, error) {
+	connection, err := network.Dial(address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial: %w", err)
+	}
+
+	return &Subscription{
+		connection: connection,
+		reader:     bufio.NewReader(connection),
+		channels:   make(map[string]struct{}),
+		patterns:   make(map[string]struct{}),
+	}, nil
+}
+
+func (s *Subscription) Subscribe(channels ...string) error {
+	return s.subscribe("SUBSCRIBE", s.channels, channels)
+}
+
+// PSubscribe subscribes to channels matching glob patterns.
+func (s *Subscription) PSubscribe(patterns ...string) error {
+	return s.subscribe("PSUBSCRIBE", s.patterns, patterns)
+}
+
+// Unsubscribe removes channels, all of them if none are given.
+func (s *Subscription) Unsubscribe(channels ...string) error {
+	return s.unsubscribe("UNSUBSCRIBE", s.channels, channels)
+}
+
+func (s *Subscription) PUnsubscribe(patterns ...string) error {
+	return s.unsubscribe("PUNSUBSCRIBE", s.patterns, patterns)
+}
+
+// Watch subscribes to changes of keys matching the glob pattern, use
+// Message.KeyspaceEvent to decode them.
+func (s *Subscription) Watch(pattern string) error {
+	return s.watch(pattern, "")
+}
+
+// WatchSince is Watch that first receives the changes made after the given
+// LSN, e.g. the last one seen before a reconnect. It fails if the server no
+// longer keeps them.
+func (s *Subscription) WatchSince(pattern string, lsn uint64) error {
+	return s.watch(pattern, strconv.FormatUint(lsn, 10))
+}
+
+// Receive blocks until the next message arrives.
+func (s *Subscription) Receive() (Message, error) {
+	if len(s.pending) != 0 {
+		message := s.pending[0]
+		s.pending = s.pending[1:]
+		return message, nil
+	}
+
+	for {
+		message, confirmation, err := s.read()
+		if err != nil {
+			return Message{}, err
+		}
+		if !confirmation {
+			return message, nil
+		}
+	}
+}
+
+func (s *Subscription) Close() error {
+	return s.connection.Close()
+}
+
+func (s *Subscription) subscribe(command string, own map[string]struct{}, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	if err := s.send(command, names, len(names)); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		own[name] = struct{}{}
+	}
+	return nil
+}
+
+func (s *Subscription) watch(pattern, since string) error {
+	args := []string{pattern}
+	if since != "" {
+		args = append(args, "SINCE", since)
+	}
+	if err := s.send("WATCH", args, 1); err != nil {
+		return err
+	}
+
+	s.patterns[keyspacePrefix+pattern] = struct{}{}
+	return nil
+}
+
+func (s *Subscription) unsubscribe(command string, own map[string]struct{}, names []string) error {
+	// the server confirms every removed name, or once if there are none
+	expected := len(names)
+	if expected == 0 {
+		expected = max(len(own), 1)
+	}
+	if err := s.send(command, names, expected); err != nil {
+		return err
+	}
+
+	if len(names) == 0 {
+		clear(own)
+	}
+	for _, name := range names {
+		delete(own, name)
+	}
+	return nil
+}
+
+// send writes a command and waits for expected confirmations.
+func (s *Subscription) send(command string, args []string, expected int) error {
+	for _, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\r\n") {
+			return fmt.Errorf("%w: %q", ErrInvalidArgument, arg)
+		}
+	}
+
+	request := strings.Join(append([]string{command}, args...), " ")
+	if _, err := s.connection.Write([]byte(request)); err != nil {
+		return err
+	}
+
+	for expected > 0 {
+		message, confirmation, err := s.read()
+		if err != nil {
+			return err
+		}
+		if confirmation {
+			expected--
+		} else {
+			s.pending = append(s.pending, message)
+		}
+	}
+	return nil
+}
+
+// read returns the next message or reports a confirmation frame.
+func (s *Subscription) read() (Message, bool, error) {
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			return Message{}, false, err
+		}
+		line = strings.TrimSuffix(line, "\n")
+
+		if strings.HasPrefix(line, errorPrefix) {
+			return Message{}, false, &ServerError{Message: strings.TrimPrefix(line, errorPrefix)}
+		}
+
+		fields := strings.SplitN(line, " ", 4)
+		switch fields[0] {
+		case "message":
+			if len(fields) >= 3 {
+				return Message{Channel: fields[1], Payload: strings.Join(fields[2:], " ")}, false, nil
+			}
+		case "pmessage":
+			if len(fields) == 4 {
+				return Message{Pattern: fields[1], Channel: fields[2], Payload: fields[3]}, false, nil
+			}
+		case "subscribe", "psubscribe", "unsubscribe", "punsubscribe":
+			return Message{}, true, nil
+		}
+	}
+}
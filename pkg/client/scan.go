@@ -0,0 +1,90 @@
+package client
+
+import (
+	"strconv"
+	"strings"
+)
+
+const scanStartCursor = "0"
+
+// Scan returns one page of keys matching pattern and the cursor of the next
+// page. Start with cursor "0", the iteration is over when "0" is returned.
+func (c *Client) Scan(cursor, pattern string, count int) (string, []string, error) {
+	args := []string{cursor}
+	if pattern != "" {
+		args = append(args, "MATCH", pattern)
+	}
+	if count > 0 {
+		args = append(args, "COUNT", strconv.Itoa(count))
+	}
+
+	response, err := c.do("SCAN", args...)
+	if err != nil {
+		return "", nil, err
+	}
+
+	lines := strings.Split(response, "\n")
+	return lines[0], lines[1:], nil
+}
+
+// ScanIterator walks all pages of a SCAN:
+//
+//	it := c.NewScanIterator("user:*", 100)
+//	for it.Next() {
+//		fmt.Println(it.Key())
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type ScanIterator struct {
+	client  *Client
+	pattern string
+	count   int
+
+	cursor string
+	page   []string
+	key    string
+	done   bool
+	err    error
+}
+
+func (c *Client) NewScanIterator(pattern string, count int) *ScanIterator {
+	return &ScanIterator{
+		client:  c,
+		pattern: pattern,
+		count:   count,
+		cursor:  scanStartCursor,
+	}
+}
+
+// Next advances to the next key, fetching pages as needed. It returns false
+// when all keys were visited or an error occurred.
+func (it *ScanIterator) Next() bool {
+	for len(it.page) == 0 {
+		if it.done || it.err != nil {
+			return false
+		}
+
+		cursor, keys, err := it.client.Scan(it.cursor, it.pattern, it.count)
+		if err != nil {
+			it.err = err
+			return false
+		}
+
+		it.cursor = cursor
+		it.page = keys
+		it.done = cursor == scanStartCursor
+	}
+
+	it.key = it.page[0]
+	it.page = it.page[1:]
+	return true
+}
+
+func (it *ScanIterator) Key() string {
+	return it.key
+}
+
+func (it *ScanIterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,109 @@
+package client
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ScoredMember is a member of a sorted set with its score.
+type ScoredMember struct {
+	Member string
+	Score  float64
+}
+
+// ZAdd sets scores of members and returns how many of them are new.
+func (c *Client) ZAdd(key string, members ...ScoredMember) (int, error) {
+	if len(members) == 0 {
+		return 0, nil
+	}
+
+	args := make([]string, 0, len(members)*2+1)
+	args = append(args, key)
+	for _, m := range members {
+		args = append(args, strconv.FormatFloat(m.Score, 'g', -1, 64), m.Member)
+	}
+	return c.doInt("ZADD", args...)
+}
+
+// ZRem removes members and returns how many of them existed.
+func (c *Client) ZRem(key string, members ...string) (int, error) {
+	return c.doInt("ZREM", append([]string{key}, members...)...)
+}
+
+func (c *Client) ZScore(key, member string) (float64, error) {
+	response, err := c.doValue("ZSCORE", key, member)
+	if err != nil {
+		return 0, err
+	}
+	return parseScore(response)
+}
+
+// ZIncrBy adds increment to the score of a member and returns the new score.
+func (c *Client) ZIncrBy(key string, increment float64, member string) (float64, error) {
+	response, err := c.do("ZINCRBY", key, strconv.FormatFloat(increment, 'g', -1, 64), member)
+	if err != nil {
+		return 0, err
+	}
+	return parseScore(response)
+}
+
+// ZRank returns the 0-based position of a member ordered by score.
+func (c *Client) ZRank(key, member string) (int, error) {
+	response, err := c.doValue("ZRANK", key, member)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(response)
+}
+
+// ZRange returns members from start to stop rank inclusive, negative
+// indexes count from the highest score.
+func (c *Client) ZRange(key string, start, stop int) ([]ScoredMember, error) {
+	response, err := c.do("ZRANGE", key, strconv.Itoa(start), strconv.Itoa(stop), "WITHSCORES")
+	if err != nil {
+		return nil, err
+	}
+	return parseMembers(response)
+}
+
+// ZRangeByScore returns up to count members with min <= score <= max after
+// skipping offset of them, all of them if count <= 0. Bounds are passed as
+// is, so "(1" and "+inf" can be used.
+func (c *Client) ZRangeByScore(key, min, max string, offset, count int) ([]ScoredMember, error) {
+	args := []string{key, min, max, "WITHSCORES"}
+	if offset > 0 || count > 0 {
+		if count <= 0 {
+			count = -1
+		}
+		args = append(args, "LIMIT", strconv.Itoa(offset), strconv.Itoa(count))
+	}
+
+	response, err := c.do("ZRANGEBYSCORE", args...)
+	if err != nil {
+		return nil, err
+	}
+	return parseMembers(response)
+}
+
+func parseMembers(response string) ([]ScoredMember, error) {
+	lines := splitLines(response)
+	members := make([]ScoredMember, len(lines))
+	for i, line := range lines {
+		member, score, _ := strings.Cut(line, " ")
+		value, err := parseScore(score)
+		if err != nil {
+			return nil, err
+		}
+		members[i] = ScoredMember{Member: member, Score: value}
+	}
+	return members, nil
+}
+
+func parseScore(response string) (float64, error) {
+	score, err := strconv.ParseFloat(response, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected response %q: %w", response, err)
+	}
+	return score, nil
+}
@@ -0,0 +1,105 @@
+package common
+
+// MatchPattern reports whether text matches the glob pattern. Unlike
+// path.Match it has no special handling for '/', so "user:*" matches
+// every key with the "user:" prefix. '*' matches any sequence of
+// characters, '?' a single character, "[a-z]" or "[^abc]" a character
+// class and '\' escapes the next character.
+func MatchPattern(pattern, text string) bool {
+	p, t := 0, 0
+	// position of the last '*' in pattern and of text where it started matching
+	star, match := -1, 0
+
+	for t < len(text) {
+		if p < len(pattern) {
+			switch pattern[p] {
+			case '*':
+				star, match = p, t
+				p++
+				continue
+			case '?':
+				p++
+				t++
+				continue
+			case '[':
+				if end, ok := matchClass(pattern, p, text[t]); end > 0 {
+					if ok {
+						p = end
+						t++
+						continue
+					}
+				} else if text[t] == '[' {
+					// unterminated class is matched literally
+					p++
+					t++
+					continue
+				}
+			case '\\':
+				if p+1 < len(pattern) && pattern[p+1] == text[t] {
+					p += 2
+					t++
+					continue
+				}
+			default:
+				if pattern[p] == text[t] {
+					p++
+					t++
+					continue
+				}
+			}
+		}
+
+		if star < 0 {
+			return false
+		}
+
+		// let the last '*' consume one more character and retry
+		match++
+		p, t = star+1, match
+	}
+
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+
+	return p == len(pattern)
+}
+
+// matchClass matches c against the class starting at pattern[start] == '['.
+// It returns the index right after the class, or 0 if the class is not
+// terminated.
+func matchClass(pattern string, start int, c byte) (int, bool) {
+	i := start + 1
+	negate := false
+	if i < len(pattern) && (pattern[i] == '^' || pattern[i] == '!') {
+		negate = true
+		i++
+	}
+
+	matched := false
+	first := true
+	for i < len(pattern) && (pattern[i] != ']' || first) {
+		first = false
+
+		lo := pattern[i]
+		if lo == '\\' && i+1 < len(pattern) {
+			i++
+			lo = pattern[i]
+		}
+		hi := lo
+		if i+2 < len(pattern) && pattern[i+1] == '-' && pattern[i+2] != ']' {
+			hi = pattern[i+2]
+			i += 2
+		}
+		if lo <= c && c <= hi {
+			matched = true
+		}
+		i++
+	}
+
+	if i >= len(pattern) {
+		return 0, false
+	}
+
+	return i + 1, matched != negate
+}
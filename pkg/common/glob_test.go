@@ -0,0 +1,50 @@
+package common_test
+
+import (
+	"concurrency_hw1/pkg/common"
+	"testing"
+)
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		text    string
+		want    bool
+	}{
+		{pattern: "*", text: "anything", want: true},
+		{pattern: "user:*", text: "user:1/profile", want: true},
+		{pattern: "user:*", text: "users", want: false},
+		{pattern: "*:name", text: "user:1:name", want: true},
+		{pattern: "a*b*c", text: "axxbyyc", want: true},
+		{pattern: "a*b*c", text: "axxbyy", want: false},
+		{pattern: "h?llo", text: "hello", want: true},
+		{pattern: "h?llo", text: "hllo", want: false},
+		{pattern: "h[ae]llo", text: "hallo", want: true},
+		{pattern: "h[ae]llo", text: "hillo", want: false},
+		{pattern: "key[0-9]", text: "key7", want: true},
+		{pattern: "key[0-9]", text: "keyx", want: false},
+		{pattern: "h[^e]llo", text: "hallo", want: true},
+		{pattern: "h[^e]llo", text: "hello", want: false},
+		{pattern: "h[!e]llo", text: "hello", want: false},
+		{pattern: "[]]", text: "]", want: true},
+		{pattern: `[\]]`, text: "]", want: true},
+		{pattern: `a\*b`, text: "a*b", want: true},
+		{pattern: `a\*b`, text: "axb", want: false},
+		{pattern: `a\?`, text: "a?", want: true},
+		{pattern: `\[x]`, text: "[x]", want: true},
+		{pattern: "[abc", text: "[abc", want: true},
+		{pattern: "[abc", text: "a", want: false},
+		{pattern: "", text: "", want: true},
+		{pattern: "", text: "a", want: false},
+		{pattern: "*", text: "", want: true},
+		{pattern: "?", text: "", want: false},
+		{pattern: "[a]", text: "", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.text, func(t *testing.T) {
+			if got := common.MatchPattern(tt.pattern, tt.text); got != tt.want {
+				t.Errorf("MatchPattern(%q, %q) = %v, want %v", tt.pattern, tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package concurrency
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	defaultFailureThreshold  = 3
+	defaultOpenTimeout       = 5 * time.Second
+	defaultHalfOpenSuccesses = 1
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type BreakerState int
+
+const (
+	// BreakerClosed lets every call through
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rejects every call until the open timeout passes
+	BreakerOpen
+	// BreakerHalfOpen lets one probe call through at a time
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+type CircuitBreakerOption func(*CircuitBreaker)
+
+// WithFailureThreshold sets how many failures in a row open the breaker.
+func WithFailureThreshold(count int) CircuitBreakerOption {
+	return func(b *CircuitBreaker) {
+		b.failureThreshold = count
+	}
+}
+
+// WithOpenTimeout sets how long the breaker stays open before probing.
+func WithOpenTimeout(timeout time.Duration) CircuitBreakerOption {
+	return func(b *CircuitBreaker) {
+		b.openTimeout = timeout
+	}
+}
+
+// WithHalfOpenSuccesses sets how many probes in a row must succeed to close
+// the breaker.
+func WithHalfOpenSuccesses(count int) CircuitBreakerOption {
+	return func(b *CircuitBreaker) {
+		b.halfOpenSuccesses = count
+	}
+}
+
+// WithStateChangeHandler is called after every change of the state.
+func WithStateChangeHandler(handler func(from, to BreakerState)) CircuitBreakerOption {
+	return func(b *CircuitBreaker) {
+		b.onStateChange = handler
+	}
+}
+
+// CircuitBreaker stops calling something that keeps failing. It opens after
+// a number of failures in a row, rejects calls while it is open and then
+// lets probes through one at a time: it closes after enough of them succeed
+// and opens again on a failure.
+type CircuitBreaker struct {
+	failureThreshold  int
+	openTimeout       time.Duration
+	halfOpenSuccesses int
+	onStateChange     func(from, to BreakerState)
+
+	mu        sync.Mutex
+	state     BreakerState
+	failures  int
+	successes int
+	probing   bool
+	openedAt  time.Time
+}
+
+func NewCircuitBreaker(options ...CircuitBreakerOption) *CircuitBreaker {
+	b := &CircuitBreaker{
+		failureThreshold:  defaultFailureThreshold,
+		openTimeout:       defaultOpenTimeout,
+		halfOpenSuccesses: defaultHalfOpenSuccesses,
+	}
+	for _, option := range options {
+		option(b)
+	}
+	b.failureThreshold = max(b.failureThreshold, 1)
+	b.halfOpenSuccesses = max(b.halfOpenSuccesses, 1)
+	return b
+}
+
+// State returns the current state, an open breaker whose timeout has passed
+// is half-open.
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.openTimeout {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// Allow reports whether a call may be made, it fails with ErrCircuitOpen
+// otherwise. Every allowed call must be followed by Success or Failure.
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	from := b.state
+	err := b.allow()
+	to := b.state
+	b.mu.Unlock()
+
+	b.notify(from, to)
+	return err
+}
+
+func (b *CircuitBreaker) allow() error {
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.openTimeout {
+			return ErrCircuitOpen
+		}
+		b.state = BreakerHalfOpen
+		b.successes = 0
+		fallthrough
+	case BreakerHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// Success records a successful call.
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	from := b.state
+	switch b.state {
+	case BreakerClosed:
+		b.failures = 0
+	case BreakerHalfOpen:
+		b.probing = false
+		b.successes++
+		if b.successes >= b.halfOpenSuccesses {
+			b.state = BreakerClosed
+			b.failures = 0
+		}
+	}
+	to := b.state
+	b.mu.Unlock()
+
+	b.notify(from, to)
+}
+
+// Failure records a failed call.
+func (b *CircuitBreaker) Failure() {
+	b.mu.Lock()
+	from := b.state
+	switch b.state {
+	case BreakerClosed:
+		b.failures++
+		if b.failures >= b.failureThreshold {
+			b.open()
+		}
+	case BreakerHalfOpen:
+		b.probing = false
+		b.open()
+	}
+	to := b.state
+	b.mu.Unlock()
+
+	b.notify(from, to)
+}
+
+// Execute calls f if the breaker allows it and records the result, a panic
+// is a failure.
+func (b *CircuitBreaker) Execute(f func() error) (err error) {
+	if err := b.Allow(); err != nil {
+		return err
+	}
+
+	err = ErrPanicked
+	defer func() {
+		if err != nil {
+			b.Failure()
+		} else {
+			b.Success()
+		}
+	}()
+	err = f()
+	return err
+}
+
+func (b *CircuitBreaker) open() {
+	b.state = BreakerOpen
+	b.openedAt = time.Now()
+}
+
+func (b *CircuitBreaker) notify(from, to BreakerState) {
+	if from != to && b.onStateChange != nil {
+		b.onStateChange(from, to)
+	}
+}
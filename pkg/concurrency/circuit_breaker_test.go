@@ -0,0 +1,112 @@
+package concurrency_test
+
+import (
+	"concurrency_hw1/pkg/concurrency"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	fail := func() error { return errTest }
+	succeed := func() error { return nil }
+
+	tests := []struct {
+		name      string
+		calls     []func() error
+		wait      time.Duration
+		wantErr   error
+		wantState concurrency.BreakerState
+	}{
+		{
+			name:      "Stays closed below the threshold",
+			calls:     []func() error{fail, fail, succeed, fail, fail},
+			wantErr:   nil,
+			wantState: concurrency.BreakerClosed,
+		},
+		{
+			name:      "Opens at the threshold",
+			calls:     []func() error{fail, fail, fail},
+			wantErr:   concurrency.ErrCircuitOpen,
+			wantState: concurrency.BreakerOpen,
+		},
+		{
+			name:      "Half-open after the timeout",
+			calls:     []func() error{fail, fail, fail},
+			wait:      30 * time.Millisecond,
+			wantErr:   nil,
+			wantState: concurrency.BreakerClosed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := concurrency.NewCircuitBreaker(concurrency.WithFailureThreshold(3), concurrency.WithOpenTimeout(20*time.Millisecond))
+			for _, call := range tt.calls {
+				b.Execute(call)
+			}
+			time.Sleep(tt.wait)
+
+			if err := b.Execute(succeed); !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+			if got := b.State(); got != tt.wantState {
+				t.Errorf("got state %s, want %s", got, tt.wantState)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerProbes(t *testing.T) {
+	var changes []concurrency.BreakerState
+	b := concurrency.NewCircuitBreaker(
+		concurrency.WithFailureThreshold(1),
+		concurrency.WithOpenTimeout(10*time.Millisecond),
+		concurrency.WithHalfOpenSuccesses(2),
+		concurrency.WithStateChangeHandler(func(from, to concurrency.BreakerState) {
+			changes = append(changes, to)
+		}),
+	)
+
+	b.Execute(func() error { return errTest })
+	time.Sleep(20 * time.Millisecond)
+	if got := b.State(); got != concurrency.BreakerHalfOpen {
+		t.Fatalf("got state %s, want half-open", got)
+	}
+
+	// one probe at a time
+	if err := b.Allow(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Allow(); !errors.Is(err, concurrency.ErrCircuitOpen) {
+		t.Errorf("got error %v for a second probe, want ErrCircuitOpen", err)
+	}
+	b.Failure()
+	if got := b.State(); got != concurrency.BreakerOpen {
+		t.Fatalf("got state %s after a failed probe, want open", got)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	b.Execute(func() error { return nil })
+	if got := b.State(); got != concurrency.BreakerHalfOpen {
+		t.Errorf("got state %s after one probe, want half-open", got)
+	}
+	b.Execute(func() error { return nil })
+	if got := b.State(); got != concurrency.BreakerClosed {
+		t.Errorf("got state %s after two probes, want closed", got)
+	}
+
+	want := []concurrency.BreakerState{
+		concurrency.BreakerOpen, concurrency.BreakerHalfOpen, concurrency.BreakerOpen,
+		concurrency.BreakerHalfOpen, concurrency.BreakerClosed,
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("got state changes %v, want %v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("got state changes %v, want %v", changes, want)
+			break
+		}
+	}
+}
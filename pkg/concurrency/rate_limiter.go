@@ -0,0 +1,186 @@
+package concurrency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// TokenBucket lets through rate tokens per second on average and up to
+// burst tokens at once. A nil bucket is unlimited.
+type TokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket returns a full bucket, or nil if rate is not positive. A
+// burst below one token is raised to one.
+func NewTokenBucket(rate, burst float64) *TokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+
+	burst = max(burst, 1)
+	return &TokenBucket{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// Allow takes n tokens if the bucket has them. More than burst tokens are
+// never available at once, so such a request only needs a full bucket.
+func (b *TokenBucket) Allow(n float64) bool {
+	if b == nil {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(time.Now())
+	if b.tokens < min(n, b.burst) {
+		return false
+	}
+	b.tokens -= n
+	return true
+}
+
+// Reserve takes n tokens even if the bucket goes into debt and returns how
+// long the caller must wait before using them.
+func (b *TokenBucket) Reserve(n float64) time.Duration {
+	if b == nil {
+		return 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(time.Now())
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// Wait takes n tokens and blocks until they are available or ctx is done.
+// The tokens are not returned on cancellation.
+func (b *TokenBucket) Wait(ctx context.Context, n float64) error {
+	return sleep(ctx, b.Reserve(n))
+}
+
+// refund returns tokens taken by a request that was not let through.
+func (b *TokenBucket) refund(n float64) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = min(b.tokens+n, b.burst)
+}
+
+func (b *TokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	b.last = now
+	if elapsed > 0 {
+		b.tokens = min(b.tokens+elapsed*b.rate, b.burst)
+	}
+}
+
+// RateLimiter limits both the number of operations and the number of bytes
+// per second, with a burst of one second of each. A nil limiter or a zero
+// rate is unlimited.
+type RateLimiter struct {
+	ops   *TokenBucket
+	bytes *TokenBucket
+}
+
+func NewRateLimiter(opsPerSecond, bytesPerSecond float64) *RateLimiter {
+	if opsPerSecond <= 0 && bytesPerSecond <= 0 {
+		return nil
+	}
+
+	return &RateLimiter{
+		ops:   NewTokenBucket(opsPerSecond, opsPerSecond),
+		bytes: NewTokenBucket(bytesPerSecond, bytesPerSecond),
+	}
+}
+
+// Allow lets an operation of size bytes through if both limits have room
+// for it, nothing is taken otherwise.
+func (l *RateLimiter) Allow(size int) bool {
+	if l == nil {
+		return true
+	}
+
+	if !l.ops.Allow(1) {
+		return false
+	}
+	if !l.bytes.Allow(float64(size)) {
+		l.ops.refund(1)
+		return false
+	}
+	return true
+}
+
+// Reserve takes an operation of size bytes and returns how long to wait
+// before running it.
+func (l *RateLimiter) Reserve(size int) time.Duration {
+	if l == nil {
+		return 0
+	}
+	return max(l.ops.Reserve(1), l.bytes.Reserve(float64(size)))
+}
+
+func (l *RateLimiter) refund(size int) {
+	if l == nil {
+		return
+	}
+	l.ops.refund(1)
+	l.bytes.refund(float64(size))
+}
+
+// AllowAll lets an operation through only if every limiter allows it, e.g.
+// the limiter of a connection and the one of its client.
+func AllowAll(size int, limiters ...*RateLimiter) bool {
+	for i, limiter := range limiters {
+		if !limiter.Allow(size) {
+			for _, allowed := range limiters[:i] {
+				allowed.refund(size)
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// WaitAll takes an operation from every limiter and blocks until all of
+// them have room for it or ctx is done.
+func WaitAll(ctx context.Context, size int, limiters ...*RateLimiter) error {
+	var delay time.Duration
+	for _, limiter := range limiters {
+		delay = max(delay, limiter.Reserve(size))
+	}
+	return sleep(ctx, delay)
+}
+
+func sleep(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,99 @@
+package concurrency_test
+
+import (
+	"concurrency_hw1/pkg/concurrency"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	tests := []struct {
+		name    string
+		ops     float64
+		bytes   float64
+		size    int
+		allowed int
+	}{
+		{
+			name:    "Unlimited",
+			size:    100,
+			allowed: 10,
+		},
+		{
+			name:    "Operations burst",
+			ops:     3,
+			size:    100,
+			allowed: 3,
+		},
+		{
+			name:    "Bytes burst",
+			bytes:   250,
+			size:    100,
+			allowed: 2,
+		},
+		{
+			name:    "Request larger than the burst needs a full bucket",
+			bytes:   50,
+			size:    100,
+			allowed: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := concurrency.NewRateLimiter(tt.ops, tt.bytes)
+			allowed := 0
+			for i := 0; i < 10; i++ {
+				if limiter.Allow(tt.size) {
+					allowed++
+				}
+			}
+			if allowed != tt.allowed {
+				t.Errorf("got %d allowed requests, want %d", allowed, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestAllowAllTakesNothingFromRejected(t *testing.T) {
+	connection := concurrency.NewRateLimiter(10, 0)
+	client := concurrency.NewRateLimiter(1, 0)
+
+	if !concurrency.AllowAll(1, connection, client) {
+		t.Fatal("first request is rejected")
+	}
+	for i := 0; i < 5; i++ {
+		if concurrency.AllowAll(1, connection, client) {
+			t.Fatal("request over the client limit is allowed")
+		}
+	}
+	// the rejected requests must not have used the connection limit
+	for i := 0; i < 9; i++ {
+		if !connection.Allow(1) {
+			t.Fatalf("connection limit is used by rejected requests, %d left", i)
+		}
+	}
+}
+
+func TestWaitAllDelays(t *testing.T) {
+	limiter := concurrency.NewRateLimiter(100, 0)
+	for limiter.Allow(1) {
+	}
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := concurrency.WaitAll(context.Background(), 1, limiter); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("5 requests at 100 ops/s took %v, want at least 40ms", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := concurrency.WaitAll(ctx, 1, limiter); err == nil {
+		t.Error("WaitAll does not stop on a cancelled context")
+	}
+}
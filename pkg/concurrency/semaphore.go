@@ -1,37 +1,245 @@
 package concurrency
 
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrWeightTooLarge = errors.New("weight is larger than the semaphore")
+
+// Semaphore is a weighted semaphore: Acquire takes n units out of size and
+// waits while they are not available. Waiters are served in the order they
+// came, so a large request is not starved by small ones.
+//
+// A nil Semaphore is unlimited, it never blocks. The zero Semaphore, or one
+// resized to 0, is unlimited as well but still counts the units in use.
 type Semaphore struct {
-	semaphore chan struct{}
+	mu      sync.Mutex
+	size    int64
+	current int64
+	waiters list.List
+	stats   SemaphoreStats
+}
+
+// SemaphoreStats tells how often and how long Acquire had to wait.
+type SemaphoreStats struct {
+	// Acquired is the number of successful acquisitions, Waited is how many
+	// of them had to wait
+	Acquired uint64
+	Waited   uint64
+	// Cancelled is the number of acquisitions given up because the context
+	// was done
+	Cancelled uint64
+	TotalWait time.Duration
+	MaxWait   time.Duration
+	// InUse, Waiting and Size are the current state
+	InUse   int64
+	Waiting int
+	Size    int64
+}
+
+type semaphoreWaiter struct {
+	n     int64
+	ready chan struct{}
+	// err is set before ready is closed if the waiter gets no units
+	err error
 }
 
-func NewSemaphore(concurrency int) Semaphore {
-	return Semaphore{
-		semaphore: make(chan struct{}, concurrency),
+// NewSemaphore returns a semaphore of size units, nil if size is not
+// positive.
+func NewSemaphore(size int64) *Semaphore {
+	if size <= 0 {
+		return nil
+	}
+
+	return &Semaphore{
+		size: size,
 	}
 }
 
-func (s *Semaphore) Acquire() {
-	if s == nil || s.semaphore == nil {
-		return
+// Size returns the number of units, 0 for an unlimited semaphore.
+func (s *Semaphore) Size() int64 {
+	if s == nil {
+		return 0
 	}
 
-	s.semaphore <- struct{}{}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
 }
 
-func (s *Semaphore) Release() {
-	if s == nil || s.semaphore == nil {
-		return
+// Resize changes the number of units, 0 makes the semaphore unlimited. The
+// units in use are kept, after shrinking below them nothing is acquired
+// until enough are released. Waiters for more units than the new size fail
+// with ErrWeightTooLarge, they would block everyone behind them forever.
+func (s *Semaphore) Resize(size int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.size = max(size, 0)
+	if s.size > 0 {
+		for element := s.waiters.Front(); element != nil; {
+			next := element.Next()
+			if waiter := element.Value.(*semaphoreWaiter); waiter.n > s.size {
+				waiter.err = ErrWeightTooLarge
+				s.waiters.Remove(element)
+				close(waiter.ready)
+			}
+			element = next
+		}
+	}
+	s.notifyWaiters()
+}
+
+// Acquire takes n units, waiting until they are available or ctx is done.
+// It fails with ErrWeightTooLarge if n exceeds the size.
+func (s *Semaphore) Acquire(ctx context.Context, n int64) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	if s.size > 0 && n > s.size {
+		s.mu.Unlock()
+		return ErrWeightTooLarge
+	}
+	if s.waiters.Len() == 0 && s.fits(n) {
+		s.current += n
+		s.stats.Acquired++
+		s.mu.Unlock()
+		return nil
+	}
+
+	waiter := &semaphoreWaiter{n: n, ready: make(chan struct{})}
+	element := s.waiters.PushBack(waiter)
+	s.mu.Unlock()
+
+	start := time.Now()
+	select {
+	case <-waiter.ready:
+		if waiter.err != nil {
+			return waiter.err
+		}
+		s.recordWait(time.Since(start))
+		return nil
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-waiter.ready:
+		if waiter.err != nil {
+			return waiter.err
+		}
+		// the units were handed over at the same time, give them back
+		s.current -= n
+		s.notifyWaiters()
+	default:
+		isFront := s.waiters.Front() == element
+		s.waiters.Remove(element)
+		// the waiters behind the first one may fit now
+		if isFront {
+			s.notifyWaiters()
+		}
+	}
+	s.stats.Cancelled++
+	return ctx.Err()
+}
+
+// TryAcquire takes n units if they are available right now and nobody is
+// waiting for them.
+func (s *Semaphore) TryAcquire(n int64) bool {
+	if s == nil {
+		return true
 	}
 
-	<-s.semaphore
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.waiters.Len() != 0 || !s.fits(n) {
+		return false
+	}
+	s.current += n
+	s.stats.Acquired++
+	return true
 }
 
-func (s *Semaphore) WithAcquire(f func()) {
-	if s == nil || s.semaphore == nil {
+// Release returns n units taken by Acquire or TryAcquire.
+func (s *Semaphore) Release(n int64) {
+	if s == nil {
 		return
 	}
 
-	s.Acquire()
-	defer s.Release()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.current -= n
+	if s.current < 0 {
+		panic("concurrency: semaphore released more than acquired")
+	}
+	s.notifyWaiters()
+}
+
+// WithAcquire runs f holding n units. f runs without limits on a nil
+// semaphore and does not run at all if the units cannot be acquired.
+func (s *Semaphore) WithAcquire(ctx context.Context, n int64, f func()) error {
+	if err := s.Acquire(ctx, n); err != nil {
+		return err
+	}
+	defer s.Release(n)
+
 	f()
+	return nil
+}
+
+func (s *Semaphore) Stats() SemaphoreStats {
+	if s == nil {
+		return SemaphoreStats{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := s.stats
+	stats.InUse = s.current
+	stats.Waiting = s.waiters.Len()
+	stats.Size = s.size
+	return stats
+}
+
+// notifyWaiters hands units to the waiters in order and stops at the first
+// one that does not fit, the caller holds mu.
+func (s *Semaphore) notifyWaiters() {
+	for {
+		front := s.waiters.Front()
+		if front == nil {
+			return
+		}
+
+		waiter := front.Value.(*semaphoreWaiter)
+		if !s.fits(waiter.n) {
+			return
+		}
+		s.current += waiter.n
+		s.waiters.Remove(front)
+		close(waiter.ready)
+	}
+}
+
+// fits reports whether n more units are available, the caller holds mu.
+func (s *Semaphore) fits(n int64) bool {
+	return s.size == 0 || s.size-s.current >= n
+}
+
+func (s *Semaphore) recordWait(wait time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stats.Acquired++
+	s.stats.Waited++
+	s.stats.TotalWait += wait
+	s.stats.MaxWait = max(s.stats.MaxWait, wait)
 }
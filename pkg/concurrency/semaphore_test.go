@@ -0,0 +1,186 @@
+package concurrency_test
+
+import (
+	"concurrency_hw1/pkg/concurrency"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSemaphore(t *testing.T) {
+	tests := []struct {
+		name   string
+		size   int64
+		action func(s *concurrency.Semaphore) bool
+		want   bool
+	}{
+		{
+			name:   "TryAcquire within the size",
+			size:   3,
+			action: func(s *concurrency.Semaphore) bool { return s.TryAcquire(2) && s.TryAcquire(1) },
+			want:   true,
+		},
+		{
+			name:   "TryAcquire over the size",
+			size:   3,
+			action: func(s *concurrency.Semaphore) bool { return s.TryAcquire(2) && s.TryAcquire(2) },
+			want:   false,
+		},
+		{
+			name: "Release makes room",
+			size: 3,
+			action: func(s *concurrency.Semaphore) bool {
+				s.TryAcquire(3)
+				s.Release(2)
+				return s.TryAcquire(2)
+			},
+			want: true,
+		},
+		{
+			name: "Acquire larger than the size",
+			size: 3,
+			action: func(s *concurrency.Semaphore) bool {
+				return errors.Is(s.Acquire(context.Background(), 4), concurrency.ErrWeightTooLarge)
+			},
+			want: true,
+		},
+		{
+			name: "Resize makes room",
+			size: 2,
+			action: func(s *concurrency.Semaphore) bool {
+				s.TryAcquire(2)
+				s.Resize(3)
+				return s.TryAcquire(1)
+			},
+			want: true,
+		},
+		{
+			name: "Shrinking keeps the units in use",
+			size: 3,
+			action: func(s *concurrency.Semaphore) bool {
+				s.TryAcquire(3)
+				s.Resize(2)
+				s.Release(1)
+				return s.TryAcquire(1)
+			},
+			want: false,
+		},
+		{
+			name: "Resize to zero is unlimited",
+			size: 1,
+			action: func(s *concurrency.Semaphore) bool {
+				s.Resize(0)
+				return s.TryAcquire(100) && s.Stats().InUse == 100
+			},
+			want: true,
+		},
+		{
+			name: "Nil semaphore runs WithAcquire",
+			size: 0,
+			action: func(s *concurrency.Semaphore) bool {
+				ran := false
+				err := s.WithAcquire(context.Background(), 10, func() { ran = true })
+				return ran && err == nil
+			},
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.action(concurrency.NewSemaphore(tt.size)); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSemaphoreAcquireIsCancelled(t *testing.T) {
+	s := concurrency.NewSemaphore(1)
+	s.TryAcquire(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.Acquire(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	s.Release(1)
+	if !s.TryAcquire(1) {
+		t.Error("units of a cancelled waiter are lost")
+	}
+	if stats := s.Stats(); stats.Cancelled != 1 || stats.Waiting != 0 {
+		t.Errorf("got %+v, want 1 cancelled and no waiting", stats)
+	}
+}
+
+func TestSemaphoreIsFair(t *testing.T) {
+	s := concurrency.NewSemaphore(4)
+	s.TryAcquire(4)
+
+	// a large waiter comes first, the small ones must not overtake it
+	var mu sync.Mutex
+	var order []int64
+	var wg sync.WaitGroup
+	for i, n := range []int64{4, 1, 1} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.Acquire(context.Background(), n); err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			order = append(order, n)
+			mu.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			s.Release(n)
+		}()
+		// queue the waiters in a known order
+		for s.Stats().Waiting != i+1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	if s.TryAcquire(1) {
+		t.Error("TryAcquire overtakes waiters")
+	}
+
+	s.Release(4)
+	wg.Wait()
+	if len(order) != 3 || order[0] != 4 {
+		t.Errorf("got order %v, want the large waiter first", order)
+	}
+	if stats := s.Stats(); stats.Waited != 3 || stats.MaxWait == 0 {
+		t.Errorf("got %+v, want 3 waits", stats)
+	}
+}
+
+func TestSemaphoreShrinkFailsLargeWaiters(t *testing.T) {
+	s := concurrency.NewSemaphore(4)
+	s.TryAcquire(2)
+
+	large := make(chan error, 1)
+	go func() { large <- s.Acquire(context.Background(), 4) }()
+	for s.Stats().Waiting != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	small := make(chan error, 1)
+	go func() { small <- s.Acquire(context.Background(), 1) }()
+	for s.Stats().Waiting != 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// the large waiter never fits into 3 units and must not block the small one
+	s.Resize(3)
+	if err := <-large; !errors.Is(err, concurrency.ErrWeightTooLarge) {
+		t.Errorf("large waiter: got %v, want %v", err, concurrency.ErrWeightTooLarge)
+	}
+	if err := <-small; err != nil {
+		t.Errorf("small waiter: got %v", err)
+	}
+	if stats := s.Stats(); stats.InUse != 3 || stats.Waiting != 0 {
+		t.Errorf("got %+v, want 3 in use and no waiting", stats)
+	}
+}
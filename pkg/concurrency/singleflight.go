@@ -0,0 +1,52 @@
+package concurrency
+
+import (
+	"errors"
+	"sync"
+)
+
+var ErrCallPanicked = errors.New("singleflight call panicked")
+
+// Group coalesces calls with the same key: while a call is running, the
+// others for its key wait for it and get its result instead of running
+// their own. The zero Group is ready to use.
+type Group[T any] struct {
+	mu    sync.Mutex
+	calls map[string]*call[T]
+}
+
+type call[T any] struct {
+	done  chan struct{}
+	value T
+	err   error
+}
+
+// Do runs fn once for all the concurrent callers of key. shared tells if
+// the caller got the result of a call made by another one, such a value
+// must not be modified. If fn panics, the panic goes to the caller that
+// ran it and the others get ErrCallPanicked.
+func (g *Group[T]) Do(key string, fn func() (T, error)) (value T, err error, shared bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call[T])
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-c.done
+		return c.value, c.err, true
+	}
+
+	c := &call[T]{done: make(chan struct{}), err: ErrCallPanicked}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(c.done)
+	}()
+
+	c.value, c.err = fn()
+	return c.value, c.err, false
+}
@@ -0,0 +1,168 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+var ErrPanicked = errors.New("function panicked")
+
+type result[T any] struct {
+	value T
+	err   error
+}
+
+// RunWithTimeout runs f and waits until it returns, timeout passes or ctx
+// is done, a zero timeout leaves only ctx. f gets a context that is
+// cancelled when the wait is over, but if it does not stop, it keeps
+// running in the background and its result is dropped. A panic of f is
+// returned as ErrPanicked.
+func RunWithTimeout[T any](ctx context.Context, timeout time.Duration, f func(context.Context) (T, error)) (T, error) {
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	results := make(chan result[T], 1)
+	go run(ctx, f, results)
+
+	select {
+	case r := <-results:
+		return r.value, r.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// Hedge runs f and, while no call has succeeded, starts another one every
+// delay up to attempts calls at once. The first success wins and the other
+// calls are cancelled. If every call fails, the last error is returned.
+func Hedge[T any](ctx context.Context, delay time.Duration, attempts int, f func(context.Context) (T, error)) (T, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	attempts = max(attempts, 1)
+	results := make(chan result[T], attempts)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	var zero T
+	var err error
+	started, failed := 0, 0
+	for {
+		select {
+		case <-timer.C:
+			go run(ctx, f, results)
+			started++
+			if started < attempts {
+				timer.Reset(delay)
+			}
+		case r := <-results:
+			if r.err == nil {
+				return r.value, nil
+			}
+			err = r.err
+			failed++
+			if failed == attempts {
+				return zero, err
+			}
+			// do not wait for the delay when nothing is running
+			if failed == started {
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(0)
+			}
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		}
+	}
+}
+
+// Backoff is the delay between retries: Initial, multiplied by Multiplier
+// after every retry up to Max. Jitter is the fraction of the delay it may
+// randomly be shortened by, so clients do not retry in lockstep.
+type Backoff struct {
+	Attempts   int
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+	Jitter     float64
+}
+
+// Delay returns the delay after the given failed attempt, counted from 0.
+func (b Backoff) Delay(attempt int) time.Duration {
+	delay := float64(b.Initial)
+	for i := 0; i < attempt; i++ {
+		delay *= max(b.Multiplier, 1)
+		if b.Max > 0 && delay >= float64(b.Max) {
+			break
+		}
+	}
+	if b.Max > 0 {
+		delay = min(delay, float64(b.Max))
+	}
+	if b.Jitter > 0 {
+		delay -= delay * min(b.Jitter, 1) * rand.Float64()
+	}
+	return time.Duration(delay)
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks an error that Retry must not retry.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+// Retry calls f until it succeeds, fails with a Permanent error or the
+// attempts of backoff are used up, at least one call is made. The last
+// error is returned, unwrapped from Permanent.
+func Retry[T any](ctx context.Context, backoff Backoff, f func(context.Context) (T, error)) (T, error) {
+	var zero T
+	for attempt := 0; ; attempt++ {
+		value, err := f(ctx)
+		if err == nil {
+			return value, nil
+		}
+		var permanent permanentError
+		if errors.As(err, &permanent) {
+			return zero, permanent.err
+		}
+		if attempt+1 >= backoff.Attempts {
+			return zero, err
+		}
+		if sleepErr := sleep(ctx, backoff.Delay(attempt)); sleepErr != nil {
+			return zero, err
+		}
+	}
+}
+
+func run[T any](ctx context.Context, f func(context.Context) (T, error), results chan<- result[T]) {
+	defer func() {
+		if v := recover(); v != nil {
+			results <- result[T]{err: fmt.Errorf("%w: %v", ErrPanicked, v)}
+		}
+	}()
+
+	value, err := f(ctx)
+	results <- result[T]{value: value, err: err}
+}
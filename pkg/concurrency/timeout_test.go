@@ -0,0 +1,151 @@
+package concurrency_test
+
+import (
+	"concurrency_hw1/pkg/concurrency"
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errTest = errors.New("test error")
+
+func TestRunWithTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		f       func(ctx context.Context) (int, error)
+		want    int
+		wantErr error
+	}{
+		{
+			name:    "Returns in time",
+			timeout: time.Second,
+			f:       func(ctx context.Context) (int, error) { return 1, nil },
+			want:    1,
+		},
+		{
+			name:    "Returns an error",
+			timeout: time.Second,
+			f:       func(ctx context.Context) (int, error) { return 0, errTest },
+			wantErr: errTest,
+		},
+		{
+			name:    "Ignores the context and times out",
+			timeout: 10 * time.Millisecond,
+			f: func(ctx context.Context) (int, error) {
+				time.Sleep(100 * time.Millisecond)
+				return 1, nil
+			},
+			wantErr: context.DeadlineExceeded,
+		},
+		{
+			name:    "Panics",
+			timeout: time.Second,
+			f:       func(ctx context.Context) (int, error) { panic("boom") },
+			wantErr: concurrency.ErrPanicked,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := concurrency.RunWithTimeout(context.Background(), tt.timeout, tt.f)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHedge(t *testing.T) {
+	var calls atomic.Int64
+	got, err := concurrency.Hedge(context.Background(), 10*time.Millisecond, 3, func(ctx context.Context) (int64, error) {
+		call := calls.Add(1)
+		if call == 1 {
+			// the first call is slow, a hedged one overtakes it
+			select {
+			case <-time.After(time.Second):
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			}
+		}
+		return call, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 2 {
+		t.Errorf("got the result of call %d, want 2", got)
+	}
+
+	calls.Store(0)
+	_, err = concurrency.Hedge(context.Background(), time.Minute, 3, func(ctx context.Context) (int64, error) {
+		calls.Add(1)
+		return 0, errTest
+	})
+	if !errors.Is(err, errTest) {
+		t.Errorf("got error %v, want %v", err, errTest)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("got %d calls after failures, want 3", got)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	backoff := concurrency.Backoff{Attempts: 3, Initial: time.Millisecond, Multiplier: 2}
+	tests := []struct {
+		name      string
+		errs      []error
+		wantErr   error
+		wantCalls int
+	}{
+		{
+			name:      "Succeeds after failures",
+			errs:      []error{errTest, errTest, nil},
+			wantCalls: 3,
+		},
+		{
+			name:      "Runs out of attempts",
+			errs:      []error{errTest, errTest, errTest, nil},
+			wantErr:   errTest,
+			wantCalls: 3,
+		},
+		{
+			name:      "Stops on a permanent error",
+			errs:      []error{concurrency.Permanent(errTest), nil},
+			wantErr:   errTest,
+			wantCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			_, err := concurrency.Retry(context.Background(), backoff, func(ctx context.Context) (struct{}, error) {
+				err := tt.errs[calls]
+				calls++
+				return struct{}{}, err
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("got %d calls, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	backoff := concurrency.Backoff{Initial: 10 * time.Millisecond, Max: 50 * time.Millisecond, Multiplier: 2}
+	want := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 50 * time.Millisecond, 50 * time.Millisecond}
+	for attempt, delay := range want {
+		if got := backoff.Delay(attempt); got != delay {
+			t.Errorf("got delay %s after attempt %d, want %s", got, attempt, delay)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	defaultQueueSize         = 1024
+	defaultWorkerIdleTimeout = time.Minute
+)
+
+var (
+	ErrPoolClosed = errors.New("worker pool is shut down")
+	ErrQueueFull  = errors.New("worker pool queue is full")
+)
+
+type WorkerPoolOption func(*WorkerPool)
+
+// WithMaxWorkers makes the pool elastic: when no worker is idle, a task
+// starts a new one up to count workers. Extra workers stop after being
+// idle for the idle timeout.
+func WithMaxWorkers(count int) WorkerPoolOption {
+	return func(pool *WorkerPool) {
+		pool.maxSize = count
+	}
+}
+
+// WithQueueSize sets how many tasks may wait for a worker, Submit blocks
+// and TrySubmit fails while the queue is full.
+func WithQueueSize(size int) WorkerPoolOption {
+	return func(pool *WorkerPool) {
+		pool.queueSize = size
+	}
+}
+
+func WithWorkerIdleTimeout(timeout time.Duration) WorkerPoolOption {
+	return func(pool *WorkerPool) {
+		pool.idleTimeout = timeout
+	}
+}
+
+// WithPanicHandler is called with the value of a panicking task, the worker
+// survives the panic either way.
+func WithPanicHandler(handler func(any)) WorkerPoolOption {
+	return func(pool *WorkerPool) {
+		pool.panicHandler = handler
+	}
+}
+
+// WorkerPool runs tasks on a bounded number of goroutines. It has size
+// workers all the time and, if it is elastic, up to max workers under load.
+type WorkerPool struct {
+	size         int
+	maxSize      int
+	queueSize    int
+	idleTimeout  time.Duration
+	panicHandler func(any)
+
+	tasks   chan func()
+	closing chan struct{}
+	wg      sync.WaitGroup
+
+	// submitMu is held by submits while they queue a task, so Shutdown
+	// does not stop the workers with a task on its way to the queue
+	submitMu sync.RWMutex
+	closed   bool
+
+	mu      sync.Mutex
+	workers int
+	idle    int
+}
+
+// WorkerPoolStats is the current state of a pool.
+type WorkerPoolStats struct {
+	Workers int
+	Idle    int
+	Queued  int
+}
+
+// NewWorkerPool starts size workers, at least one.
+func NewWorkerPool(size int, options ...WorkerPoolOption) *WorkerPool {
+	pool := &WorkerPool{
+		size:        max(size, 1),
+		queueSize:   defaultQueueSize,
+		idleTimeout: defaultWorkerIdleTimeout,
+		closing:     make(chan struct{}),
+	}
+	for _, option := range options {
+		option(pool)
+	}
+	pool.maxSize = max(pool.maxSize, pool.size)
+	pool.tasks = make(chan func(), max(pool.queueSize, 0))
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	for i := 0; i < pool.size; i++ {
+		pool.start(nil, true)
+	}
+	return pool
+}
+
+// Submit queues a task, waiting while the queue is full until ctx is done.
+func (p *WorkerPool) Submit(ctx context.Context, task func()) error {
+	p.submitMu.RLock()
+	defer p.submitMu.RUnlock()
+
+	if p.closed {
+		return ErrPoolClosed
+	}
+	if p.grow(task) {
+		return nil
+	}
+
+	select {
+	case p.tasks <- task:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TrySubmit queues a task or fails with ErrQueueFull without waiting.
+func (p *WorkerPool) TrySubmit(task func()) error {
+	p.submitMu.RLock()
+	defer p.submitMu.RUnlock()
+
+	if p.closed {
+		return ErrPoolClosed
+	}
+	if p.grow(task) {
+		return nil
+	}
+
+	select {
+	case p.tasks <- task:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Shutdown stops accepting tasks and waits until the queued and the
+// running ones are done or ctx is done.
+func (p *WorkerPool) Shutdown(ctx context.Context) error {
+	p.submitMu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.closing)
+	}
+	p.submitMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *WorkerPool) Stats() WorkerPoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return WorkerPoolStats{
+		Workers: p.workers,
+		Idle:    p.idle,
+		Queued:  len(p.tasks),
+	}
+}
+
+// grow runs the task on a new worker if the pool is elastic, no worker is
+// idle and there is room for one more.
+func (p *WorkerPool) grow(task func()) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.idle != 0 || p.workers >= p.maxSize {
+		return false
+	}
+	p.start(task, false)
+	return true
+}
+
+// start runs a worker, the caller holds mu. Permanent workers live until
+// Shutdown, the others also stop when they are idle for too long.
+func (p *WorkerPool) start(task func(), permanent bool) {
+	p.workers++
+	p.wg.Add(1)
+	go p.work(task, permanent)
+}
+
+func (p *WorkerPool) work(task func(), permanent bool) {
+	defer p.wg.Done()
+
+	if task != nil {
+		p.run(task)
+	}
+
+	var timer *time.Timer
+	var timeout <-chan time.Time
+	if !permanent {
+		timer = time.NewTimer(p.idleTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	for {
+		p.setIdle(1)
+		select {
+		case task := <-p.tasks:
+			p.setIdle(-1)
+			p.run(task)
+			if timer != nil {
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(p.idleTimeout)
+			}
+		case <-p.closing:
+			p.setIdle(-1)
+			p.drain()
+			p.exit()
+			return
+		case <-timeout:
+			p.setIdle(-1)
+			p.exit()
+			return
+		}
+	}
+}
+
+// drain runs the tasks left in the queue after Shutdown.
+func (p *WorkerPool) drain() {
+	for {
+		select {
+		case task := <-p.tasks:
+			p.run(task)
+		default:
+			return
+		}
+	}
+}
+
+func (p *WorkerPool) run(task func()) {
+	defer func() {
+		if v := recover(); v != nil && p.panicHandler != nil {
+			p.panicHandler(v)
+		}
+	}()
+
+	task()
+}
+
+func (p *WorkerPool) setIdle(delta int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.idle += delta
+}
+
+func (p *WorkerPool) exit() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.workers--
+}
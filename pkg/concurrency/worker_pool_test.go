@@ -0,0 +1,137 @@
+package concurrency_test
+
+import (
+	"concurrency_hw1/pkg/concurrency"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkerPoolBoundsConcurrency(t *testing.T) {
+	pool := concurrency.NewWorkerPool(3)
+	defer pool.Shutdown(context.Background())
+
+	var running, peak atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		err := pool.Submit(context.Background(), func() {
+			defer wg.Done()
+			current := running.Add(1)
+			for {
+				old := peak.Load()
+				if current <= old || peak.CompareAndSwap(old, current) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			running.Add(-1)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+
+	if got := peak.Load(); got > 3 {
+		t.Errorf("%d tasks ran at once, want at most 3", got)
+	}
+}
+
+func TestWorkerPoolElastic(t *testing.T) {
+	pool := concurrency.NewWorkerPool(1, concurrency.WithMaxWorkers(3), concurrency.WithWorkerIdleTimeout(20*time.Millisecond))
+	defer pool.Shutdown(context.Background())
+
+	release := make(chan struct{})
+	var started sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		started.Add(1)
+		if err := pool.Submit(context.Background(), func() {
+			started.Done()
+			<-release
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	started.Wait()
+
+	if got := pool.Stats().Workers; got != 3 {
+		t.Errorf("got %d workers under load, want 3", got)
+	}
+	close(release)
+
+	deadline := time.Now().Add(time.Second)
+	for pool.Stats().Workers != 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := pool.Stats().Workers; got != 1 {
+		t.Errorf("got %d workers after the idle timeout, want 1", got)
+	}
+}
+
+func TestWorkerPoolRecoversPanics(t *testing.T) {
+	panics := make(chan any, 1)
+	pool := concurrency.NewWorkerPool(1, concurrency.WithPanicHandler(func(v any) { panics <- v }))
+	defer pool.Shutdown(context.Background())
+
+	if err := pool.Submit(context.Background(), func() { panic("boom") }); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-panics; got != "boom" {
+		t.Errorf("got panic %v, want boom", got)
+	}
+
+	done := make(chan struct{})
+	if err := pool.Submit(context.Background(), func() { close(done) }); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("worker did not survive the panic")
+	}
+}
+
+func TestWorkerPoolQueue(t *testing.T) {
+	pool := concurrency.NewWorkerPool(1, concurrency.WithQueueSize(1))
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	var ran atomic.Int64
+	task := func() { ran.Add(1) }
+
+	if err := pool.Submit(context.Background(), func() {
+		close(started)
+		<-release
+	}); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	if err := pool.TrySubmit(task); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.TrySubmit(task); !errors.Is(err, concurrency.ErrQueueFull) {
+		t.Errorf("got %v, want ErrQueueFull", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := pool.Submit(ctx, task); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want context.DeadlineExceeded", err)
+	}
+
+	close(release)
+	if err := pool.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := ran.Load(); got != 1 {
+		t.Errorf("%d queued tasks ran before shutdown, want 1", got)
+	}
+	if err := pool.Submit(context.Background(), task); !errors.Is(err, concurrency.ErrPoolClosed) {
+		t.Errorf("got %v, want ErrPoolClosed", err)
+	}
+}
@@ -2,58 +2,154 @@ package disk
 
 import (
 	"concurrency_hw1/pkg/common"
+	"concurrency_hw1/pkg/concurrency"
 	"concurrency_hw1/pkg/logger"
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// retryInterval is how often records that could not be written are tried
+// again.
+const retryInterval = 100 * time.Millisecond
+
 type DiskStorage struct {
 	file      *os.File
 	log       *logger.Logger
 	path      string
 	batchSize int
 	storageCh chan []byte
+	breaker   *concurrency.CircuitBreaker
+}
+
+type DiskStorageOption func(*DiskStorage)
+
+// WithCircuitBreaker stops writing once the disk keeps failing, the records
+// wait while the breaker is open and are written once it lets a probe
+// through.
+func WithCircuitBreaker(breaker *concurrency.CircuitBreaker) DiskStorageOption {
+	return func(d *DiskStorage) {
+		d.breaker = breaker
+	}
 }
 
-func NewDiskStorage(path string, batchSize string, log *logger.Logger) (*DiskStorage, error) {
-	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+func NewDiskStorage(path string, batchSize string, log *logger.Logger, options ...DiskStorageOption) (*DiskStorage, error) {
+	segments, err := Segments(path)
 	if err != nil {
 		return nil, err
 	}
 
+	// keep appending to the latest segment, the base file is the first one
+	current := path
+	if len(segments) > 0 {
+		current = segments[len(segments)-1]
+	}
+	file, err := os.OpenFile(current, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := terminateRecord(file); err != nil {
+		file.Close()
+		return nil, err
+	}
+
 	size, err := common.ParseSize(batchSize)
 	if err != nil {
 		return nil, err
 	}
 
-	return &DiskStorage{
+	d := &DiskStorage{
 		file:      file,
 		log:       log,
 		path:      path,
 		batchSize: size,
 		storageCh: make(chan []byte),
-	}, nil
+	}
+	for _, option := range options {
+		option(d)
+	}
+	return d, nil
 }
 
+// StartStorageRoutine writes the records sent to the returned channel in
+// order. Clients were answered before their records are written, so a
+// record that fails is kept and retried together with the ones after it,
+// it is never dropped. The retries are the probes of the breaker, writers
+// are kept out until it is closed.
 func (d *DiskStorage) StartStorageRoutine(ctx context.Context) (chan []byte, error) {
 	go func() {
+		var pending [][]byte
+		retry := time.NewTicker(retryInterval)
+		defer retry.Stop()
+
 		for {
 			select {
 			case <-ctx.Done():
+				if len(pending) > 0 {
+					d.log.Error("stopped with %d WAL records not written", len(pending))
+				}
 				return
 			case data := <-d.storageCh:
-				if err := d.append(data); err != nil {
-					d.log.Error("failed to append data to file: %v", err)
+				pending = append(pending, data)
+			case <-retry.C:
+				if len(pending) == 0 {
+					d.probe()
+					continue
 				}
 			}
+			pending = d.flush(pending)
 		}
 	}()
 
 	return d.storageCh, nil
 }
 
+// flush writes the pending records in order and returns the ones left after
+// the first failure.
+func (d *DiskStorage) flush(pending [][]byte) [][]byte {
+	for len(pending) > 0 {
+		err := d.write(pending[0])
+		if errors.Is(err, concurrency.ErrCircuitOpen) {
+			// the breaker reports the failing disk itself
+			return pending
+		}
+		if err != nil {
+			d.log.Error("failed to append data to file, retrying: %v", err)
+			return pending
+		}
+		pending[0] = nil
+		pending = pending[1:]
+	}
+	return nil
+}
+
+// probe checks the disk with a sync while the breaker is half-open and no
+// record is waiting to do it, so the breaker closes without new records.
+func (d *DiskStorage) probe() {
+	if d.breaker == nil || d.breaker.State() != concurrency.BreakerHalfOpen {
+		return
+	}
+	if err := d.breaker.Execute(d.file.Sync); err != nil && !errors.Is(err, concurrency.ErrCircuitOpen) {
+		d.log.Error("disk probe failed: %v", err)
+	}
+}
+
+func (d *DiskStorage) write(data []byte) error {
+	if d.breaker == nil {
+		return d.append(data)
+	}
+	return d.breaker.Execute(func() error {
+		return d.append(data)
+	})
+}
+
 func (d *DiskStorage) append(data []byte) error {
 	info, err := d.file.Stat()
 	if err != nil {
@@ -61,30 +157,94 @@ func (d *DiskStorage) append(data []byte) error {
 	}
 
 	if info.Size()+int64(len(data)) > int64(d.batchSize) {
-		err = d.close()
-		if err != nil {
-			return fmt.Errorf("failed to close file: %w", err)
-		}
-
+		// the current file is kept if the new one cannot be created, so
+		// writing recovers once the disk does
 		timestamp := time.Now().Unix()
 		newPath := fmt.Sprintf("%s.%d", d.path, timestamp)
-		d.file, err = os.OpenFile(newPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		file, err := os.OpenFile(newPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 		if err != nil {
 			return fmt.Errorf("failed to create new file: %w", err)
 		}
+		if err := d.close(); err != nil {
+			d.log.Error("failed to close file: %v", err)
+		}
+		d.file = file
 		d.log.Info("Created new file: %s", newPath)
-	}
-	if err != nil {
-		return fmt.Errorf("failed to append data to file: %w", err)
+
+		if info, err = d.file.Stat(); err != nil {
+			return fmt.Errorf("failed to get file stats: %w", err)
+		}
 	}
 
 	if _, err := d.file.Write(data); err != nil {
+		// cut a partly written record off, it would break the next one
+		if truncateErr := d.file.Truncate(info.Size()); truncateErr != nil {
+			d.log.Error("failed to truncate file: %v", truncateErr)
+		}
 		return err
 	}
 
 	return d.file.Sync()
 }
 
+// terminateRecord ends the last record of the file with a newline if it has
+// none. Logs written before records were newline terminated end without one,
+// the next record would be glued to it.
+func terminateRecord(file *os.File) error {
+	info, err := file.Stat()
+	if err != nil || info.Size() == 0 {
+		return err
+	}
+
+	last := make([]byte, 1)
+	if _, err := file.ReadAt(last, info.Size()-1); err != nil {
+		return fmt.Errorf("failed to read the last record: %w", err)
+	}
+	if last[0] == '\n' {
+		return nil
+	}
+	if _, err := file.Write([]byte{'\n'}); err != nil {
+		return fmt.Errorf("failed to terminate the last record: %w", err)
+	}
+	return file.Sync()
+}
+
+// Segments returns the existing files of the log at path in the order they
+// were written: the base file followed by "path.<unix time>" segments.
+func Segments(path string) ([]string, error) {
+	matches, err := filepath.Glob(path + ".*")
+	if err != nil {
+		return nil, err
+	}
+
+	type segment struct {
+		path      string
+		timestamp int64
+	}
+	var segments []segment
+	for _, match := range matches {
+		timestamp, err := strconv.ParseInt(strings.TrimPrefix(match, path+"."), 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, segment{path: match, timestamp: timestamp})
+	}
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].timestamp < segments[j].timestamp
+	})
+
+	var paths []string
+	if _, err := os.Stat(path); err == nil {
+		paths = append(paths, path)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	for _, s := range segments {
+		paths = append(paths, s.path)
+	}
+	return paths, nil
+}
+
 func (d *DiskStorage) close() error {
 	return d.file.Close()
 }
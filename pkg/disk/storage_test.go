@@ -0,0 +1,117 @@
+package disk_test
+
+import (
+	"concurrency_hw1/pkg/concurrency"
+	"concurrency_hw1/pkg/disk"
+	"concurrency_hw1/pkg/logger"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDiskStorageKeepsRecordsWhileBreakerIsOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal")
+	breaker := concurrency.NewCircuitBreaker(
+		concurrency.WithFailureThreshold(1),
+		concurrency.WithOpenTimeout(150*time.Millisecond),
+	)
+	storage, err := disk.NewDiskStorage(path, "1MB", logger.New("error", ""), disk.WithCircuitBreaker(breaker))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	records, err := storage.StartStorageRoutine(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	breaker.Failure()
+	records <- []byte("SET a 1\n")
+	records <- []byte("SET b 2\n")
+	time.Sleep(50 * time.Millisecond)
+	if data, _ := os.ReadFile(path); len(data) != 0 {
+		t.Fatalf("got %q written while the breaker is open", data)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) == "SET a 1\nSET b 2\n" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %q, want both records in order once the breaker lets writes through", data)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if state := breaker.State(); state != concurrency.BreakerClosed {
+		t.Errorf("got breaker %s, want closed", state)
+	}
+}
+
+func TestDiskStorageTerminatesLegacyRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal")
+	// logs of the first version have no newline after the records
+	if err := os.WriteFile(path, []byte("SET 1 1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	storage, err := disk.NewDiskStorage(path, "1MB", logger.New("error", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	records, err := storage.StartStorageRoutine(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records <- []byte("SET 2 2\n")
+	deadline := time.Now().Add(time.Second)
+	for {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) == "SET 1 1\nSET 2 2\n" {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %q, want the legacy record terminated before the new one", data)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDiskStorageProbesWithoutRecords(t *testing.T) {
+	breaker := concurrency.NewCircuitBreaker(
+		concurrency.WithFailureThreshold(1),
+		concurrency.WithOpenTimeout(10*time.Millisecond),
+		concurrency.WithHalfOpenSuccesses(2),
+	)
+	storage, err := disk.NewDiskStorage(filepath.Join(t.TempDir(), "wal"), "1MB", logger.New("error", ""), disk.WithCircuitBreaker(breaker))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := storage.StartStorageRoutine(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// writers are rejected until the breaker closes, no record comes to probe
+	breaker.Failure()
+	deadline := time.Now().Add(2 * time.Second)
+	for breaker.State() != concurrency.BreakerClosed {
+		if time.Now().After(deadline) {
+			t.Fatalf("got breaker %s, want it closed by probes of the healthy disk", breaker.State())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
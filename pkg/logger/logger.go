@@ -30,18 +30,8 @@ var _ LoggerInterface = (*Logger)(nil)
 
 // New -.
 func New(level, serviceEnv string) *Logger {
-	var l zerolog.Level
-
-	switch strings.ToLower(level) {
-	case "error":
-		l = zerolog.ErrorLevel
-	case "warn":
-		l = zerolog.WarnLevel
-	case "info":
-		l = zerolog.InfoLevel
-	case "debug":
-		l = zerolog.DebugLevel
-	default:
+	l, err := parseLevel(level)
+	if err != nil {
 		l = zerolog.InfoLevel
 	}
 
@@ -69,6 +59,38 @@ func New(level, serviceEnv string) *Logger {
 	}
 }
 
+// SetLevel changes the level of every logger, the output format chosen by
+// New stays.
+func SetLevel(level string) error {
+	l, err := parseLevel(level)
+	if err != nil {
+		return err
+	}
+	zerolog.SetGlobalLevel(l)
+	return nil
+}
+
+// ValidateLevel fails for a level SetLevel does not know.
+func ValidateLevel(level string) error {
+	_, err := parseLevel(level)
+	return err
+}
+
+func parseLevel(level string) (zerolog.Level, error) {
+	switch strings.ToLower(level) {
+	case "error":
+		return zerolog.ErrorLevel, nil
+	case "warn":
+		return zerolog.WarnLevel, nil
+	case "info":
+		return zerolog.InfoLevel, nil
+	case "debug":
+		return zerolog.DebugLevel, nil
+	default:
+		return zerolog.InfoLevel, fmt.Errorf("unknown log level %q", level)
+	}
+}
+
 // Debug -.
 func (l *Logger) Debug(message interface{}, args ...interface{}) {
 	l.msg("debug", message, args...)
@@ -0,0 +1,89 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// UnixScheme prefixes addresses of unix domain sockets, e.g.
+	// "unix:///run/spider.sock"
+	UnixScheme = "unix://"
+
+	defaultUnixSocketMode os.FileMode = 0o660
+)
+
+// ParseAddress returns the network and the address to dial or listen on:
+// "unix" and the socket path for unix:// addresses, "tcp" for the rest.
+func ParseAddress(address string) (string, string) {
+	if path, ok := strings.CutPrefix(address, UnixScheme); ok {
+		return "unix", path
+	}
+	return "tcp", address
+}
+
+// Dial connects to a TCP or a unix:// address.
+func Dial(address string) (net.Conn, error) {
+	return net.Dial(ParseAddress(address))
+}
+
+// ParseSocketMode parses an octal file mode like "0660", empty means the
+// default one.
+func ParseSocketMode(mode string) (os.FileMode, error) {
+	if mode == "" {
+		return defaultUnixSocketMode, nil
+	}
+
+	value, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || value > 0o777 {
+		return 0, fmt.Errorf("incorrect socket mode %q", mode)
+	}
+	return os.FileMode(value), nil
+}
+
+// listen opens a listener on a TCP or a unix:// address. The file of a unix
+// socket left by a server that did not stop cleanly is removed first, a
+// socket that still accepts connections is never taken over.
+func listen(address string, mode os.FileMode) (net.Listener, error) {
+	network, path := ParseAddress(address)
+	if network != "unix" {
+		return net.Listen(network, path)
+	}
+
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen(network, path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("failed to set socket mode: %w", err)
+	}
+	return listener, nil
+}
+
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+
+	if connection, err := net.Dial("unix", path); err == nil {
+		_ = connection.Close()
+		return fmt.Errorf("%s is used by a running server", path)
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,158 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type connKey struct{}
+
+type remoteAddrKey struct{}
+
+// Conn is the client connection a request came from. Handlers get it with
+// ConnFromContext to write to the client outside of a response, e.g. to
+// push messages to a subscriber. All writes go through Conn, so responses
+// and pushed data never interleave.
+type Conn struct {
+	connection   net.Conn
+	ctx          context.Context
+	writeTimeout time.Duration
+	writeMu      sync.Mutex
+	closeOnce    sync.Once
+	pushMode     atomic.Bool
+	// pending is data read by WatchClose while a request was blocked, the
+	// request loop reads it first
+	pending []byte
+}
+
+func newConn(ctx context.Context, connection net.Conn, writeTimeout time.Duration) *Conn {
+	return &Conn{
+		connection:   connection,
+		ctx:          ctx,
+		writeTimeout: writeTimeout,
+	}
+}
+
+// ConnFromContext returns the connection of a request or nil if the request
+// did not come from the network.
+func ConnFromContext(ctx context.Context) *Conn {
+	conn, _ := ctx.Value(connKey{}).(*Conn)
+	return conn
+}
+
+func withConn(ctx context.Context, conn *Conn) context.Context {
+	return context.WithValue(ctx, connKey{}, conn)
+}
+
+// WithRemoteAddr keeps the address of a client whose requests do not come
+// from a connection, e.g. over HTTP.
+func WithRemoteAddr(ctx context.Context, addr string) context.Context {
+	return context.WithValue(ctx, remoteAddrKey{}, addr)
+}
+
+// RemoteAddrFromContext returns the address of the client of a request or
+// an empty string if it is unknown.
+func RemoteAddrFromContext(ctx context.Context) string {
+	if conn := ConnFromContext(ctx); conn != nil {
+		return conn.RemoteAddr()
+	}
+	addr, _ := ctx.Value(remoteAddrKey{}).(string)
+	return addr
+}
+
+func (c *Conn) RemoteAddr() string {
+	return c.connection.RemoteAddr().String()
+}
+
+// Context is done when the connection is closed or the server is stopped.
+func (c *Conn) Context() context.Context {
+	return c.ctx
+}
+
+func (c *Conn) Write(data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.writeTimeout != 0 {
+		if err := c.connection.SetWriteDeadline(time.Now().Add(c.writeTimeout)); err != nil {
+			return err
+		}
+	}
+	_, err := c.connection.Write(data)
+	return err
+}
+
+// SetPushMode switches the connection to push mode and back. A connection
+// in push mode mostly waits for data from the server, so it is not closed
+// after the idle timeout.
+func (c *Conn) SetPushMode(enabled bool) {
+	c.pushMode.Store(enabled)
+}
+
+func (c *Conn) PushMode() bool {
+	return c.pushMode.Load()
+}
+
+// WatchClose returns a context that is done when the client closes the
+// connection, for requests that block for a long time. The request loop does
+// not read while a request is handled, so the connection is read in the
+// background and the data is kept for the next request. stop must be called
+// before the request returns.
+func (c *Conn) WatchClose(ctx context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		if err := c.connection.SetReadDeadline(time.Time{}); err != nil {
+			cancel()
+			return
+		}
+
+		buffer := make([]byte, 512)
+		for {
+			count, err := c.connection.Read(buffer)
+			c.pending = append(c.pending, buffer[:count]...)
+			if err != nil {
+				// the deadline is set by stop, anything else means the client
+				// is gone
+				if !errors.Is(err, os.ErrDeadlineExceeded) {
+					cancel()
+				}
+				return
+			}
+		}
+	}()
+
+	stop := func() {
+		_ = c.connection.SetReadDeadline(time.Now())
+		<-done
+		cancel()
+	}
+	return ctx, stop
+}
+
+// read reads the next request, starting with the data kept by WatchClose.
+func (c *Conn) read(request []byte) (int, error) {
+	if len(c.pending) != 0 {
+		count := copy(request, c.pending)
+		c.pending = c.pending[count:]
+		return count, nil
+	}
+	return c.connection.Read(request)
+}
+
+// Close closes the connection, the pending read of the request loop fails
+// and the connection is released. It is safe to call Close more than once.
+func (c *Conn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		err = c.connection.Close()
+	})
+	return err
+}
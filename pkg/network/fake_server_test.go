@@ -0,0 +1,114 @@
+package network_test
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer answers requests with respond, a nil response closes the
+// connection without answering. It counts the connections it accepted.
+type fakeServer struct {
+	listener net.Listener
+	respond  func(connection, request int, data []byte) []byte
+
+	mu          sync.Mutex
+	connections []net.Conn
+	wg          sync.WaitGroup
+}
+
+func newFakeServer(t *testing.T, respond func(connection, request int, data []byte) []byte) *fakeServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &fakeServer{listener: listener, respond: respond}
+	s.wg.Add(1)
+	go s.serve()
+	t.Cleanup(s.close)
+	return s
+}
+
+// pong answers PING and echoes everything else.
+func pong(_, _ int, data []byte) []byte {
+	if string(data) == "PING" {
+		return []byte("PONG")
+	}
+	return data
+}
+
+func (s *fakeServer) address() string {
+	return s.listener.Addr().String()
+}
+
+// accepted returns the number of accepted connections once it reaches
+// want, a dialed connection is accepted a moment later.
+func (s *fakeServer) accepted(want int) int {
+	deadline := time.Now().Add(time.Second)
+	for {
+		s.mu.Lock()
+		count := len(s.connections)
+		s.mu.Unlock()
+		if count >= want || time.Now().After(deadline) {
+			return count
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// dropConnections closes the connections accepted so far.
+func (s *fakeServer) dropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, connection := range s.connections {
+		connection.Close()
+	}
+}
+
+func (s *fakeServer) close() {
+	s.listener.Close()
+	s.dropConnections()
+	s.wg.Wait()
+}
+
+func (s *fakeServer) serve() {
+	defer s.wg.Done()
+	for {
+		connection, err := s.listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			continue
+		}
+
+		s.mu.Lock()
+		index := len(s.connections)
+		s.connections = append(s.connections, connection)
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer connection.Close()
+			buffer := make([]byte, 4096)
+			for request := 0; ; request++ {
+				n, err := connection.Read(buffer)
+				if err != nil {
+					return
+				}
+				response := s.respond(index, request, buffer[:n])
+				if response == nil {
+					return
+				}
+				if _, err := connection.Write(response); err != nil {
+					return
+				}
+			}
+		}()
+	}
+}
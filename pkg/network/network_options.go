@@ -18,6 +18,26 @@ func WithClientBufferSize(size uint) TCPClientOption {
 	}
 }
 
+// WithReconnect makes the client redial a broken connection up to attempts
+// times (0 means without limit), doubling the delay between attempts from
+// minBackoff up to maxBackoff.
+func WithReconnect(attempts uint, minBackoff, maxBackoff time.Duration) TCPClientOption {
+	return func(client *TCPClient) {
+		client.reconnect = reconnectPolicy{
+			enabled:    true,
+			attempts:   int(attempts),
+			minBackoff: minBackoff,
+			maxBackoff: maxBackoff,
+		}
+	}
+}
+
+func WithClientStateHandler(handler func(ConnectionState)) TCPClientOption {
+	return func(client *TCPClient) {
+		client.stateHandler = handler
+	}
+}
+
 type TCPServerOption func(*TCPServer)
 
 func WithServerIdleTimeout(timeout time.Duration) TCPServerOption {
@@ -32,8 +52,60 @@ func WithServerBufferSize(size uint) TCPServerOption {
 	}
 }
 
+// WithServerRateLimit limits the requests of every connection and of all
+// connections of a client host together.
+func WithServerRateLimit(policy string, connection, client RateLimit) TCPServerOption {
+	return func(server *TCPServer) {
+		server.rateLimits = rateLimits{
+			policy:     policy,
+			connection: connection,
+			client:     client,
+		}
+	}
+}
+
 func WithServerMaxConnectionsNumber(count uint) TCPServerOption {
 	return func(server *TCPServer) {
 		server.maxConnections = int(count)
 	}
 }
+
+type PoolOption func(*Pool)
+
+func WithPoolMinIdle(count uint) PoolOption {
+	return func(pool *Pool) {
+		pool.minIdle = int(count)
+	}
+}
+
+func WithPoolMaxIdle(count uint) PoolOption {
+	return func(pool *Pool) {
+		pool.maxIdle = int(count)
+	}
+}
+
+func WithPoolMaxOpen(count uint) PoolOption {
+	return func(pool *Pool) {
+		pool.maxOpen = int(count)
+	}
+}
+
+func WithPoolWaitTimeout(timeout time.Duration) PoolOption {
+	return func(pool *Pool) {
+		pool.waitTimeout = timeout
+	}
+}
+
+// WithPoolPingTimeout bounds the PING that checks an idle client, a client
+// not answering in time is evicted.
+func WithPoolPingTimeout(timeout time.Duration) PoolOption {
+	return func(pool *Pool) {
+		pool.pingTimeout = timeout
+	}
+}
+
+func WithPoolClientOptions(options ...TCPClientOption) PoolOption {
+	return func(pool *Pool) {
+		pool.clientOptions = append(pool.clientOptions, options...)
+	}
+}
@@ -0,0 +1,303 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	defaultPoolMaxOpen     = 10
+	defaultPoolMaxIdle     = 2
+	defaultPoolPingTimeout = time.Second
+)
+
+var (
+	ErrPoolClosed  = errors.New("pool is closed")
+	ErrPoolTimeout = errors.New("timed out waiting for a free connection")
+)
+
+type PoolStats struct {
+	InUse    int
+	Idle     int
+	Waits    uint64
+	Timeouts uint64
+	Evicted  uint64
+}
+
+// Pool keeps a set of TCP clients connected to the same address. Idle
+// clients are validated with PING before they are handed out again, and
+// clients that fail are closed instead of being returned to the pool.
+type Pool struct {
+	address       string
+	clientOptions []TCPClientOption
+	minIdle       int
+	maxIdle       int
+	maxOpen       int
+	waitTimeout   time.Duration
+	pingTimeout   time.Duration
+
+	// every client handed out by Get holds one slot until it is returned,
+	// done is closed by Close to wake up the callers waiting for one
+	slots chan struct{}
+	done  chan struct{}
+
+	mu     sync.Mutex
+	idle   []*TCPClient
+	stats  PoolStats
+	closed bool
+}
+
+func NewPool(address string, options ...PoolOption) (*Pool, error) {
+	pool := &Pool{
+		address:     address,
+		maxOpen:     defaultPoolMaxOpen,
+		maxIdle:     defaultPoolMaxIdle,
+		pingTimeout: defaultPoolPingTimeout,
+		done:        make(chan struct{}),
+	}
+
+	for _, option := range options {
+		option(pool)
+	}
+
+	if pool.maxOpen <= 0 {
+		return nil, errors.New("max open connections must be positive")
+	}
+	if pool.maxIdle < pool.minIdle {
+		pool.maxIdle = pool.minIdle
+	}
+
+	pool.slots = make(chan struct{}, pool.maxOpen)
+
+	for i := 0; i < pool.minIdle; i++ {
+		client, err := NewTCPClient(pool.address, pool.clientOptions...)
+		if err != nil {
+			pool.Close()
+			return nil, fmt.Errorf("failed to open idle connection: %w", err)
+		}
+		pool.idle = append(pool.idle, client)
+	}
+
+	return pool, nil
+}
+
+// Get returns a healthy client, waiting for a free slot if all of them
+// are in use. The client must be given back with Put or Discard.
+func (p *Pool) Get(ctx context.Context) (*TCPClient, error) {
+	if err := p.acquire(ctx); err != nil {
+		return nil, err
+	}
+
+	evicted := false
+	for {
+		client, err := p.popIdle()
+		if err != nil {
+			<-p.slots
+			return nil, err
+		}
+		if client == nil {
+			break
+		}
+
+		if err := p.ping(ctx, client); err != nil {
+			client.Close()
+			p.mu.Lock()
+			p.stats.Evicted++
+			p.mu.Unlock()
+			evicted = true
+			continue
+		}
+
+		p.markInUse()
+		return client, nil
+	}
+	if evicted {
+		go p.fillIdle()
+	}
+
+	client, err := NewTCPClient(p.address, p.clientOptions...)
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+
+	p.markInUse()
+	return client, nil
+}
+
+// Put returns a client that is still usable back to the pool.
+func (p *Pool) Put(client *TCPClient) {
+	if client == nil {
+		return
+	}
+
+	p.mu.Lock()
+	p.stats.InUse--
+	if p.closed || len(p.idle) >= p.maxIdle {
+		p.mu.Unlock()
+		client.Close()
+	} else {
+		p.idle = append(p.idle, client)
+		p.mu.Unlock()
+	}
+
+	<-p.slots
+}
+
+// Discard closes a client that is known to be broken and releases its slot.
+func (p *Pool) Discard(client *TCPClient) {
+	if client == nil {
+		return
+	}
+	client.Close()
+
+	p.mu.Lock()
+	p.stats.InUse--
+	p.stats.Evicted++
+	p.mu.Unlock()
+
+	<-p.slots
+
+	go p.fillIdle()
+}
+
+// Send executes a single request on a pooled client.
+func (p *Pool) Send(request []byte) ([]byte, error) {
+	client, err := p.Get(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := client.Send(request)
+	if err != nil {
+		p.Discard(client)
+		return nil, err
+	}
+
+	p.Put(client)
+	return response, nil
+}
+
+func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := p.stats
+	stats.Idle = len(p.idle)
+	return stats
+}
+
+// Close closes the idle clients, the ones in use are closed when they are
+// returned. Callers waiting for a free client fail with ErrPoolClosed.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.closed {
+		close(p.done)
+	}
+	p.closed = true
+	for _, client := range p.idle {
+		client.Close()
+	}
+	p.idle = nil
+}
+
+func (p *Pool) acquire(ctx context.Context) error {
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	p.mu.Lock()
+	p.stats.Waits++
+	p.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if p.waitTimeout != 0 {
+		timer := time.NewTimer(p.waitTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	case <-p.done:
+		return ErrPoolClosed
+	case <-ctx.Done():
+		p.countTimeout()
+		return ctx.Err()
+	case <-timeout:
+		p.countTimeout()
+		return ErrPoolTimeout
+	}
+}
+
+// ping checks an idle client, waiting for the answer at most pingTimeout.
+func (p *Pool) ping(ctx context.Context, client *TCPClient) error {
+	if p.pingTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.pingTimeout)
+		defer cancel()
+	}
+	return client.PingContext(ctx)
+}
+
+func (p *Pool) popIdle() (*TCPClient, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil, ErrPoolClosed
+	}
+	if len(p.idle) == 0 {
+		return nil, nil
+	}
+
+	client := p.idle[len(p.idle)-1]
+	p.idle = p.idle[:len(p.idle)-1]
+	return client, nil
+}
+
+func (p *Pool) markInUse() {
+	p.mu.Lock()
+	p.stats.InUse++
+	p.mu.Unlock()
+}
+
+func (p *Pool) countTimeout() {
+	p.mu.Lock()
+	p.stats.Timeouts++
+	p.mu.Unlock()
+}
+
+// fillIdle dials new connections until the pool has minIdle idle clients.
+func (p *Pool) fillIdle() {
+	for {
+		p.mu.Lock()
+		if p.closed || len(p.idle) >= p.minIdle {
+			p.mu.Unlock()
+			return
+		}
+		p.mu.Unlock()
+
+		client, err := NewTCPClient(p.address, p.clientOptions...)
+		if err != nil {
+			return
+		}
+
+		p.mu.Lock()
+		if p.closed || len(p.idle) >= p.minIdle {
+			p.mu.Unlock()
+			client.Close()
+			return
+		}
+		p.idle = append(p.idle, client)
+		p.mu.Unlock()
+	}
+}
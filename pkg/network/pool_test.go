@@ -0,0 +1,218 @@
+package network_test
+
+import (
+	"concurrency_hw1/pkg/network"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPoolReusesClients(t *testing.T) {
+	server := newFakeServer(t, pong)
+	pool, err := network.NewPool(server.address(), network.WithPoolMaxIdle(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	first, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := pool.Stats(); stats.InUse != 1 || stats.Idle != 0 {
+		t.Errorf("got %+v, want 1 in use", stats)
+	}
+	pool.Put(first)
+	if stats := pool.Stats(); stats.InUse != 0 || stats.Idle != 1 {
+		t.Errorf("got %+v, want 1 idle", stats)
+	}
+
+	second, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Put(second)
+	if second != first {
+		t.Error("got a new client instead of the idle one")
+	}
+	if got := server.accepted(1); got != 1 {
+		t.Errorf("got %d connections, want 1", got)
+	}
+}
+
+func TestPoolDiscard(t *testing.T) {
+	server := newFakeServer(t, pong)
+	pool, err := network.NewPool(server.address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	first, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.Discard(first)
+	if stats := pool.Stats(); stats.InUse != 0 || stats.Idle != 0 || stats.Evicted != 1 {
+		t.Errorf("got %+v, want 1 evicted and nothing kept", stats)
+	}
+
+	second, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Put(second)
+	if second == first {
+		t.Error("got the discarded client back")
+	}
+	if got := server.accepted(2); got != 2 {
+		t.Errorf("got %d connections, want 2", got)
+	}
+}
+
+func TestPoolEvictsClientsFailingPing(t *testing.T) {
+	server := newFakeServer(t, pong)
+	pool, err := network.NewPool(server.address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	first, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.Put(first)
+	server.dropConnections()
+
+	second, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Put(second)
+	if second == first {
+		t.Error("got the client of a dropped connection back")
+	}
+	if response, err := second.Send([]byte("GET key")); err != nil || string(response) != "GET key" {
+		t.Errorf("got %q, %v from the new client", response, err)
+	}
+	if stats := pool.Stats(); stats.Evicted != 1 || stats.InUse != 1 {
+		t.Errorf("got %+v, want 1 evicted and 1 in use", stats)
+	}
+}
+
+func TestPoolWaitTimeout(t *testing.T) {
+	server := newFakeServer(t, pong)
+	pool, err := network.NewPool(server.address(), network.WithPoolMaxOpen(1), network.WithPoolWaitTimeout(20*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	client, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pool.Get(context.Background()); !errors.Is(err, network.ErrPoolTimeout) {
+		t.Errorf("got %v, want %v", err, network.ErrPoolTimeout)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := pool.Get(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want %v", err, context.Canceled)
+	}
+	if stats := pool.Stats(); stats.Waits != 2 || stats.Timeouts != 2 {
+		t.Errorf("got %+v, want 2 waits and 2 timeouts", stats)
+	}
+
+	// a waiter gets the client once it is put back
+	done := make(chan error, 1)
+	go func() {
+		waiter, err := pool.Get(context.Background())
+		if err == nil {
+			pool.Put(waiter)
+		}
+		done <- err
+	}()
+	time.Sleep(5 * time.Millisecond)
+	pool.Put(client)
+	if err := <-done; err != nil {
+		t.Errorf("waiter: got %v", err)
+	}
+}
+
+func TestPoolCloseWakesWaiters(t *testing.T) {
+	server := newFakeServer(t, pong)
+	pool, err := network.NewPool(server.address(), network.WithPoolMaxOpen(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := pool.Get(context.Background())
+		done <- err
+	}()
+	for pool.Stats().Waits != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	pool.Close()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, network.ErrPoolClosed) {
+			t.Errorf("got %v, want %v", err, network.ErrPoolClosed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiter is not woken up by Close")
+	}
+}
+
+func TestPoolPingTimeout(t *testing.T) {
+	// the first connection hangs, the later ones answer
+	release := make(chan struct{})
+	server := newFakeServer(t, func(connection, request int, data []byte) []byte {
+		if connection == 0 {
+			<-release
+			return nil
+		}
+		return pong(connection, request, data)
+	})
+	t.Cleanup(func() { close(release) })
+	pool, err := network.NewPool(server.address(), network.WithPoolMinIdle(1), network.WithPoolPingTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	start := time.Now()
+	client, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Put(client)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Get waited %s for the hung client", elapsed)
+	}
+	if response, err := client.Send([]byte("GET key")); err != nil || string(response) != "GET key" {
+		t.Errorf("got %q, %v from the new client", response, err)
+	}
+
+	// the evicted idle client is replaced
+	deadline := time.Now().Add(time.Second)
+	for pool.Stats().Idle != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %+v, want the idle client replaced", pool.Stats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if stats := pool.Stats(); stats.Evicted != 1 {
+		t.Errorf("got %+v, want 1 evicted", stats)
+	}
+}
@@ -0,0 +1,124 @@
+package network
+
+import (
+	"concurrency_hw1/pkg/concurrency"
+	"context"
+	"fmt"
+	"net"
+	"sync"
+)
+
+const (
+	// RateLimitReject answers requests over the limit with an error
+	RateLimitReject = "reject"
+	// RateLimitDelay holds requests over the limit until they fit
+	RateLimitDelay = "delay"
+)
+
+// throttledMessage is written instead of the response of a rejected
+// request, in the error format of the protocol.
+const throttledMessage = "ERR too many requests, slow down"
+
+// RateLimit is a limit of requests and of request bytes per second, zero
+// is unlimited.
+type RateLimit struct {
+	OpsPerSecond   float64
+	BytesPerSecond float64
+}
+
+type rateLimits struct {
+	policy     string
+	connection RateLimit
+	client     RateLimit
+}
+
+func parseRateLimitPolicy(policy string) (string, error) {
+	switch policy {
+	case "", RateLimitReject:
+		return RateLimitReject, nil
+	case RateLimitDelay:
+		return RateLimitDelay, nil
+	default:
+		return "", fmt.Errorf("unknown rate limit policy %q", policy)
+	}
+}
+
+// clientLimiters shares a limiter between the connections of a client host.
+// There are no user accounts, so the host is what identifies a client; all
+// connections over unix sockets are one client.
+type clientLimiters struct {
+	mu       sync.Mutex
+	limit    RateLimit
+	limiters map[string]*clientLimiter
+}
+
+type clientLimiter struct {
+	limiter     *concurrency.RateLimiter
+	connections int
+}
+
+func newClientLimiters(limit RateLimit) *clientLimiters {
+	return &clientLimiters{
+		limit:    limit,
+		limiters: make(map[string]*clientLimiter),
+	}
+}
+
+// acquire returns the limiter of the host of the connection, release must
+// be called when the connection is closed.
+func (c *clientLimiters) acquire(connection net.Conn) (*concurrency.RateLimiter, func()) {
+	limiter := concurrency.NewRateLimiter(c.limit.OpsPerSecond, c.limit.BytesPerSecond)
+	if limiter == nil {
+		return nil, func() {}
+	}
+
+	host := clientHost(connection)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	client, ok := c.limiters[host]
+	if !ok {
+		client = &clientLimiter{limiter: limiter}
+		c.limiters[host] = client
+	}
+	client.connections++
+
+	release := func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if client.connections--; client.connections == 0 {
+			delete(c.limiters, host)
+		}
+	}
+	return client.limiter, release
+}
+
+func clientHost(connection net.Conn) string {
+	address := connection.RemoteAddr()
+	if address == nil || address.Network() != "tcp" {
+		return "unix"
+	}
+	host, _, err := net.SplitHostPort(address.String())
+	if err != nil {
+		return address.String()
+	}
+	return host
+}
+
+// throttledResponse ends the error with a new line in push mode, where
+// every response is a line.
+func throttledResponse(conn *Conn) []byte {
+	if conn.PushMode() {
+		return []byte(throttledMessage + "\n")
+	}
+	return []byte(throttledMessage)
+}
+
+// admit applies the rate limits to a request of size bytes. It returns
+// false if the request is rejected, or if ctx is done while it is delayed.
+func admit(ctx context.Context, policy string, size int, limiters ...*concurrency.RateLimiter) bool {
+	if policy == RateLimitDelay {
+		return concurrency.WaitAll(ctx, size, limiters...) == nil
+	}
+	return concurrency.AllowAll(size, limiters...)
+}
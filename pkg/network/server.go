@@ -2,25 +2,37 @@ package network
 
 import (
 	"concurrency_hw1/internal/config"
+	"concurrency_hw1/pkg/concurrency"
 	"concurrency_hw1/pkg/logger"
 	"context"
 	"errors"
 	"fmt"
-	"io"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type TCPHandler = func(context.Context, []byte) []byte
 
+const (
+	maxConnectionsMessage = "ERR max number of connections reached"
+	rejectTimeout         = time.Second
+)
+
 type ServerInterface interface {
 	Execute(ctx context.Context, handleRequest func(ctx context.Context, request []byte) []byte) error
 }
 
 type Server struct {
 	tcpServer *TCPServer
-	logger    logger.LoggerInterface
+	clients   *clientLimiters
+	// connections admits up to maxConnections clients at once, it is
+	// unlimited if maxConnections is 0
+	connections *concurrency.Semaphore
+	// idleTimeout starts as the one of tcpServer and may be changed live
+	idleTimeout atomic.Int64
+	logger      logger.LoggerInterface
 }
 
 func NewServer(cfg *config.Config, logger logger.LoggerInterface) (*Server, error) {
@@ -28,10 +40,27 @@ func NewServer(cfg *config.Config, logger logger.LoggerInterface) (*Server, erro
 	if err != nil {
 		return nil, fmt.Errorf("failed to create TCP server: %w", err)
 	}
-	return &Server{
-		tcpServer: tcpServer,
-		logger:    logger,
-	}, nil
+	s := &Server{
+		tcpServer:   tcpServer,
+		clients:     newClientLimiters(tcpServer.rateLimits.client),
+		connections: new(concurrency.Semaphore),
+		logger:      logger,
+	}
+	s.SetMaxConnections(tcpServer.maxConnections)
+	s.SetIdleTimeout(tcpServer.idleTimeout)
+	return s, nil
+}
+
+// SetMaxConnections changes the connection limit, 0 is unlimited. Open
+// connections over a lowered limit are kept.
+func (s *Server) SetMaxConnections(count int) {
+	s.connections.Resize(int64(count))
+}
+
+// SetIdleTimeout changes how long connections may stay idle, 0 is forever.
+// It applies to the next request of every connection.
+func (s *Server) SetIdleTimeout(timeout time.Duration) {
+	s.idleTimeout.Store(int64(timeout))
 }
 
 func (s *Server) Execute(ctx context.Context, handleRequest func(ctx context.Context, request []byte) []byte) error {
@@ -39,63 +68,96 @@ func (s *Server) Execute(ctx context.Context, handleRequest func(ctx context.Con
 
 	fmt.Println("Welcome to SuperKV database. Waiting for your commands")
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		for {
-			c, err := s.tcpServer.listener.Accept()
-
-			if err != nil {
-				if errors.Is(err, net.ErrClosed) {
+	for _, listener := range s.tcpServer.listeners {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				c, err := listener.Accept()
+
+				if err != nil {
+					if errors.Is(err, net.ErrClosed) {
+						return
+					}
+					s.logger.Error("failed to accept connection: %v", err.Error())
 					return
 				}
-				s.logger.Error("failed to accept connection: %v", err.Error())
-				return
-			}
 
-			go func() {
-				s.handleConnection(ctx, c, handleRequest)
-			}()
-		}
-	}()
+				if !s.connections.TryAcquire(1) {
+					s.reject(c)
+					continue
+				}
+				go func() {
+					defer s.connections.Release(1)
+					s.handleConnection(ctx, c, handleRequest)
+				}()
+			}
+		}()
+	}
 
 	<-ctx.Done()
 	s.logger.Info("stopping TCP server")
-	if err := s.tcpServer.listener.Close(); err != nil {
-		s.logger.Error("failed to close listener: %v", err.Error())
-	}
+	s.tcpServer.close()
 
 	wg.Wait()
 
 	return nil
 }
 
+// ConnectionStats tells how many connections are open, Size is the limit.
+func (s *Server) ConnectionStats() concurrency.SemaphoreStats {
+	return s.connections.Stats()
+}
+
+// reject answers a client over the connection limit with an error instead
+// of leaving it waiting for a response.
+func (s *Server) reject(connection net.Conn) {
+	s.logger.Warn("max connections reached, rejecting %v", connection.RemoteAddr())
+	if err := connection.SetWriteDeadline(time.Now().Add(rejectTimeout)); err == nil {
+		_, _ = connection.Write([]byte(maxConnectionsMessage))
+	}
+	_ = connection.Close()
+}
+
 func (s *Server) handleConnection(ctx context.Context, connection net.Conn, handler TCPHandler) {
+	ctx, cancel := context.WithCancel(ctx)
+	conn := newConn(ctx, connection, time.Duration(s.idleTimeout.Load()))
+	// a stopping server closes the connection to interrupt the pending read
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.Close()
+	})
+
 	defer func() {
 		if v := recover(); v != nil {
 			s.logger.Error("captured panic", v)
 		}
 
-		if err := connection.Close(); err != nil {
+		stop()
+		cancel()
+		if err := conn.Close(); err != nil {
 			s.logger.Warn("failed to close connection: %v", err)
 		}
 	}()
 	request := make([]byte, s.tcpServer.bufferSize)
+	requestCtx := withConn(ctx, conn)
+
+	limits := s.tcpServer.rateLimits
+	connectionLimiter := concurrency.NewRateLimiter(limits.connection.OpsPerSecond, limits.connection.BytesPerSecond)
+	clientLimiter, release := s.clients.acquire(connection)
+	defer release()
 
 Loop:
 	for {
-		if s.tcpServer.idleTimeout != 0 {
-			if err := connection.SetReadDeadline(time.Now().Add(s.tcpServer.idleTimeout)); err != nil {
-				s.logger.Warn("failed to set read deadline %v", err.Error())
-				break Loop
-			}
+		if err := connection.SetReadDeadline(s.readDeadline(conn)); err != nil {
+			s.logger.Warn("failed to set read deadline %v", err.Error())
+			break Loop
 		}
 		select {
 		case <-ctx.Done():
 			break Loop
 		default:
-			count, err := connection.Read(request)
-			if err != nil && errors.Is(err, io.EOF) {
+			count, err := conn.read(request)
+			if err != nil {
 				s.logger.Warn("failed to read from connection: %v", err.Error())
 				break Loop
 			} else if count == s.tcpServer.bufferSize {
@@ -103,19 +165,21 @@ Loop:
 				break Loop
 			}
 
-			if s.tcpServer.idleTimeout != 0 {
-				if err := connection.SetWriteDeadline(time.Now().Add(s.tcpServer.idleTimeout)); err != nil {
-					s.logger.Warn("failed to set read deadline %v", err.Error())
-					break Loop
-				}
-			}
 			s.logger.Info("request: %v", string(request))
-			response := handler(ctx, request[:count])
+			var response []byte
+			if admit(ctx, limits.policy, count, connectionLimiter, clientLimiter) {
+				response = handler(requestCtx, request[:count])
+			} else if ctx.Err() == nil {
+				s.logger.Warn("request from %v is throttled", conn.RemoteAddr())
+				response = throttledResponse(conn)
+			} else {
+				break Loop
+			}
 			s.logger.Info("response: %v", string(response))
-			if _, err := connection.Write(response); err != nil {
+			if err := conn.Write(response); err != nil {
 				s.logger.Warn(
 					"failed to write data to %v: %v",
-					connection.RemoteAddr().String(),
+					conn.RemoteAddr(),
 					err.Error(),
 				)
 				break Loop
@@ -124,3 +188,13 @@ Loop:
 	}
 
 }
+
+// readDeadline returns the deadline of the next request, connections in
+// push mode do not expire.
+func (s *Server) readDeadline(conn *Conn) time.Time {
+	idleTimeout := time.Duration(s.idleTimeout.Load())
+	if idleTimeout == 0 || conn.PushMode() {
+		return time.Time{}
+	}
+	return time.Now().Add(idleTimeout)
+}
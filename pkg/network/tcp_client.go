@@ -1,44 +1,222 @@
 package network
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"net"
+	"strings"
 	"time"
 )
 
-type TCPClient struct {
-	connection  net.Conn
-	idleTimeout time.Duration
-	bufferSize  int
+const (
+	pingRequest  = "PING"
+	pingResponse = "PONG"
+)
+
+var (
+	ErrSmallBuffer       = errors.New("small buffer size")
+	ErrReconnectFailed   = errors.New("failed to reconnect")
+	ErrRequestNotRetried = errors.New("connection was lost, request is not idempotent and was not retried")
+)
+
+// idempotentCommands can be safely repeated after a reconnect because
+// executing them twice does not change the state of the database.
+var idempotentCommands = map[string]struct{}{
+	"GET":           {},
+	"MGET":          {},
+	"EXISTS":        {},
+	"KEYS":          {},
+	"SCAN":          {},
+	"RANGE":         {},
+	"REVRANGE":      {},
+	"PREFIX":        {},
+	"REVPREFIX":     {},
+	"TYPE":          {},
+	"LRANGE":        {},
+	"HGET":          {},
+	"HGETALL":       {},
+	"SMEMBERS":      {},
+	"SISMEMBER":     {},
+	"ZSCORE":        {},
+	"ZRANK":         {},
+	"ZRANGE":        {},
+	"ZRANGEBYSCORE": {},
+	"PING":          {},
 }
 
-func NewTCPClient(address string, options ...TCPClientOption) (*TCPClient, error) {
-	connection, err := net.Dial("tcp", address)
-	if err != nil {
-		return nil, fmt.Errorf("failed to dial: %w", err)
+type ConnectionState int
+
+const (
+	StateConnected ConnectionState = iota
+	StateDisconnected
+	StateReconnecting
+	StateClosed
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case StateConnected:
+		return "connected"
+	case StateDisconnected:
+		return "disconnected"
+	case StateReconnecting:
+		return "reconnecting"
+	case StateClosed:
+		return "closed"
+	default:
+		return "unknown"
 	}
+}
+
+type reconnectPolicy struct {
+	enabled    bool
+	attempts   int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
 
+type TCPClient struct {
+	address      string
+	connection   net.Conn
+	idleTimeout  time.Duration
+	bufferSize   int
+	reconnect    reconnectPolicy
+	state        ConnectionState
+	stateHandler func(ConnectionState)
+}
+
+func NewTCPClient(address string, options ...TCPClientOption) (*TCPClient, error) {
 	client := &TCPClient{
-		connection: connection,
+		address:    address,
 		bufferSize: defaultBufferSize,
+		state:      StateDisconnected,
 	}
 
 	for _, option := range options {
 		option(client)
 	}
 
-	if client.idleTimeout != 0 {
-		if err := connection.SetDeadline(time.Now().Add(client.idleTimeout)); err != nil {
-			return nil, fmt.Errorf("failed to set deadline for connection: %w", err)
-		}
+	var err error
+	if client.reconnect.enabled {
+		err = client.redial(context.Background())
+	} else {
+		err = client.dial()
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	return client, nil
 }
 
+// Send writes the request and waits for the response. When reconnect is
+// enabled, a broken connection is redialed; idempotent requests are then
+// repeated, other requests fail with ErrRequestNotRetried.
 func (c *TCPClient) Send(request []byte) ([]byte, error) {
+	return c.SendContext(context.Background(), request)
+}
+
+// SendContext is Send that gives up once ctx is done, also while the
+// request is in flight. The connection is closed then, the response would
+// come as the one of the next request.
+func (c *TCPClient) SendContext(ctx context.Context, request []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	for retry := 0; ; retry++ {
+		response, err := c.send(ctx, request)
+		if ctxErr := contextDone(ctx); err != nil && ctxErr != nil {
+			c.drop()
+			return nil, fmt.Errorf("%w: %w", ctxErr, err)
+		}
+		if err == nil || !c.reconnect.enabled || errors.Is(err, ErrSmallBuffer) {
+			return response, err
+		}
+		if c.reconnect.attempts != 0 && retry >= c.reconnect.attempts {
+			return nil, fmt.Errorf("%w: %w", ErrReconnectFailed, err)
+		}
+
+		c.setState(StateDisconnected)
+		if err := c.redial(ctx); err != nil {
+			return nil, err
+		}
+
+		if !isIdempotent(request) {
+			return nil, fmt.Errorf("%w: %w", ErrRequestNotRetried, err)
+		}
+	}
+}
+
+// Ping checks that the server on the other side of the connection is alive.
+func (c *TCPClient) Ping() error {
+	return c.PingContext(context.Background())
+}
+
+// PingContext is Ping that gives up once ctx is done.
+func (c *TCPClient) PingContext(ctx context.Context) error {
+	response, err := c.SendContext(ctx, []byte(pingRequest))
+	if err != nil {
+		return err
+	}
+
+	if string(response) != pingResponse {
+		return fmt.Errorf("unexpected ping response: %q", response)
+	}
+
+	return nil
+}
+
+// Receive waits for data the server pushes without a request, e.g. the
+// messages of a subscription. Unlike Send it does not time out.
+func (c *TCPClient) Receive() ([]byte, error) {
+	if c.connection == nil {
+		return nil, net.ErrClosed
+	}
+	if err := c.connection.SetDeadline(time.Time{}); err != nil {
+		return nil, fmt.Errorf("failed to reset deadline for connection: %w", err)
+	}
+
+	data := make([]byte, c.bufferSize)
+	count, err := c.connection.Read(data)
+	if err != nil {
+		return nil, err
+	}
+	return data[:count], nil
+}
+
+func (c *TCPClient) Close() {
+	if c.connection != nil {
+		_ = c.connection.Close()
+	}
+	c.setState(StateClosed)
+}
+
+func (c *TCPClient) send(ctx context.Context, request []byte) ([]byte, error) {
+	if c.connection == nil {
+		return nil, net.ErrClosed
+	}
+	// the deadline is refreshed for every operation, so a client that is
+	// kept around (e.g. in a pool) does not expire after the first timeout
+	var deadline time.Time
+	if c.idleTimeout != 0 {
+		deadline = time.Now().Add(c.idleTimeout)
+	}
+	if ctxDeadline, ok := ctx.Deadline(); ok && (deadline.IsZero() || ctxDeadline.Before(deadline)) {
+		deadline = ctxDeadline
+	}
+	if err := c.connection.SetDeadline(deadline); err != nil {
+		return nil, fmt.Errorf("failed to set deadline for connection: %w", err)
+	}
+	// a cancelled request interrupts the pending write or read
+	connection := c.connection
+	stop := context.AfterFunc(ctx, func() {
+		_ = connection.SetDeadline(time.Unix(1, 0))
+	})
+	defer stop()
+
 	if _, err := c.connection.Write(request); err != nil {
 		return nil, err
 	}
@@ -48,14 +226,113 @@ func (c *TCPClient) Send(request []byte) ([]byte, error) {
 	if err != nil && err != io.EOF {
 		return nil, err
 	} else if count == c.bufferSize {
-		return nil, errors.New("small buffer size")
+		return nil, ErrSmallBuffer
+	} else if count == 0 {
+		return nil, io.EOF
 	}
 
 	return response[:count], nil
 }
 
-func (c *TCPClient) Close() {
+// drop closes the connection after a request was given up, the next one
+// redials if reconnect is enabled.
+func (c *TCPClient) drop() {
+	if c.connection != nil {
+		_ = c.connection.Close()
+		c.connection = nil
+	}
+	c.setState(StateDisconnected)
+}
+
+func (c *TCPClient) dial() error {
+	connection, err := Dial(c.address)
+	if err != nil {
+		return fmt.Errorf("failed to dial: %w", err)
+	}
+
+	c.connection = connection
+	c.setState(StateConnected)
+	return nil
+}
+
+// redial replaces the current connection, sleeping with jittered
+// exponential backoff between unsuccessful attempts until ctx is done.
+func (c *TCPClient) redial(ctx context.Context) error {
 	if c.connection != nil {
 		_ = c.connection.Close()
+		c.connection = nil
+	}
+
+	c.setState(StateReconnecting)
+
+	var err error
+	for attempt := 0; c.reconnect.attempts == 0 || attempt < c.reconnect.attempts; attempt++ {
+		if attempt > 0 {
+			timer := time.NewTimer(c.backoff(attempt - 1))
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				c.setState(StateDisconnected)
+				return fmt.Errorf("%w: %w", ErrReconnectFailed, ctx.Err())
+			}
+		}
+
+		if err = c.dial(); err == nil {
+			return nil
+		}
 	}
+
+	c.setState(StateDisconnected)
+	return fmt.Errorf("%w: %w", ErrReconnectFailed, err)
+}
+
+func (c *TCPClient) backoff(attempt int) time.Duration {
+	delay := c.reconnect.minBackoff
+	for i := 0; i < attempt && delay < c.reconnect.maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > c.reconnect.maxBackoff {
+		delay = c.reconnect.maxBackoff
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	// full jitter in the upper half keeps retries of many clients apart
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
+
+func (c *TCPClient) setState(state ConnectionState) {
+	if c.state == state {
+		return
+	}
+
+	c.state = state
+	if c.stateHandler != nil {
+		c.stateHandler(state)
+	}
+}
+
+// contextDone is ctx.Err, but it reports a passed deadline right away, the
+// connection may time out before the context does.
+func contextDone(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+		return context.DeadlineExceeded
+	}
+	return nil
+}
+
+func isIdempotent(request []byte) bool {
+	fields := strings.Fields(string(request))
+	if len(fields) == 0 {
+		return false
+	}
+
+	_, ok := idempotentCommands[strings.ToUpper(fields[0])]
+	return ok
 }
@@ -0,0 +1,135 @@
+package network_test
+
+import (
+	"concurrency_hw1/pkg/network"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// dropFirstRequest closes the first connection when its first request comes,
+// the later connections answer.
+func dropFirstRequest(connection, request int, data []byte) []byte {
+	if connection == 0 {
+		return nil
+	}
+	return pong(connection, request, data)
+}
+
+func TestTCPClientReconnect(t *testing.T) {
+	tests := []struct {
+		name    string
+		request string
+		want    string
+		wantErr error
+	}{
+		{name: "idempotent request is retried", request: "GET key", want: "GET key"},
+		{name: "other request is not retried", request: "SET key value", wantErr: network.ErrRequestNotRetried},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer(t, dropFirstRequest)
+			var states []network.ConnectionState
+			client, err := network.NewTCPClient(server.address(),
+				network.WithReconnect(3, time.Millisecond, 5*time.Millisecond),
+				network.WithClientStateHandler(func(state network.ConnectionState) {
+					states = append(states, state)
+				}),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+
+			response, err := client.Send([]byte(tt.request))
+			if !errors.Is(err, tt.wantErr) || string(response) != tt.want {
+				t.Errorf("got %q, %v, want %q, %v", response, err, tt.want, tt.wantErr)
+			}
+			if got := server.accepted(2); got != 2 {
+				t.Errorf("got %d connections, want a redial", got)
+			}
+			if last := states[len(states)-1]; last != network.StateConnected {
+				t.Errorf("got state %s after the redial, want connected", last)
+			}
+		})
+	}
+}
+
+func TestTCPClientBackoffStopsWithContext(t *testing.T) {
+	server := newFakeServer(t, pong)
+	client, err := network.NewTCPClient(server.address(), network.WithReconnect(0, 50*time.Millisecond, time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	// nothing listens any more, the client redials forever
+	server.close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = client.SendContext(ctx, []byte("GET key"))
+	if !errors.Is(err, network.ErrReconnectFailed) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want %v with %v", err, network.ErrReconnectFailed, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("redialing went on for %s after the context was done", elapsed)
+	}
+}
+
+func TestTCPClientSendStopsWithContext(t *testing.T) {
+	tests := []struct {
+		name    string
+		ctx     func() (context.Context, context.CancelFunc)
+		wantErr error
+	}{
+		{
+			name: "cancelled",
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(50*time.Millisecond, cancel)
+				return ctx, cancel
+			},
+			wantErr: context.Canceled,
+		},
+		{
+			name: "deadline",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 50*time.Millisecond)
+			},
+			wantErr: context.DeadlineExceeded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the server never answers
+			release := make(chan struct{})
+			server := newFakeServer(t, func(_, _ int, _ []byte) []byte {
+				<-release
+				return nil
+			})
+			t.Cleanup(func() { close(release) })
+			client, err := network.NewTCPClient(server.address())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+
+			ctx, cancel := tt.ctx()
+			defer cancel()
+			start := time.Now()
+			if _, err := client.SendContext(ctx, []byte("GET key")); !errors.Is(err, tt.wantErr) {
+				t.Errorf("got %v, want %v", err, tt.wantErr)
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("waited %s for the response after the context was done", elapsed)
+			}
+			// the late response must not be taken for the one of the next request
+			if _, err := client.Send([]byte("GET key")); !errors.Is(err, net.ErrClosed) {
+				t.Errorf("got %v after giving up a request, want %v", err, net.ErrClosed)
+			}
+		})
+	}
+}
@@ -13,12 +13,15 @@ import (
 
 const defaultServerAddress = ":3223"
 
+// TCPServer listens on a TCP address, a unix socket or several of them at
+// once.
 type TCPServer struct {
-	listener net.Listener
+	listeners []net.Listener
 
 	idleTimeout    time.Duration
 	bufferSize     int
 	maxConnections int
+	rateLimits     rateLimits
 	logger         logger.LoggerInterface
 }
 
@@ -31,14 +34,26 @@ func NewTCPServer(cfg *config.Config, logger logger.LoggerInterface) (*TCPServer
 		return nil, errors.New("empty logger")
 	}
 
-	listener, err := net.Listen("tcp", cfg.Network.Address)
+	socketMode, err := ParseSocketMode(cfg.Network.UnixSocketMode)
 	if err != nil {
-		return nil, fmt.Errorf("failed to listen: %w", err)
+		return nil, err
+	}
+
+	address := cfg.Network.Address
+	if address == "" {
+		address = defaultServerAddress
 	}
 
 	server := &TCPServer{
-		listener: listener,
-		logger:   logger,
+		logger: logger,
+	}
+	for _, address := range append([]string{address}, cfg.Network.ExtraAddresses...) {
+		listener, err := listen(address, socketMode)
+		if err != nil {
+			server.close()
+			return nil, fmt.Errorf("failed to listen on %s: %w", address, err)
+		}
+		server.listeners = append(server.listeners, listener)
 	}
 
 	options, err := server.getOptions(cfg.Network)
@@ -58,13 +73,17 @@ func NewTCPServer(cfg *config.Config, logger logger.LoggerInterface) (*TCPServer
 
 }
 
+func (s *TCPServer) close() {
+	for _, listener := range s.listeners {
+		if err := listener.Close(); err != nil {
+			s.logger.Error("failed to close listener: %v", err.Error())
+		}
+	}
+}
+
 func (s *TCPServer) getOptions(cfg *config.NetworkConfig) ([]TCPServerOption, error) {
 	var options []TCPServerOption
 
-	if cfg.Address != "" {
-		cfg.Address = defaultServerAddress
-	}
-
 	if cfg.MaxConnections != 0 {
 		options = append(options, WithServerMaxConnectionsNumber(uint(cfg.MaxConnections)))
 	}
@@ -82,5 +101,44 @@ func (s *TCPServer) getOptions(cfg *config.NetworkConfig) ([]TCPServerOption, er
 		options = append(options, WithServerIdleTimeout(cfg.IdleTimeout))
 	}
 
+	if cfg.RateLimit != nil {
+		option, err := rateLimitOption(cfg.RateLimit)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, option)
+	}
+
 	return options, nil
 }
+
+func rateLimitOption(cfg *config.RateLimitConfig) (TCPServerOption, error) {
+	policy, err := parseRateLimitPolicy(cfg.Policy)
+	if err != nil {
+		return nil, err
+	}
+
+	connectionBytes, err := parseRate(cfg.ConnectionBytesPerSecond)
+	if err != nil {
+		return nil, errors.New("incorrect connection bytes per second")
+	}
+	clientBytes, err := parseRate(cfg.ClientBytesPerSecond)
+	if err != nil {
+		return nil, errors.New("incorrect client bytes per second")
+	}
+
+	return WithServerRateLimit(
+		policy,
+		RateLimit{OpsPerSecond: cfg.ConnectionOpsPerSecond, BytesPerSecond: connectionBytes},
+		RateLimit{OpsPerSecond: cfg.ClientOpsPerSecond, BytesPerSecond: clientBytes},
+	), nil
+}
+
+// parseRate parses a size per second, empty means unlimited.
+func parseRate(size string) (float64, error) {
+	if size == "" {
+		return 0, nil
+	}
+	value, err := common.ParseSize(size)
+	return float64(value), err
+}